@@ -6,6 +6,7 @@ tool github.com/golangci/golangci-lint/cmd/golangci-lint
 
 require (
 	github.com/edaniels/golinters v0.0.5-0.20220906153528-641155550742
+	github.com/mkch/gpio v0.0.0-20190919032813-8327cd97d95e
 	github.com/pkg/errors v0.9.1
 	github.com/rhysd/actionlint v1.7.8
 	github.com/viam-modules/pinctrl v0.0.0-20251230164603-b51a5031d7da
@@ -14,6 +15,7 @@ require (
 	go.viam.com/rdk v0.102.1
 	go.viam.com/test v1.2.4
 	go.viam.com/utils v0.1.176
+	golang.org/x/sys v0.37.0
 )
 
 require (
@@ -197,7 +199,6 @@ require (
 	github.com/miekg/dns v1.1.53 // indirect
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
-	github.com/mkch/gpio v0.0.0-20190919032813-8327cd97d95e // indirect
 	github.com/montanaflynn/stats v0.7.1 // indirect
 	github.com/moricho/tparallel v0.3.2 // indirect
 	github.com/muesli/clusters v0.0.0-20200529215643-2700303c1762 // indirect
@@ -320,7 +321,6 @@ require (
 	golang.org/x/net v0.43.0 // indirect
 	golang.org/x/oauth2 v0.30.0 // indirect
 	golang.org/x/sync v0.17.0 // indirect
-	golang.org/x/sys v0.37.0 // indirect
 	golang.org/x/text v0.28.0 // indirect
 	golang.org/x/time v0.6.0 // indirect
 	golang.org/x/tools v0.35.0 // indirect
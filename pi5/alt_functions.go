@@ -0,0 +1,100 @@
+//go:build linux
+
+package pi5
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+	rpiutils "raspberry-pi/utils"
+)
+
+// reconfigureAltFunctions muxes every pin configured as `alt` to its RP1 function. Pins that were
+// previously configured as `alt` but no longer are get returned to the SYS_RIO function and become
+// available as GPIOs again.
+func (b *pinctrlpi5) reconfigureAltFunctions(newConf *rpiutils.Config) error {
+	newAlts := map[uint]rpiutils.PinConfig{}
+	for _, newConfig := range newConf.Pins {
+		if newConfig.Type != rpiutils.PinAlt {
+			continue
+		}
+		bcom, ok := rpiutils.BroadcomPinFromHardwareLabel(newConfig.Pin)
+		if !ok {
+			return errors.Errorf("cannot find GPIO for unknown pin: %s", newConfig.Pin)
+		}
+		newAlts[bcom] = newConfig
+	}
+
+	for _, oldConfig := range b.pinConfigs {
+		if oldConfig.Type != rpiutils.PinAlt {
+			continue
+		}
+		bcom, ok := rpiutils.BroadcomPinFromHardwareLabel(oldConfig.Pin)
+		if !ok {
+			return errors.Errorf("cannot find GPIO for unknown pin: %s", oldConfig.Name)
+		}
+		if _, ok := newAlts[bcom]; ok {
+			continue
+		}
+		if err := b.setFunction(bcom, funcSelSysRIO); err != nil {
+			return err
+		}
+		// add back the gpio pin to make it available to the user
+		b.gpios[bcom] = b.boardPinCtrl.CreateGpioPin(b.gpioMappings[oldConfig.Pin], rpiutils.DefaultPWMFreqHz)
+	}
+
+	for bcom, altConfig := range newAlts {
+		function, isRP1, err := rpiutils.ParseAltFunction(altConfig.Function)
+		if err != nil {
+			return err
+		}
+		if !isRP1 {
+			return errors.Errorf("alternate function %s on pin %s is not supported on the Pi 5, use a0-a8",
+				altConfig.Function, altConfig.Name)
+		}
+		// the pin is driven by a peripheral now, so stop managing it as a gpio
+		if gpio, ok := b.gpios[bcom]; ok {
+			if err := gpio.Close(); err != nil {
+				return err
+			}
+			delete(b.gpios, bcom)
+		}
+		if err := b.setFunction(bcom, uint32(function)); err != nil {
+			return err
+		}
+		b.logger.Debugf("set pin %s to %s", altConfig.Name, altConfig.Function)
+	}
+	return nil
+}
+
+// setFunction writes the function select field of a gpio's CTRL register, and enables the pad so
+// the selected peripheral can drive and read the pin.
+// The board mutex should be locked before calling this.
+func (b *pinctrlpi5) setFunction(bcom uint, funcSel uint32) error {
+	ctrlOffset := ioBank0Offset + 8*int(bcom) + 4
+	ctrl, err := b.readRegister(ctrlOffset)
+	if err != nil {
+		return err
+	}
+	padOffset := padsBank0Offset + 4 + 4*int(bcom)
+	pad, err := b.readRegister(padOffset)
+	if err != nil {
+		return err
+	}
+
+	pad = (pad | padInputEn) &^ padOutputDis
+	if err := b.writeRegister(padOffset, pad); err != nil {
+		return err
+	}
+	return b.writeRegister(ctrlOffset, (ctrl&^ctrlFuncSelMask)|funcSel)
+}
+
+// writeRegister writes a little endian 32 bit register in the mapped gpio memory.
+func (b *pinctrlpi5) writeRegister(offset int, value uint32) error {
+	if offset < 0 || offset+4 > len(b.boardPinCtrl.VPage) {
+		return fmt.Errorf("register offset %#x is outside of mapped gpio memory", offset)
+	}
+	binary.LittleEndian.PutUint32(b.boardPinCtrl.VPage[offset:offset+4], value)
+	return nil
+}
@@ -6,7 +6,9 @@ package pi5
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,6 +20,7 @@ import (
 	pb "go.viam.com/api/component/board/v1"
 	"go.viam.com/rdk/components/board"
 	gl "go.viam.com/rdk/components/board/genericlinux"
+	"go.viam.com/rdk/components/board/genericlinux/buses"
 	"go.viam.com/rdk/grpc"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
@@ -35,9 +38,13 @@ const (
 	pullUpMode   = 0x8
 )
 
+// boardGPIOMappings are the gpio mappings of the board we are running on, found during init.
+var boardGPIOMappings map[string]gl.GPIOBoardMapping
+
 func init() {
 	logger := logging.NewLogger("pi5.init")
-	gpioMappings, err := gl.GetGPIOBoardMappings(Model.Name, boardInfoMappings, logger)
+	var err error
+	boardGPIOMappings, err = gl.GetGPIOBoardMappings(Model.Name, boardInfoMappings, logger)
 	var noBoardErr gl.NoBoardFoundError
 	if errors.As(err, &noBoardErr) {
 		logger.Debugw("Error getting raspi5 GPIO board mapping", "error", err)
@@ -53,11 +60,17 @@ func init() {
 				conf resource.Config,
 				logger logging.Logger,
 			) (board.Board, error) {
-				return newBoard(ctx, conf, gpioMappings, logger, false)
+				return NewBoard(ctx, conf, logger)
 			},
 		})
 }
 
+// NewBoard creates a board using the RP1 pinctrl backend. Other models use it to hand off to this
+// backend when they detect they are running on a Pi 5.
+func NewBoard(ctx context.Context, conf resource.Config, logger logging.Logger) (board.Board, error) {
+	return newBoard(ctx, conf, boardGPIOMappings, logger, false)
+}
+
 type pinctrlpi5 struct {
 	resource.Named
 	mu sync.Mutex
@@ -66,10 +79,15 @@ type pinctrlpi5 struct {
 	logger       logging.Logger
 
 	gpios            map[uint]*pinctrl.GPIOPin
-	interrupts       map[uint]*pinctrl.DigitalInterrupt
+	interrupts       map[uint]*digitalInterrupt
 	userDefinedNames map[string]uint // user defined pin names that map to a line/boardcom
 	pinConfigs       []rpiutils.PinConfig
 
+	tickQueue       rpiutils.TickQueueConfig              // the default queue of a StreamTicks client
+	tickSubscribers map[*rpiutils.TickSubscriber]struct{} // the queues of the StreamTicks clients
+	eventClock      eventClock                            // converts the timestamps of interrupts to Unix time
+	totalizers      *rpiutils.Totalizers                  // the persisted counts of the interrupts
+
 	boardPinCtrl pinctrl.Pinctrl
 
 	cancelCtx               context.Context
@@ -77,6 +95,8 @@ type pinctrlpi5 struct {
 	activeBackgroundWorkers sync.WaitGroup
 
 	pulls map[int]byte // mapping of gpio pin to pull up/down
+
+	i2cBuses map[string]buses.I2C
 }
 
 // newBoard is the constructor for a Board.
@@ -88,13 +108,12 @@ func newBoard(
 	testingMode bool,
 ) (board.Board, error) {
 	var err error
-	piModel, err := os.ReadFile("/proc/device-tree/model")
+	hw, err := rpiutils.DetectPiHardware()
 	if err != nil {
 		logger.Errorw("Cannot determine raspberry pi model", "error", err)
 	}
-	isPi5 := strings.Contains(string(piModel), "Raspberry Pi 5")
-	// ensure that we are a pi5 when not running tests
-	if !isPi5 && !testingMode {
+	// ensure that we are a pi5 (or another RP1 based pi) when not running tests
+	if !hw.UsesRP1() && !testingMode {
 		return nil, rpiutils.WrongModelErr(conf.Name)
 	}
 
@@ -103,6 +122,15 @@ func newBoard(
 		logger.Warnf("%v", err)
 	}
 
+	cfg, err := resource.NativeConfig[*rpiutils.Config](conf)
+	if err != nil {
+		return nil, err
+	}
+	totalizers, err := rpiutils.LoadTotalizers(conf.Name, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
 	cancelCtx, cancelFunc := context.WithCancel(context.Background())
 
 	b := &pinctrlpi5{
@@ -114,7 +142,10 @@ func newBoard(
 		cancelFunc:   cancelFunc,
 
 		gpios:      map[uint]*pinctrl.GPIOPin{},
-		interrupts: map[uint]*pinctrl.DigitalInterrupt{},
+		interrupts: map[uint]*digitalInterrupt{},
+
+		tickSubscribers: map[*rpiutils.TickSubscriber]struct{}{},
+		totalizers:      totalizers,
 
 		pulls: map[int]byte{},
 	}
@@ -140,10 +171,24 @@ func newBoard(
 		b.gpios[bcom] = b.boardPinCtrl.CreateGpioPin(mapping, rpiutils.DefaultPWMFreqHz)
 	}
 
+	if err := b.eventClock.sync(); err != nil {
+		return nil, err
+	}
 	if err := b.Reconfigure(ctx, nil, conf); err != nil {
 		return nil, err
 	}
 
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(b.syncEventClock, b.activeBackgroundWorkers.Done)
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() {
+		totalizers.CheckpointEvery(cancelCtx, rpiutils.TotalizerCheckpointInterval, func() []*rpiutils.BasicDigitalInterrupt {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			return b.basicInterrupts()
+		}, logger)
+	}, b.activeBackgroundWorkers.Done)
+
 	return b, nil
 }
 
@@ -178,12 +223,32 @@ func (b *pinctrlpi5) Reconfigure(
 	if err := b.reconfigureInterrupts(newConf); err != nil {
 		return err
 	}
+	if err := b.reconfigureAltFunctions(newConf); err != nil {
+		return err
+	}
+	if err := b.reconfigureClocks(newConf); err != nil {
+		return err
+	}
+	if err := b.reconfigureI2Cs(newConf); err != nil {
+		return err
+	}
+
+	if len(newConf.SoftUARTs) > 0 {
+		b.logger.Warn("soft_uarts are not supported on the Pi 5 and will be ignored")
+	}
+	if len(newConf.SoftSPIs) > 0 {
+		b.logger.Warn("soft_spis are not supported on the Pi 5 and will be ignored")
+	}
+	if newConf.PigpiodHost != "" || newConf.PigpiodPort != 0 || !newConf.Pigpiod.Equals(rpiutils.PigpiodConfig{}) {
+		b.logger.Warn("pigpiod_host, pigpiod_port and pigpiod are not supported on the Pi 5 and will be ignored")
+	}
 
 	b.configureI2C(newConf)
 
 	b.configureBT(newConf)
 
 	b.pinConfigs = newConf.Pins
+	b.tickQueue = newConf.TickQueue
 
 	return nil
 }
@@ -219,6 +284,7 @@ func (b *pinctrlpi5) reconfigureInterrupts(newConf *rpiutils.Config) error {
 		// this actually removes the interrupt
 		interrupt, ok := b.interrupts[bcom]
 		if ok {
+			b.totalizers.Record(interrupt.BasicDigitalInterrupt)
 			if err := interrupt.Close(); err != nil {
 				return err
 			}
@@ -228,12 +294,18 @@ func (b *pinctrlpi5) reconfigureInterrupts(newConf *rpiutils.Config) error {
 		// add back the gpio pin to make it available to the user
 		b.gpios[bcom] = b.boardPinCtrl.CreateGpioPin(b.gpioMappings[oldConfig.Pin], rpiutils.DefaultPWMFreqHz)
 	}
-	// add any new interrupts. DigitalInterruptByName will create the interrupt only if we are not already managing it.
+	// add any new interrupts, and update the edge and debounce of the ones we are already managing
 	for _, newConfig := range newConf.Pins {
 		if newConfig.Type != rpiutils.PinInterrupt {
 			continue
 		}
-		if _, err := b.digitalInterruptByName(newConfig.Name, newConfig.DebounceMS); err != nil {
+		if interrupt, ok := b.interrupts[uint(b.gpioMappings[newConfig.Pin].GPIO)]; ok {
+			if err := interrupt.reconfigure(newConfig); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := b.digitalInterruptByName(newConfig); err != nil {
 			return err
 		}
 	}
@@ -289,17 +361,14 @@ func (b *pinctrlpi5) reconfigurePullUpPullDowns(newConf *rpiutils.Config) error
 
 // setPull is a helper function to access memory to set a pull up/pull down resisitor on a pin.
 func (b *pinctrlpi5) setPulls() {
-	// offset to the pads address space in /dev/gpiomem0
 	// all gpio pins are in bank0
-	PadsBank0Offset := 0x00020000
-
 	for pin, mode := range b.pulls {
 		// each pad has 4 header bytes + 4 bytes of memory for each gpio pin
 		pinOffsetBytes := 4 + 4*pin
 
 		// only the 5th and 6th bits of the register are used to set pull up/down
 		// reset the register then set the mode
-		b.boardPinCtrl.VPage[PadsBank0Offset+pinOffsetBytes] = (b.boardPinCtrl.VPage[PadsBank0Offset+pinOffsetBytes] & 0xf3) | mode
+		b.boardPinCtrl.VPage[padsBank0Offset+pinOffsetBytes] = (b.boardPinCtrl.VPage[padsBank0Offset+pinOffsetBytes] & 0xf3) | mode
 	}
 }
 
@@ -308,8 +377,10 @@ func (b *pinctrlpi5) AnalogByName(name string) (board.Analog, error) {
 	return nil, errors.New("analogs not supported")
 }
 
-// the implementation of digitalInterruptByName. The board mutex should be locked before calling this.
-func (b *pinctrlpi5) digitalInterruptByName(name string, debounceMilliSeconds int) (board.DigitalInterrupt, error) {
+// the implementation of digitalInterruptByName, which creates the interrupt named by cfg if it does not
+// exist yet. The board mutex should be locked before calling this.
+func (b *pinctrlpi5) digitalInterruptByName(cfg rpiutils.PinConfig) (board.DigitalInterrupt, error) {
+	name := cfg.Name
 	// first check if the pinName is a user defined name
 	bcom, ok := b.userDefinedNames[name]
 	if !ok {
@@ -336,26 +407,21 @@ func (b *pinctrlpi5) digitalInterruptByName(name string, debounceMilliSeconds in
 		return nil, err
 	}
 
-	hardwareName := ""
 	var pinMapping gl.GPIOBoardMapping
 	// When creating a new interrupt we need to pass in the genericlinux pin mapping.
 	// Unfortunately with the bcom logic it ended up hard to track the generic linux pinmapping with the bcom number
 	// to workaround this we have to run through all of the pinmappings to find which mapping is actually the requested version
-	for newName, mapping := range b.gpioMappings {
+	for _, mapping := range b.gpioMappings {
 		if mapping.GPIO == int(bcom) {
-			hardwareName = newName
 			pinMapping = mapping
 		}
 	}
 
-	defaultInterruptConfig := board.DigitalInterruptConfig{
-		Name: hardwareName,
-		Pin:  hardwareName,
-	}
-	interrupt, err := b.boardPinCtrl.NewDigitalInterrupt(defaultInterruptConfig, pinMapping, debounceMilliSeconds, nil)
+	interrupt, err := newDigitalInterrupt(cfg, pinMapping, &b.eventClock)
 	if err != nil {
 		return nil, err
 	}
+	b.totalizers.Restore(interrupt.BasicDigitalInterrupt)
 
 	delete(b.gpios, bcom)
 	b.interrupts[bcom] = interrupt
@@ -366,7 +432,7 @@ func (b *pinctrlpi5) digitalInterruptByName(name string, debounceMilliSeconds in
 func (b *pinctrlpi5) DigitalInterruptByName(name string) (board.DigitalInterrupt, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	return b.digitalInterruptByName(name, 0)
+	return b.digitalInterruptByName(rpiutils.PinConfig{Name: name, Pin: name, Type: rpiutils.PinInterrupt})
 }
 
 // AnalogNames returns the names of all known analog pins.
@@ -416,33 +482,86 @@ func (b *pinctrlpi5) SetPowerMode(
 	return grpc.UnimplementedError
 }
 
+// DoCommand runs the board specific command named by the "command" key.
+func (b *pinctrlpi5) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	switch cmd[rpiutils.DoCommandKey] {
+	case rpiutils.PinStateCommand:
+		return b.pinStateCommand(cmd)
+	case rpiutils.SetClockFrequencyCommand:
+		return nil, errors.New("changing the clock frequency is not supported on the Pi 5, " +
+			"its clocks run at the frequency configured in the firmware")
+	case rpiutils.WatchdogTimeoutsCommand:
+		return b.watchdogTimeoutsCommand(cmd)
+	case rpiutils.MeasureCommand:
+		return b.measureCommand(cmd)
+	case rpiutils.ResetCounterCommand:
+		return b.resetCounterCommand(cmd)
+	case rpiutils.TickHistoryCommand:
+		return b.tickHistoryCommand(cmd)
+	case rpiutils.TickQueueStatsCommand:
+		return b.tickQueueStatsCommand(), nil
+	case rpiutils.PigpiodHealthCommand:
+		return nil, errors.New("the Pi 5 does not use pigpiod")
+	case rpiutils.I2CTransactionCommand:
+		return b.i2cTransactionCommand(ctx, cmd)
+	default:
+		return nil, fmt.Errorf("unknown command %v", cmd[rpiutils.DoCommandKey])
+	}
+}
+
+// tickQueueStatsCommand handles the tick_queue_stats DoCommand.
+func (b *pinctrlpi5) tickQueueStatsCommand() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return rpiutils.TickQueueStatsResult(slices.Collect(maps.Keys(b.tickSubscribers)))
+}
+
 // StreamTicks starts a stream of digital interrupt ticks.
 func (b *pinctrlpi5) StreamTicks(ctx context.Context, interrupts []board.DigitalInterrupt, ch chan board.Tick,
 	extra map[string]interface{},
 ) error {
-	var rawInterrupts []*pinctrl.DigitalInterrupt
+	var rawInterrupts []*digitalInterrupt
 	for _, i := range interrupts {
-		raw, ok := i.(*pinctrl.DigitalInterrupt)
+		raw, ok := i.(*digitalInterrupt)
 		if !ok {
 			return errors.New("cannot stream ticks to an interrupt not associated with this board")
 		}
 		rawInterrupts = append(rawInterrupts, raw)
 	}
 
+	b.mu.Lock()
+	queueConfig, err := rpiutils.TickQueueConfigFromExtra(b.tickQueue, extra)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(rawInterrupts))
+	for _, i := range rawInterrupts {
+		names = append(names, i.Name())
+	}
+	subscriber := rpiutils.NewTickSubscriber(ch, queueConfig, names)
 	for _, i := range rawInterrupts {
-		i.AddChannel(ch)
+		rpiutils.AddSubscriber(i.BasicDigitalInterrupt, subscriber)
 	}
+	b.mu.Lock()
+	b.tickSubscribers[subscriber] = struct{}{}
+	b.mu.Unlock()
 
 	b.activeBackgroundWorkers.Add(1)
 	utils.ManagedGo(func() {
-		// Wait until it's time to shut down then remove callbacks.
+		// Wait until it's time to shut down then remove the subscriber. It is closed first, so an
+		// interrupt blocked on its queue lets go of the interrupt.
 		select {
 		case <-ctx.Done():
 		case <-b.cancelCtx.Done():
 		}
+		subscriber.Close()
 		for _, i := range rawInterrupts {
-			i.RemoveChannel(ch)
+			rpiutils.RemoveSubscriber(i.BasicDigitalInterrupt, subscriber)
 		}
+		b.mu.Lock()
+		delete(b.tickSubscribers, subscriber)
+		b.mu.Unlock()
 	}, b.activeBackgroundWorkers.Done)
 
 	return nil
@@ -699,6 +818,7 @@ func (b *pinctrlpi5) Close(ctx context.Context) error {
 	b.mu.Unlock()
 	b.activeBackgroundWorkers.Wait()
 
+	err = b.totalizers.Checkpoint(b.basicInterrupts())
 	for _, pin := range b.gpios {
 		err = multierr.Combine(err, pin.Close())
 	}
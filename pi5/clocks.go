@@ -0,0 +1,79 @@
+//go:build linux
+
+package pi5
+
+import (
+	"github.com/pkg/errors"
+	rpiutils "raspberry-pi/utils"
+)
+
+// rp1ClockFunctions maps the header pins that can output a general purpose clock to the RP1
+// function select that routes the clock to them.
+var rp1ClockFunctions = map[uint]uint32{
+	4:  0, // a0: GPCLK0
+	5:  0, // a0: GPCLK1
+	6:  0, // a0: GPCLK2
+	20: 3, // a3: GPCLK0
+	21: 3, // a3: GPCLK1
+}
+
+// reconfigureClocks routes the RP1 general purpose clocks to every pin configured as `clock`. Pins
+// that were previously configured as `clock` but no longer are get returned to the SYS_RIO function
+// and become available as GPIOs again.
+//
+// The RP1 clock generators are not part of the gpio memory we map, so the clock runs at whatever
+// frequency the firmware configured, e.g. with a `dtoverlay` in /boot/firmware/config.txt.
+func (b *pinctrlpi5) reconfigureClocks(newConf *rpiutils.Config) error {
+	newClocks := map[uint]rpiutils.PinConfig{}
+	for _, newConfig := range newConf.Pins {
+		if newConfig.Type != rpiutils.PinClock {
+			continue
+		}
+		bcom, ok := rpiutils.BroadcomPinFromHardwareLabel(newConfig.Pin)
+		if !ok {
+			return errors.Errorf("cannot find GPIO for unknown pin: %s", newConfig.Pin)
+		}
+		newClocks[bcom] = newConfig
+	}
+
+	for _, oldConfig := range b.pinConfigs {
+		if oldConfig.Type != rpiutils.PinClock {
+			continue
+		}
+		bcom, ok := rpiutils.BroadcomPinFromHardwareLabel(oldConfig.Pin)
+		if !ok {
+			return errors.Errorf("cannot find GPIO for unknown pin: %s", oldConfig.Name)
+		}
+		if _, ok := newClocks[bcom]; ok {
+			continue
+		}
+		if err := b.setFunction(bcom, funcSelSysRIO); err != nil {
+			return err
+		}
+		// add back the gpio pin to make it available to the user
+		b.gpios[bcom] = b.boardPinCtrl.CreateGpioPin(b.gpioMappings[oldConfig.Pin], rpiutils.DefaultPWMFreqHz)
+	}
+
+	for bcom, clockConfig := range newClocks {
+		funcSel, ok := rp1ClockFunctions[bcom]
+		if !ok {
+			return errors.Errorf("pin %s does not have a general purpose clock", clockConfig.Name)
+		}
+		// the pin is driven by the clock now, so stop managing it as a gpio
+		if gpio, ok := b.gpios[bcom]; ok {
+			if err := gpio.Close(); err != nil {
+				return err
+			}
+			delete(b.gpios, bcom)
+		}
+		if err := b.setFunction(bcom, funcSel); err != nil {
+			return err
+		}
+		// frequency_hz is optional on the Pi 5, and only set by configs shared with Pi 0-4 boards
+		if clockConfig.FrequencyHz != 0 {
+			b.logger.Warnf("frequency_hz of clock pin %s is not applied on the Pi 5, "+
+				"its clock runs at the frequency configured in the firmware", clockConfig.Name)
+		}
+	}
+	return nil
+}
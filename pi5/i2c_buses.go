@@ -0,0 +1,55 @@
+//go:build linux
+
+package pi5
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/components/board/genericlinux/buses"
+	rpiutils "raspberry-pi/utils"
+)
+
+// reconfigureI2Cs creates the configured hardware i2c buses. Bit-banged buses need the pigpio daemon
+// and are not supported on the Pi 5.
+func (b *pinctrlpi5) reconfigureI2Cs(newConf *rpiutils.Config) error {
+	i2cBuses := map[string]buses.I2C{}
+	for _, c := range newConf.I2Cs {
+		if c.Bus == nil {
+			b.logger.Warnf("bit-banged i2c bus %s is not supported on the Pi 5 and will be ignored", c.Name)
+			continue
+		}
+		bus, err := buses.NewI2cBus(strconv.Itoa(*c.Bus))
+		if err != nil {
+			return err
+		}
+		i2cBuses[c.Name] = bus
+	}
+	b.i2cBuses = i2cBuses
+	return nil
+}
+
+// I2CByName returns the i2c bus with the given name, so other components can borrow it.
+func (b *pinctrlpi5) I2CByName(name string) (buses.I2C, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bus, ok := b.i2cBuses[name]
+	if !ok {
+		return nil, errors.Errorf("can't find i2c bus (%s)", name)
+	}
+	return bus, nil
+}
+
+// i2cTransactionCommand handles the i2c_transaction DoCommand.
+func (b *pinctrlpi5) i2cTransactionCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	name, ok := cmd["bus"].(string)
+	if !ok {
+		return nil, errors.New("expected \"bus\" to be the name of an i2c bus")
+	}
+	bus, err := b.I2CByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return rpiutils.RunI2CTransaction(ctx, bus, cmd)
+}
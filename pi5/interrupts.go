@@ -0,0 +1,260 @@
+//go:build linux
+
+package pi5
+
+/*
+	interrupts.go: Digital interrupts on the Pi 5. The pins are watched through the gpio character
+	device, which reports both edges and timestamps them in the kernel. The timestamps are converted
+	to the Unix time the Pi 0-4 tick in by the eventClock of the board. The Pi 5 has no filters like
+	those of pigpiod, so every debounce mode is applied by a rpiutils.Debouncer. The watchdog is a
+	timer of the monitor that starts over on every event, like the watchdog of pigpiod. The changes
+	are counted and ticked by a rpiutils.BasicDigitalInterrupt, following its edge and count mode,
+	like on the other boards.
+*/
+
+import (
+	"context"
+	"time"
+
+	"github.com/mkch/gpio"
+	"github.com/pkg/errors"
+	gl "go.viam.com/rdk/components/board/genericlinux"
+	"go.viam.com/utils"
+	rpiutils "raspberry-pi/utils"
+)
+
+// digitalInterrupt is a digital interrupt on a pin of the Pi 5. Like the other pins, it can be read as
+// a GPIOPin.
+type digitalInterrupt struct {
+	*rpiutils.BasicDigitalInterrupt
+	pinMapping gl.GPIOBoardMapping
+	cfg        rpiutils.PinConfig
+	clock      *eventClock
+
+	line      *gpio.LineWithEvent
+	debouncer *rpiutils.Debouncer
+	workers   *utils.StoppableWorkers
+}
+
+// newDigitalInterrupt starts watching a pin for the interrupt configured by cfg.
+func newDigitalInterrupt(cfg rpiutils.PinConfig, pinMapping gl.GPIOBoardMapping, clock *eventClock) (*digitalInterrupt, error) {
+	basic := &rpiutils.BasicDigitalInterrupt{}
+	if err := basic.Reconfigure(cfg); err != nil {
+		return nil, err
+	}
+	di := &digitalInterrupt{
+		BasicDigitalInterrupt: basic,
+		pinMapping:            pinMapping,
+		cfg:                   cfg,
+		clock:                 clock,
+	}
+	if err := di.open(); err != nil {
+		return nil, err
+	}
+	return di, nil
+}
+
+// open requests the line and starts the debouncer and the monitor.
+func (di *digitalInterrupt) open() error {
+	chip, err := gpio.OpenChip(di.pinMapping.GPIOChipDev)
+	if err != nil {
+		return err
+	}
+	defer utils.UncheckedErrorFunc(chip.Close)
+
+	line, err := chip.OpenLineWithEvents(uint32(di.pinMapping.GPIO), gpio.Input, gpio.BothEdges, "viam-interrupt")
+	if err != nil {
+		return err
+	}
+	di.line = line
+	di.workers = utils.NewBackgroundStoppableWorkers()
+	ctx := di.workers.Context()
+	period := time.Duration(di.cfg.DebounceMS) * time.Millisecond
+	di.debouncer = rpiutils.NewDebouncer(di.cfg.DebounceMode, period, func(high bool, nanoseconds uint64) {
+		//nolint:errcheck  // it only fails once the interrupt is closed
+		rpiutils.Tick(ctx, di.BasicDigitalInterrupt, high, nanoseconds)
+	})
+	if value, err := line.Value(); err == nil {
+		di.debouncer.Init(value != 0)
+	}
+	di.workers.Add(di.monitor)
+	return nil
+}
+
+// reconfigure updates the interrupt from its config. The line is requested again if the debounce or
+// the watchdog changed, which keeps the count and the ticks streams.
+func (di *digitalInterrupt) reconfigure(cfg rpiutils.PinConfig) error {
+	if err := di.BasicDigitalInterrupt.Reconfigure(cfg); err != nil {
+		return err
+	}
+	if cfg.DebounceMS == di.cfg.DebounceMS && cfg.DebounceMode == di.cfg.DebounceMode && cfg.WatchdogMS == di.cfg.WatchdogMS {
+		return nil
+	}
+	if err := di.Close(); err != nil {
+		return err
+	}
+	di.cfg = cfg
+	return di.open()
+}
+
+// monitor passes every event of the line to the debouncer until the interrupt is closed, and reports
+// every watchdog_ms the line had no events for.
+func (di *digitalInterrupt) monitor(ctx context.Context) {
+	var watchdog *time.Timer
+	var timeouts <-chan time.Time
+	watchdogPeriod := time.Duration(di.cfg.WatchdogMS) * time.Millisecond
+	if watchdogPeriod != 0 {
+		watchdog = time.NewTimer(watchdogPeriod)
+		defer watchdog.Stop()
+		timeouts = watchdog.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-di.line.Events():
+			if !ok {
+				return
+			}
+			di.debouncer.Change(event.RisingEdge, di.clock.nanoseconds(event.Time))
+			if watchdog != nil {
+				watchdog.Reset(watchdogPeriod)
+			}
+		case <-timeouts:
+			err := rpiutils.WatchdogTimeout(ctx, di.BasicDigitalInterrupt, di.debouncer.Level(), uint64(time.Now().UnixNano()))
+			if err != nil {
+				return
+			}
+			watchdog.Reset(watchdogPeriod)
+		}
+	}
+}
+
+// Close stops watching the pin.
+func (di *digitalInterrupt) Close() error {
+	di.workers.Stop()
+	di.debouncer.Stop()
+	return di.line.Close()
+}
+
+// watchdogTimeoutsCommand handles the watchdog_timeouts DoCommand.
+func (b *pinctrlpi5) watchdogTimeoutsCommand(cmd map[string]interface{}) (map[string]interface{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	interrupts, err := b.commandInterrupts(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return rpiutils.WatchdogTimeoutsResult(interrupts), nil
+}
+
+// measureCommand handles the measure DoCommand.
+func (b *pinctrlpi5) measureCommand(cmd map[string]interface{}) (map[string]interface{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	interrupts, err := b.commandInterrupts(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return rpiutils.MeasurementsResult(interrupts), nil
+}
+
+// tickHistoryCommand handles the tick_history DoCommand, which returns the ticks after the optional
+// "since".
+func (b *pinctrlpi5) tickHistoryCommand(cmd map[string]interface{}) (map[string]interface{}, error) {
+	since, err := rpiutils.SinceFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	interrupts, err := b.commandInterrupts(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return rpiutils.TickHistoryResult(interrupts, since), nil
+}
+
+// resetCounterCommand handles the reset_counter DoCommand, which sets the values of the interrupts to
+// the optional "preset", or 0.
+func (b *pinctrlpi5) resetCounterCommand(cmd map[string]interface{}) (map[string]interface{}, error) {
+	preset, err := rpiutils.PresetFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	interrupts, err := b.commandInterrupts(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return rpiutils.ResetCounterResult(interrupts, preset), nil
+}
+
+// basicInterrupts returns the interrupts of the board.
+// The board mutex should be locked before calling this.
+func (b *pinctrlpi5) basicInterrupts() []*rpiutils.BasicDigitalInterrupt {
+	interrupts := make([]*rpiutils.BasicDigitalInterrupt, 0, len(b.interrupts))
+	for _, interrupt := range b.interrupts {
+		interrupts = append(interrupts, interrupt.BasicDigitalInterrupt)
+	}
+	return interrupts
+}
+
+// commandInterrupts returns the interrupt selected by the optional "pin" argument of a DoCommand, or
+// every interrupt if no pin is given. The pin is the name of the interrupt or the label of its pin.
+// The board mutex should be locked before calling this.
+func (b *pinctrlpi5) commandInterrupts(cmd map[string]interface{}) ([]*rpiutils.BasicDigitalInterrupt, error) {
+	pin, err := rpiutils.PinFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var interrupts []*rpiutils.BasicDigitalInterrupt
+	for bcom, interrupt := range b.interrupts {
+		if pin != "" && interrupt.Name() != pin {
+			if labelBcom, ok := rpiutils.BroadcomPinFromHardwareLabel(pin); !ok || labelBcom != bcom {
+				continue
+			}
+		}
+		interrupts = append(interrupts, interrupt.BasicDigitalInterrupt)
+	}
+	if pin != "" && len(interrupts) == 0 {
+		return nil, errors.Errorf("interrupt %s does not exist", pin)
+	}
+	return interrupts, nil
+}
+
+// Get reads the current value of the interrupt pin.
+func (di *digitalInterrupt) Get(ctx context.Context, extra map[string]interface{}) (bool, error) {
+	value, err := di.line.Value()
+	if err != nil {
+		return false, err
+	}
+	return value != 0, nil
+}
+
+// Set is unsupported, the pin of an interrupt is an input.
+func (di *digitalInterrupt) Set(ctx context.Context, high bool, extra map[string]interface{}) error {
+	return errors.New("cannot set value of a digital interrupt pin")
+}
+
+// PWM is unsupported, the pin of an interrupt is an input.
+func (di *digitalInterrupt) PWM(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	return 0, errors.New("cannot get PWM of a digital interrupt pin")
+}
+
+// SetPWM is unsupported, the pin of an interrupt is an input.
+func (di *digitalInterrupt) SetPWM(ctx context.Context, dutyCyclePct float64, extra map[string]interface{}) error {
+	return errors.New("cannot set PWM of a digital interrupt pin")
+}
+
+// PWMFreq is unsupported, the pin of an interrupt is an input.
+func (di *digitalInterrupt) PWMFreq(ctx context.Context, extra map[string]interface{}) (uint, error) {
+	return 0, errors.New("cannot get PWM freq of a digital interrupt pin")
+}
+
+// SetPWMFreq is unsupported, the pin of an interrupt is an input.
+func (di *digitalInterrupt) SetPWMFreq(ctx context.Context, freqHz uint, extra map[string]interface{}) error {
+	return errors.New("cannot set PWM freq of a digital interrupt pin")
+}
@@ -0,0 +1,147 @@
+//go:build linux
+
+package pi5
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	rpiutils "raspberry-pi/utils"
+)
+
+// Offsets into the RP1 register blocks mapped by /dev/gpiomem0. See the RP1 peripherals datasheet.
+const (
+	ioBank0Offset   = 0x00000 // per gpio STATUS and CTRL registers, 8 bytes per gpio
+	sysRIO0Offset   = 0x10000 // registered IO block used when a gpio is in the SYS_RIO function
+	padsBank0Offset = 0x20000 // per gpio pad registers, after a 4 byte voltage select header
+
+	rioOEOffset = 0x4 // output enable register within the SYS_RIO block
+
+	statusLevelBit = 23 // level of the pin as seen by the peripherals
+
+	ctrlFuncSelMask = 0x1f
+	funcSelSysRIO   = 5
+	funcSelNull     = 0x1f
+
+	padSlewFast   = 1 << 0
+	padSchmitt    = 1 << 1
+	padPullDown   = 1 << 2
+	padPullUp     = 1 << 3
+	padDriveShift = 4
+	padDriveMask  = 0x3
+	padInputEn    = 1 << 6
+	padOutputDis  = 1 << 7
+)
+
+// padDriveMA maps the pad drive field to the drive strength in milliamps.
+var padDriveMA = []int{2, 4, 8, 12}
+
+// pinStateCommand handles the pin_state DoCommand for one pin, or every header pin if no pin is given.
+func (b *pinctrlpi5) pinStateCommand(cmd map[string]interface{}) (map[string]interface{}, error) {
+	pin, err := rpiutils.PinFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var bcoms []uint
+	if pin != "" {
+		bcom, ok := b.userDefinedNames[pin]
+		if !ok {
+			bcom, ok = rpiutils.BroadcomPinFromHardwareLabel(pin)
+			if !ok {
+				return nil, errors.Errorf("cannot find GPIO for unknown pin: %s", pin)
+			}
+		}
+		bcoms = []uint{bcom}
+	} else {
+		for _, mapping := range b.gpioMappings {
+			bcoms = append(bcoms, uint(mapping.GPIO))
+		}
+		sort.Slice(bcoms, func(i, j int) bool { return bcoms[i] < bcoms[j] })
+	}
+
+	states := make([]rpiutils.PinState, 0, len(bcoms))
+	for _, bcom := range bcoms {
+		state, err := b.pinState(bcom)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return rpiutils.PinStatesToResponse(states), nil
+}
+
+// pinState decodes the live state of a gpio from the RP1 registers.
+// The board mutex should be locked before calling this.
+func (b *pinctrlpi5) pinState(bcom uint) (rpiutils.PinState, error) {
+	status, err := b.readRegister(ioBank0Offset + 8*int(bcom))
+	if err != nil {
+		return rpiutils.PinState{}, err
+	}
+	ctrl, err := b.readRegister(ioBank0Offset + 8*int(bcom) + 4)
+	if err != nil {
+		return rpiutils.PinState{}, err
+	}
+	pad, err := b.readRegister(padsBank0Offset + 4 + 4*int(bcom))
+	if err != nil {
+		return rpiutils.PinState{}, err
+	}
+
+	state := rpiutils.PinState{
+		GPIO:  bcom,
+		Level: status&(1<<statusLevelBit) != 0,
+		Pad: &rpiutils.PadState{
+			DriveMA:        padDriveMA[(pad>>padDriveShift)&padDriveMask],
+			SlewFast:       pad&padSlewFast != 0,
+			Schmitt:        pad&padSchmitt != 0,
+			InputEnabled:   pad&padInputEn != 0,
+			OutputDisabled: pad&padOutputDis != 0,
+		},
+	}
+
+	switch funcSel := ctrl & ctrlFuncSelMask; funcSel {
+	case funcSelSysRIO:
+		oe, err := b.readRegister(sysRIO0Offset + rioOEOffset)
+		if err != nil {
+			return rpiutils.PinState{}, err
+		}
+		state.Function = "input"
+		if oe&(1<<bcom) != 0 {
+			state.Function = "output"
+		}
+	case funcSelNull:
+		state.Function = "none"
+	default:
+		state.Function = fmt.Sprintf("a%d", funcSel)
+	}
+
+	switch {
+	case pad&padPullUp != 0:
+		state.Pull = rpiutils.PullUp
+	case pad&padPullDown != 0:
+		state.Pull = rpiutils.PullDown
+	default:
+		state.Pull = rpiutils.PullNone
+	}
+
+	for name, pin := range b.userDefinedNames {
+		if pin == bcom {
+			state.Name = name
+			break
+		}
+	}
+	return state, nil
+}
+
+// readRegister reads a little endian 32 bit register from the mapped gpio memory.
+func (b *pinctrlpi5) readRegister(offset int) (uint32, error) {
+	if offset < 0 || offset+4 > len(b.boardPinCtrl.VPage) {
+		return 0, fmt.Errorf("register offset %#x is outside of mapped gpio memory", offset)
+	}
+	return binary.LittleEndian.Uint32(b.boardPinCtrl.VPage[offset : offset+4]), nil
+}
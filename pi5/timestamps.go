@@ -0,0 +1,58 @@
+//go:build linux
+
+package pi5
+
+/*
+	timestamps.go: Converts the timestamps of line events to Unix time. The kernel timestamps events
+	with CLOCK_MONOTONIC, the time since boot, while the Pi 0-4 report ticks in Unix time. The offset
+	between the two clocks is measured when the board starts and again every
+	rpiutils.TickClockSyncInterval, which follows changes of the system time such as an NTP step.
+*/
+
+import (
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/unix"
+	rpiutils "raspberry-pi/utils"
+)
+
+// eventClock converts CLOCK_MONOTONIC timestamps to Unix time.
+type eventClock struct {
+	offset atomic.Int64 // Unix time minus monotonic time, in nanoseconds
+}
+
+// sync measures the offset between the monotonic clock and the system time. The monotonic clock is
+// taken to be read halfway between the two readings of the system time.
+func (c *eventClock) sync() error {
+	var monotonic unix.Timespec
+	before := time.Now()
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &monotonic); err != nil {
+		return err
+	}
+	after := time.Now()
+	c.offset.Store(before.Add(after.Sub(before)/2).UnixNano() - monotonic.Nano())
+	return nil
+}
+
+// nanoseconds returns the Unix time of a monotonic timestamp in nanoseconds.
+func (c *eventClock) nanoseconds(monotonic time.Time) uint64 {
+	return uint64(monotonic.UnixNano() + c.offset.Load())
+}
+
+// syncEventClock syncs the clock of the events every rpiutils.TickClockSyncInterval until the board
+// is closed.
+func (b *pinctrlpi5) syncEventClock() {
+	ticker := time.NewTicker(rpiutils.TickClockSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.cancelCtx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := b.eventClock.sync(); err != nil {
+			b.logger.Warnw("failed to sync the clock of interrupt events", "error", err)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package pigpio
+
+/*
+	client.go: Sends commands to the daemon. A command is the command number and three parameters as
+	little endian uint32s, followed by an extension whose length is the third parameter. The daemon
+	answers with the same 16 bytes, the third parameter replaced by the result, followed by data for
+	the commands that read some.
+*/
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	commandSize = 16
+	// commandTimeout bounds how long the daemon gets to answer a command, so a daemon that stops
+	// answering is noticed rather than hanging the board.
+	commandTimeout = 5 * time.Second
+	// dialTimeout is how long the daemon gets to accept a connection.
+	dialTimeout = 5 * time.Second
+)
+
+// Client is a connection to a pigpio daemon. A Client that is not connected, including the zero
+// value, returns UnconnectedPi from every function.
+type Client struct {
+	mu       sync.Mutex // serializes commands, since the daemon answers them in order
+	conn     net.Conn   // nil once the client is closed or a command failed to get an answer
+	addr     string     // the address of the daemon, for notifications opened with NotifyOpen
+	notifier *notifier
+}
+
+var _ Pi = (*Client)(nil)
+
+// Connect opens a command socket and a notification socket to the daemon at host:port.
+func Connect(host string, port int) (*Client, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: conn, addr: addr}
+	n, err := openNotifier(c, addr)
+	if err != nil {
+		return nil, multierr.Combine(err, conn.Close())
+	}
+	c.notifier = n
+	return c, nil
+}
+
+// Close disconnects from the daemon and stops calling callbacks.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	if conn != nil && c.notifier != nil {
+		//nolint:errcheck  // tell the daemon to free the handle; a daemon that is gone frees it anyway
+		exchange(conn, CmdNC, c.notifier.handle, 0, nil, nil)
+	}
+	c.mu.Unlock()
+
+	var err error
+	if conn != nil {
+		err = conn.Close()
+	}
+	if c.notifier != nil {
+		err = multierr.Combine(err, c.notifier.close())
+	}
+	return err
+}
+
+// command sends a command and returns its result. If rx is not nil, the data following a positive
+// result is read into it and the number of bytes read is returned instead. A connection that fails
+// to send or receive is closed, since the answers to later commands could no longer be matched up.
+func (c *Client) command(cmd, p1, p2 uint32, ext, rx []byte) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return UnconnectedPi
+	}
+	res, err := exchange(c.conn, cmd, p1, p2, ext, rx)
+	if err != nil {
+		//nolint:errcheck  // the connection is already broken
+		c.conn.Close()
+		c.conn = nil
+	}
+	return res
+}
+
+// exchange writes a command to conn and reads its answer. If the command could not be sent or its
+// answer received, the error is returned along with BadSend or BadRecv as the result. A result of
+// the daemon can have the same value, such as a tick, so only the error tells a broken connection.
+func exchange(conn net.Conn, cmd, p1, p2 uint32, ext, rx []byte) (int, error) {
+	buf := make([]byte, commandSize+len(ext))
+	binary.LittleEndian.PutUint32(buf[0:], cmd)
+	binary.LittleEndian.PutUint32(buf[4:], p1)
+	binary.LittleEndian.PutUint32(buf[8:], p2)
+	binary.LittleEndian.PutUint32(buf[12:], uint32(len(ext)))
+	copy(buf[commandSize:], ext)
+
+	if err := conn.SetDeadline(time.Now().Add(commandTimeout)); err != nil {
+		return BadSend, err
+	}
+	if _, err := conn.Write(buf); err != nil {
+		return BadSend, err
+	}
+	if _, err := io.ReadFull(conn, buf[:commandSize]); err != nil {
+		return BadRecv, err
+	}
+	res := int(int32(binary.LittleEndian.Uint32(buf[12:])))
+	if rx == nil || res <= 0 {
+		return res, nil
+	}
+
+	// the daemon sends everything it read, keep what fits and drop the rest
+	data := make([]byte, res)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return BadRecv, err
+	}
+	return copy(rx, data), nil
+}
+
+// uint32s encodes values as the extension of a command.
+func uint32s(values ...uint32) []byte {
+	buf := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(buf[4*i:], v)
+	}
+	return buf
+}
+
+// SetMode sets the mode of a gpio.
+func (c *Client) SetMode(gpio, mode uint) int {
+	return c.command(CmdModes, uint32(gpio), uint32(mode), nil, nil)
+}
+
+// GetMode returns the mode of a gpio.
+func (c *Client) GetMode(gpio uint) int {
+	return c.command(CmdModeg, uint32(gpio), 0, nil, nil)
+}
+
+// SetPullUpDown sets the pull of a gpio.
+func (c *Client) SetPullUpDown(gpio, pud uint) int {
+	return c.command(CmdPud, uint32(gpio), uint32(pud), nil, nil)
+}
+
+// SetGlitchFilter sets the glitch filter of a gpio.
+func (c *Client) SetGlitchFilter(gpio, steadyUS uint) int {
+	return c.command(CmdFG, uint32(gpio), uint32(steadyUS), nil, nil)
+}
+
+// SetNoiseFilter sets the noise filter of a gpio.
+func (c *Client) SetNoiseFilter(gpio, steadyUS, activeUS uint) int {
+	return c.command(CmdFN, uint32(gpio), uint32(steadyUS), uint32s(uint32(activeUS)), nil)
+}
+
+// SetWatchdog sets the watchdog of a gpio.
+func (c *Client) SetWatchdog(gpio, timeoutMS uint) int {
+	return c.command(CmdWDOG, uint32(gpio), uint32(timeoutMS), nil, nil)
+}
+
+// Read returns the level of a gpio.
+func (c *Client) Read(gpio uint) int {
+	return c.command(CmdRead, uint32(gpio), 0, nil, nil)
+}
+
+// Write sets a gpio to an output and sets its level.
+func (c *Client) Write(gpio, level uint) int {
+	return c.command(CmdWrite, uint32(gpio), uint32(level), nil, nil)
+}
+
+// SetPWMDutycycle starts software pwm on a gpio with a duty cycle out of its pwm range.
+func (c *Client) SetPWMDutycycle(gpio, duty uint) int {
+	return c.command(CmdPWM, uint32(gpio), uint32(duty), nil, nil)
+}
+
+// GetPWMDutycycle returns the pwm duty cycle of a gpio.
+func (c *Client) GetPWMDutycycle(gpio uint) int {
+	return c.command(CmdGDC, uint32(gpio), 0, nil, nil)
+}
+
+// SetPWMRange sets the range of the duty cycle of a gpio, and returns the real range the hardware
+// uses at the current frequency.
+func (c *Client) SetPWMRange(gpio, pwmRange uint) int {
+	return c.command(CmdPRS, uint32(gpio), uint32(pwmRange), nil, nil)
+}
+
+// GetPWMRange returns the range of the duty cycle of a gpio.
+func (c *Client) GetPWMRange(gpio uint) int {
+	return c.command(CmdPRG, uint32(gpio), 0, nil, nil)
+}
+
+// GetPWMRealRange returns the real range the hardware uses for the pwm of a gpio.
+func (c *Client) GetPWMRealRange(gpio uint) int {
+	return c.command(CmdPRRG, uint32(gpio), 0, nil, nil)
+}
+
+// SetPWMFrequency sets the software pwm frequency of a gpio to the closest one the daemon supports,
+// and returns that frequency.
+func (c *Client) SetPWMFrequency(gpio, freqHz uint) int {
+	return c.command(CmdPFS, uint32(gpio), uint32(freqHz), nil, nil)
+}
+
+// GetPWMFrequency returns the pwm frequency of a gpio.
+func (c *Client) GetPWMFrequency(gpio uint) int {
+	return c.command(CmdPFG, uint32(gpio), 0, nil, nil)
+}
+
+// HardwarePWM starts the pwm peripheral on a gpio, with a duty cycle in parts per million. A
+// frequency of 0 stops it.
+func (c *Client) HardwarePWM(gpio, freqHz uint, dutyPPM uint32) int {
+	return c.command(CmdHP, uint32(gpio), uint32(freqHz), uint32s(dutyPPM), nil)
+}
+
+// HardwareClock starts a general purpose clock on a gpio. A frequency of 0 stops it.
+func (c *Client) HardwareClock(gpio, freqHz uint) int {
+	return c.command(CmdHC, uint32(gpio), uint32(freqHz), nil, nil)
+}
+
+// CurrentTick returns the microseconds since the daemon started, which wrap around every 72 minutes.
+func (c *Client) CurrentTick() uint32 {
+	return uint32(c.command(CmdTick, 0, 0, nil, nil))
+}
+
+// PigpioVersion returns the version of the daemon.
+func (c *Client) PigpioVersion() int {
+	return c.command(CmdPigpv, 0, 0, nil, nil)
+}
+
+// WaveClear deletes every wave.
+func (c *Client) WaveClear() int {
+	return c.command(CmdWVClr, 0, 0, nil, nil)
+}
+
+// WaveAddNew starts a new wave.
+func (c *Client) WaveAddNew() int {
+	return c.command(CmdWVNew, 0, 0, nil, nil)
+}
+
+// WaveAddGeneric adds pulses to the wave being built, and returns the number of pulses in it.
+func (c *Client) WaveAddGeneric(pulses []Pulse) int {
+	ext := make([]uint32, 0, 3*len(pulses))
+	for _, p := range pulses {
+		ext = append(ext, p.On, p.Off, p.DelayUS)
+	}
+	return c.command(CmdWVAG, 0, 0, uint32s(ext...), nil)
+}
+
+// WaveAddSerial adds serial data to the wave being built, starting offsetUS after the start of the
+// wave, and returns the number of pulses in it.
+func (c *Client) WaveAddSerial(gpio, baud, dataBits, stopHalfBits, offsetUS uint, data []byte) int {
+	ext := append(uint32s(uint32(dataBits), uint32(stopHalfBits), uint32(offsetUS)), data...)
+	return c.command(CmdWVAS, uint32(gpio), uint32(baud), ext, nil)
+}
+
+// WaveCreate turns the wave being built into a wave that can be sent, and returns its id.
+func (c *Client) WaveCreate() int {
+	return c.command(CmdWVCre, 0, 0, nil, nil)
+}
+
+// WaveDelete deletes a wave.
+func (c *Client) WaveDelete(id uint) int {
+	return c.command(CmdWVDel, uint32(id), 0, nil, nil)
+}
+
+// WaveSendOnce sends a wave once, and returns the number of dma control blocks it uses.
+func (c *Client) WaveSendOnce(id uint) int {
+	return c.command(CmdWVTx, uint32(id), 0, nil, nil)
+}
+
+// WaveSendRepeat sends a wave until it is stopped, and returns the number of dma control blocks it uses.
+func (c *Client) WaveSendRepeat(id uint) int {
+	return c.command(CmdWVTxR, uint32(id), 0, nil, nil)
+}
+
+// WaveChain sends a chain of waves encoded as described by wave_chain.
+func (c *Client) WaveChain(buf []byte) int {
+	return c.command(CmdWVCha, 0, 0, buf, nil)
+}
+
+// WaveTxBusy returns 1 if a wave is being sent, and 0 otherwise.
+func (c *Client) WaveTxBusy() int {
+	return c.command(CmdWVBsy, 0, 0, nil, nil)
+}
+
+// WaveTxStop stops sending waves.
+func (c *Client) WaveTxStop() int {
+	return c.command(CmdWVHlt, 0, 0, nil, nil)
+}
+
+// BBSerialReadOpen starts receiving bit banged serial data on a gpio.
+func (c *Client) BBSerialReadOpen(gpio, baud, dataBits uint) int {
+	return c.command(CmdSLRO, uint32(gpio), uint32(baud), uint32s(uint32(dataBits)), nil)
+}
+
+// BBSerialRead reads the serial data received on a gpio into buf, and returns the number of bytes read.
+func (c *Client) BBSerialRead(gpio uint, buf []byte) int {
+	return c.command(CmdSLR, uint32(gpio), uint32(len(buf)), nil, buf)
+}
+
+// BBSerialReadClose stops receiving serial data on a gpio.
+func (c *Client) BBSerialReadClose(gpio uint) int {
+	return c.command(CmdSLRC, uint32(gpio), 0, nil, nil)
+}
+
+// I2COpen opens a device on a hardware i2c bus, and returns its handle.
+func (c *Client) I2COpen(bus, addr, flags uint) int {
+	return c.command(CmdI2CO, uint32(bus), uint32(addr), uint32s(uint32(flags)), nil)
+}
+
+// I2CClose closes a device opened with I2COpen.
+func (c *Client) I2CClose(handle uint) int {
+	return c.command(CmdI2CC, uint32(handle), 0, nil, nil)
+}
+
+// I2CReadDevice reads len(buf) bytes from a device, and returns the number of bytes read.
+func (c *Client) I2CReadDevice(handle uint, buf []byte) int {
+	return c.command(CmdI2CRD, uint32(handle), uint32(len(buf)), nil, buf)
+}
+
+// I2CWriteDevice writes data to a device.
+func (c *Client) I2CWriteDevice(handle uint, data []byte) int {
+	return c.command(CmdI2CWD, uint32(handle), 0, data, nil)
+}
+
+// I2CZip runs the i2c commands in `in` on a device, and returns the number of bytes read into out.
+func (c *Client) I2CZip(handle uint, in, out []byte) int {
+	return c.command(CmdI2CZ, uint32(handle), 0, in, out)
+}
+
+// BBI2COpen starts a bit banged i2c bus on a pair of gpios.
+func (c *Client) BBI2COpen(sda, scl, baud uint) int {
+	return c.command(CmdBI2CO, uint32(sda), uint32(scl), uint32s(uint32(baud)), nil)
+}
+
+// BBI2CClose stops the bit banged i2c bus on sda.
+func (c *Client) BBI2CClose(sda uint) int {
+	return c.command(CmdBI2CC, uint32(sda), 0, nil, nil)
+}
+
+// BBI2CZip runs the i2c commands in `in` on the bit banged bus on sda, and returns the number of
+// bytes read into out.
+func (c *Client) BBI2CZip(sda uint, in, out []byte) int {
+	return c.command(CmdBI2CZ, uint32(sda), 0, in, out)
+}
+
+// BBSPIOpen starts a bit banged spi bus for the chip select gpio.
+func (c *Client) BBSPIOpen(cs, miso, mosi, sclk, baud, flags uint) int {
+	return c.command(CmdBSPIO, uint32(cs), 0,
+		uint32s(uint32(miso), uint32(mosi), uint32(sclk), uint32(baud), uint32(flags)), nil)
+}
+
+// BBSPIClose stops the bit banged spi bus for the chip select gpio.
+func (c *Client) BBSPIClose(cs uint) int {
+	return c.command(CmdBSPIC, uint32(cs), 0, nil, nil)
+}
+
+// BBSPIXfer sends tx to the device selected by cs while reading rx from it, and returns the number
+// of bytes transferred.
+func (c *Client) BBSPIXfer(cs uint, tx, rx []byte) int {
+	return c.command(CmdBSPIX, uint32(cs), 0, tx, rx)
+}
@@ -0,0 +1,49 @@
+package pigpio
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+// answer reads a command from conn in the background and answers it with res. The returned channel
+// gets the error of the exchange.
+func answer(conn net.Conn, res int32) chan error {
+	errs := make(chan error, 1)
+	go func() {
+		buf := make([]byte, commandSize)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			errs <- err
+			return
+		}
+		binary.LittleEndian.PutUint32(buf[12:], uint32(res))
+		_, err := conn.Write(buf)
+		errs <- err
+	}()
+	return errs
+}
+
+func TestResultsLikeIOErrorsKeepTheConnection(t *testing.T) {
+	client, daemon := net.Pipe()
+	defer daemon.Close()
+	c := &Client{conn: client}
+
+	// ticks can take the values of the result codes of a broken connection
+	errs := answer(daemon, BadRecv)
+	test.That(t, int32(c.CurrentTick()), test.ShouldEqual, BadRecv)
+	test.That(t, <-errs, test.ShouldBeNil)
+	errs = answer(daemon, BadSend)
+	test.That(t, int32(c.CurrentTick()), test.ShouldEqual, BadSend)
+	test.That(t, <-errs, test.ShouldBeNil)
+	errs = answer(daemon, 79)
+	test.That(t, c.PigpioVersion(), test.ShouldEqual, 79)
+	test.That(t, <-errs, test.ShouldBeNil)
+
+	// a daemon that went away closes the connection
+	test.That(t, daemon.Close(), test.ShouldBeNil)
+	test.That(t, c.PigpioVersion(), test.ShouldBeIn, BadSend, BadRecv)
+	test.That(t, c.PigpioVersion(), test.ShouldEqual, UnconnectedPi)
+}
@@ -0,0 +1,141 @@
+package pigpio_test
+
+import (
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+	"raspberry-pi/pigpio"
+	"raspberry-pi/pigpio/fakepigpiod"
+)
+
+func TestClient(t *testing.T) {
+	daemon, err := fakepigpiod.New()
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, daemon.Close(), test.ShouldBeNil)
+	}()
+	endpoint := daemon.Endpoint()
+
+	pi, err := pigpio.Connect(endpoint.Host, endpoint.Port)
+	test.That(t, err, test.ShouldBeNil)
+
+	t.Run("gpio", func(t *testing.T) {
+		test.That(t, pi.SetMode(4, pigpio.Output), test.ShouldEqual, 0)
+		test.That(t, pi.GetMode(4), test.ShouldEqual, pigpio.Output)
+		test.That(t, pi.Write(4, 1), test.ShouldEqual, 0)
+		test.That(t, pi.Read(4), test.ShouldEqual, 1)
+		test.That(t, pi.SetMode(100, pigpio.Output), test.ShouldEqual, pigpio.BadGPIO)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		test.That(t, pi.SetGlitchFilter(22, 5000), test.ShouldEqual, 0)
+		test.That(t, pi.SetNoiseFilter(22, 2000, 100), test.ShouldEqual, 0)
+		glitchUS, noiseSteadyUS, noiseActiveUS := daemon.Filters(22)
+		test.That(t, glitchUS, test.ShouldEqual, 5000)
+		test.That(t, noiseSteadyUS, test.ShouldEqual, 2000)
+		test.That(t, noiseActiveUS, test.ShouldEqual, 100)
+		test.That(t, pi.SetGlitchFilter(22, 300001), test.ShouldEqual, pigpio.BadFilter)
+		test.That(t, pi.SetNoiseFilter(40, 2000, 0), test.ShouldEqual, pigpio.BadUserGPIO)
+	})
+
+	t.Run("callbacks", func(t *testing.T) {
+		levels := make(chan uint, 10)
+		id, res := pi.Callback(17, pigpio.RisingEdge, func(gpio, level uint, _ uint32) {
+			test.That(t, gpio, test.ShouldEqual, 17)
+			levels <- level
+		})
+		test.That(t, res, test.ShouldEqual, 0)
+
+		daemon.SetLevel(17, true)
+		daemon.SetLevel(17, false)
+		daemon.SetLevel(17, true)
+		for range 2 {
+			select {
+			case level := <-levels:
+				test.That(t, level, test.ShouldEqual, 1)
+			case <-time.After(time.Second):
+				t.Fatal("callback was not called")
+			}
+		}
+
+		test.That(t, pi.CallbackCancel(id), test.ShouldEqual, 0)
+		test.That(t, pi.CallbackCancel(id), test.ShouldEqual, pigpio.CallbackNotFound)
+		daemon.SetLevel(17, false)
+		daemon.SetLevel(17, true)
+		time.Sleep(10 * time.Millisecond)
+		test.That(t, len(levels), test.ShouldEqual, 0)
+	})
+
+	t.Run("callback on a gpio that changed before it was watched", func(t *testing.T) {
+		test.That(t, pi.SetPullUpDown(24, pigpio.PudUp), test.ShouldEqual, 0)
+		levels := make(chan uint, 10)
+		id, res := pi.Callback(24, pigpio.FallingEdge, func(_, level uint, _ uint32) {
+			levels <- level
+		})
+		test.That(t, res, test.ShouldEqual, 0)
+
+		daemon.SetLevel(24, false)
+		select {
+		case level := <-levels:
+			test.That(t, level, test.ShouldEqual, 0)
+		case <-time.After(time.Second):
+			t.Fatal("callback was not called")
+		}
+		test.That(t, pi.CallbackCancel(id), test.ShouldEqual, 0)
+	})
+
+	t.Run("notification", func(t *testing.T) {
+		n, res := pi.NotifyOpen()
+		test.That(t, res, test.ShouldEqual, 0)
+		test.That(t, pi.NotifyBegin(n, 1<<25), test.ShouldEqual, 0)
+
+		// changes of gpios that are not watched are not reported
+		daemon.SetLevel(26, true)
+		for k := range 100 {
+			daemon.SetLevel(25, k%2 == 0)
+		}
+		var reports []pigpio.Report
+		batch := make([]pigpio.Report, 16)
+		for len(reports) < 100 {
+			count, err := n.Read(batch)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, count, test.ShouldBeBetweenOrEqual, 1, 16)
+			reports = append(reports, batch[:count]...)
+		}
+		for k, r := range reports {
+			test.That(t, r.Levels&(1<<25) != 0, test.ShouldEqual, k%2 == 0)
+			test.That(t, r.Levels&(1<<26), test.ShouldNotEqual, 0)
+		}
+
+		test.That(t, pi.NotifyClose(n), test.ShouldEqual, 0)
+		_, err := n.Read(batch)
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+
+	t.Run("watchdog", func(t *testing.T) {
+		levels := make(chan uint, 10)
+		id, res := pi.Callback(23, pigpio.EitherEdge, func(_, level uint, _ uint32) {
+			levels <- level
+		})
+		test.That(t, res, test.ShouldEqual, 0)
+		test.That(t, pi.SetWatchdog(23, 60001), test.ShouldEqual, pigpio.BadWDogTimeout)
+		test.That(t, pi.SetWatchdog(23, 10), test.ShouldEqual, 0)
+		test.That(t, daemon.Watchdog(23), test.ShouldEqual, 10)
+
+		for range 2 {
+			select {
+			case level := <-levels:
+				test.That(t, level, test.ShouldEqual, pigpio.Timeout)
+			case <-time.After(time.Second):
+				t.Fatal("watchdog did not fire")
+			}
+		}
+
+		test.That(t, pi.SetWatchdog(23, 0), test.ShouldEqual, 0)
+		test.That(t, pi.CallbackCancel(id), test.ShouldEqual, 0)
+	})
+
+	test.That(t, pi.Close(), test.ShouldBeNil)
+	test.That(t, pi.Read(4), test.ShouldEqual, pigpio.UnconnectedPi)
+}
@@ -0,0 +1,947 @@
+// Package fakepigpiod is an in-process stand-in for the pigpio daemon. It speaks the daemon's socket
+// interface and keeps the state of every gpio in memory, so the boards and servos can be tested on
+// machines that are not pis.
+package fakepigpiod
+
+/*
+	fakepigpiod.go: The daemon answers the commands used by the pigpio client. Gpios behave like
+	the pins of a pi with nothing connected: inputs follow their pull unless a level is driven onto
+	them with SetLevel, and outputs read back what was written. Software pwm toggles the level of its
+	gpio, so interrupts on it see the pulses. Hardware pwm, clocks, waves and buses keep their
+	settings but don't toggle any levels, and the glitch and noise filters are recorded but don't
+	filter any changes. Watchdogs fire like those of the daemon. Waves are transmitted instantly,
+	except for repeated ones, which keep the daemon busy until they are stopped. The serial data of a
+	wave is received by serial reads on the gpios wired to its gpio with Wire.
+*/
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"raspberry-pi/pigpio"
+	rpiutils "raspberry-pi/utils"
+)
+
+const (
+	numGPIOs = 54
+	// version is the pigpio version the daemon reports.
+	version = 79
+	// hardwareRevision is the revision of a pi 4 model b.
+	hardwareRevision = 0xc03111
+	defaultPWMRange  = 255
+	defaultPWMFreqHz = 800
+	hardwarePWMRange = 1e6
+)
+
+// hardwarePWMModes maps the gpios with hardware pwm to the mode that connects them to it.
+var hardwarePWMModes = map[uint]uint{12: pigpio.Alt0, 13: pigpio.Alt0, 18: pigpio.Alt5, 19: pigpio.Alt5}
+
+// clockModes maps the gpios with a general purpose clock to the mode that connects them to it.
+var clockModes = map[uint]uint{4: pigpio.Alt0, 5: pigpio.Alt0, 6: pigpio.Alt0, 20: pigpio.Alt5, 21: pigpio.Alt5}
+
+type gpio struct {
+	mode, pud, level uint
+	driven           bool // the level was set with SetLevel rather than following the pull
+
+	pwm         bool
+	pwmDuty     uint
+	pwmRange    uint
+	pwmFreqHz   uint
+	pwmTimer    *time.Timer // toggles the level of a software pwm
+	hwPWMFreqHz uint
+	hwPWMDuty   uint32
+	clockFreqHz uint
+
+	glitchUS, noiseSteadyUS, noiseActiveUS uint
+
+	watchdogMS uint
+	watchdog   *time.Timer
+}
+
+// wave is a wave of the daemon, and the serial data it sends.
+type wave struct {
+	pulses int
+	serial []serialData
+}
+
+type serialData struct {
+	bcom uint
+	data []byte
+}
+
+type notification struct {
+	conn net.Conn
+	bits uint32
+	seq  uint16
+}
+
+// Daemon is a fake pigpio daemon listening on a local port.
+type Daemon struct {
+	listener     net.Listener
+	started      time.Time
+	sampleRateUS int
+	wg           sync.WaitGroup
+
+	mu            sync.Mutex
+	conns         map[net.Conn]struct{}
+	gpios         [numGPIOs]gpio
+	notifications map[uint32]*notification
+	nextHandle    uint32
+	building      wave            // the wave being built
+	waves         map[uint]wave   // the created waves
+	waveRepeating bool            // a wave is being transmitted repeatedly
+	serialReads   map[uint][]byte // data received by the open serial reads, by gpio
+	wires         map[uint][]uint
+	i2cHandles    map[uint32]struct{}
+	bbI2CBuses    map[uint]struct{}
+	bbSPIBuses    map[uint]struct{}
+	spiDevices    map[uint]func(tx []byte) []byte // the devices answering spi transfers, by chip select
+}
+
+// New starts a daemon on a free local port, with the default sample rate of 5 microseconds.
+func New() (*Daemon, error) {
+	return NewOnPort(0)
+}
+
+// NewOnPort starts a daemon on the given local port, or on a free one if it is 0. A daemon started on
+// the port of one that was closed behaves like a pigpiod that restarted.
+func NewOnPort(port int) (*Daemon, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	d := &Daemon{
+		listener:      listener,
+		started:       time.Now(),
+		sampleRateUS:  rpiutils.DefaultPigpiodSampleRateUS,
+		conns:         map[net.Conn]struct{}{},
+		notifications: map[uint32]*notification{},
+		waves:         map[uint]wave{},
+		serialReads:   map[uint][]byte{},
+		wires:         map[uint][]uint{},
+		i2cHandles:    map[uint32]struct{}{},
+		bbI2CBuses:    map[uint]struct{}{},
+		bbSPIBuses:    map[uint]struct{}{},
+		spiDevices:    map[uint]func(tx []byte) []byte{},
+	}
+	for i := range d.gpios {
+		d.gpios[i] = gpio{mode: pigpio.Input, pud: pigpio.PudOff, pwmRange: defaultPWMRange, pwmFreqHz: defaultPWMFreqHz}
+	}
+	d.wg.Add(1)
+	go d.accept()
+	return d, nil
+}
+
+// Endpoint returns the address the daemon listens on.
+func (d *Daemon) Endpoint() rpiutils.PigpiodEndpoint {
+	addr := d.listener.Addr().(*net.TCPAddr)
+	return rpiutils.PigpiodEndpoint{Host: addr.IP.String(), Port: addr.Port}
+}
+
+// Close stops the daemon and drops every connection to it.
+func (d *Daemon) Close() error {
+	err := d.listener.Close()
+	d.mu.Lock()
+	for conn := range d.conns {
+		//nolint:errcheck  // the daemon is going away, like a killed pigpiod
+		conn.Close()
+	}
+	for bcom := range 32 {
+		d.setWatchdog(uint(bcom), 0)
+		d.gpios[bcom].pwm = false
+		d.runPWM(&d.gpios[bcom])
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+	return err
+}
+
+// SetLevel drives the level of an input gpio from outside, like a sensor connected to it would.
+func (d *Daemon) SetLevel(bcom uint, high bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	g := &d.gpios[bcom]
+	if g.mode == pigpio.Output {
+		return
+	}
+	levels := d.levels()
+	g.driven = true
+	g.level = 0
+	if high {
+		g.level = 1
+	}
+	d.notify(levels)
+}
+
+// Wire connects two gpios, like a jumper wire between their pins. Serial data a wave sends on either
+// gpio is received by a serial read on the other.
+func (d *Daemon) Wire(a, b uint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.wires[a] = append(d.wires[a], b)
+	d.wires[b] = append(d.wires[b], a)
+}
+
+// ConnectSPIDevice connects a device to the chip select gpio of a bit banged spi bus. Transfers on
+// that chip select read back what the device answers to the sent bytes instead of the sent bytes.
+func (d *Daemon) ConnectSPIDevice(cs uint, device func(tx []byte) []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.spiDevices[cs] = device
+}
+
+// Waves returns the number of waves that were created and not deleted.
+func (d *Daemon) Waves() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.waves)
+}
+
+// Level returns the level of a gpio.
+func (d *Daemon) Level(bcom uint) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.gpios[bcom].level == 1
+}
+
+// Mode returns the mode of a gpio.
+func (d *Daemon) Mode(bcom uint) uint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.gpios[bcom].mode
+}
+
+// Pull returns the pull of a gpio.
+func (d *Daemon) Pull(bcom uint) uint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.gpios[bcom].pud
+}
+
+// Filters returns the glitch and noise filters of a gpio.
+func (d *Daemon) Filters(bcom uint) (glitchUS, noiseSteadyUS, noiseActiveUS uint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	g := d.gpios[bcom]
+	return g.glitchUS, g.noiseSteadyUS, g.noiseActiveUS
+}
+
+// Watchdog returns the watchdog timeout of a gpio.
+func (d *Daemon) Watchdog(bcom uint) uint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.gpios[bcom].watchdogMS
+}
+
+// PWM returns the software pwm settings of a gpio.
+func (d *Daemon) PWM(bcom uint) (duty, pwmRange, freqHz uint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	g := d.gpios[bcom]
+	return g.pwmDuty, g.pwmRange, g.pwmFreqHz
+}
+
+func (d *Daemon) accept() {
+	defer d.wg.Done()
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		d.mu.Lock()
+		d.conns[conn] = struct{}{}
+		d.mu.Unlock()
+		d.wg.Add(1)
+		go d.serve(conn)
+	}
+}
+
+// serve answers the commands sent on a connection until it is closed.
+func (d *Daemon) serve(conn net.Conn) {
+	defer d.wg.Done()
+	defer func() {
+		d.mu.Lock()
+		delete(d.conns, conn)
+		for handle, n := range d.notifications {
+			if n.conn == conn {
+				delete(d.notifications, handle)
+			}
+		}
+		d.mu.Unlock()
+		//nolint:errcheck  // the client is gone
+		conn.Close()
+	}()
+
+	header := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		cmd := binary.LittleEndian.Uint32(header[0:])
+		p1 := binary.LittleEndian.Uint32(header[4:])
+		p2 := binary.LittleEndian.Uint32(header[8:])
+		ext := make([]byte, binary.LittleEndian.Uint32(header[12:]))
+		if _, err := io.ReadFull(conn, ext); err != nil {
+			return
+		}
+
+		d.mu.Lock()
+		var res int
+		var data []byte
+		if cmd == pigpio.CmdNOIB {
+			// the connection only carries reports from now on, so the handle has to be answered
+			// before any report is sent on it
+			res = d.openNotification(conn)
+		} else {
+			res, data = d.handle(cmd, p1, p2, ext)
+		}
+		binary.LittleEndian.PutUint32(header[12:], uint32(int32(res)))
+		_, err := conn.Write(append(header, data...))
+		d.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// openNotification turns a connection into a notification socket.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) openNotification(conn net.Conn) int {
+	handle := d.nextHandle
+	d.nextHandle++
+	d.notifications[handle] = &notification{conn: conn}
+	return int(handle)
+}
+
+// levels returns the levels of gpios 0-31.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) levels() uint32 {
+	var levels uint32
+	for i := 0; i < 32; i++ {
+		levels |= uint32(d.gpios[i].level) << i
+	}
+	return levels
+}
+
+// notify sends a report to every notification watching a gpio that changed since the levels were old,
+// and restarts the watchdogs of the gpios that changed.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) notify(old uint32) {
+	d.notifyAt(old, d.tick())
+}
+
+// notifyAt is notify for a change that happened at tick.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) notifyAt(old, tick uint32) {
+	levels := d.levels()
+	for bcom := range 32 {
+		if (levels^old)&(1<<bcom) != 0 && d.gpios[bcom].watchdogMS != 0 {
+			d.setWatchdog(uint(bcom), d.gpios[bcom].watchdogMS)
+		}
+	}
+	for _, n := range d.notifications {
+		if (levels^old)&n.bits == 0 {
+			continue
+		}
+		n.seq++
+		report := pigpio.Report{Seq: n.seq, Tick: tick, Levels: levels}
+		//nolint:errcheck  // a client that has gone away is cleaned up by its connection
+		n.conn.Write(report.Encode())
+	}
+}
+
+// setWatchdog starts the watchdog of a gpio over, or stops it if timeoutMS is 0. When it fires, every
+// notification watching the gpio gets a watchdog report, and the watchdog starts over.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) setWatchdog(bcom, timeoutMS uint) {
+	g := &d.gpios[bcom]
+	if g.watchdog != nil {
+		g.watchdog.Stop()
+		g.watchdog = nil
+	}
+	g.watchdogMS = timeoutMS
+	if timeoutMS == 0 {
+		return
+	}
+	var watchdog *time.Timer
+	watchdog = time.AfterFunc(time.Duration(timeoutMS)*time.Millisecond, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if g.watchdog != watchdog {
+			// the watchdog was stopped or started over while this was waiting for the lock
+			return
+		}
+		for _, n := range d.notifications {
+			if n.bits&(1<<bcom) == 0 {
+				continue
+			}
+			n.seq++
+			report := pigpio.Report{Seq: n.seq, Flags: pigpio.NotifyFlagWatchdog | uint16(bcom), Tick: d.tick(), Levels: d.levels()}
+			//nolint:errcheck  // a client that has gone away is cleaned up by its connection
+			n.conn.Write(report.Encode())
+		}
+		d.setWatchdog(bcom, timeoutMS)
+	})
+	g.watchdog = watchdog
+}
+
+// tick returns the microseconds since the daemon started, wrapping like the daemon's do.
+func (d *Daemon) tick() uint32 {
+	return d.tickAt(time.Now())
+}
+
+// tickAt returns the tick of a time.
+func (d *Daemon) tickAt(at time.Time) uint32 {
+	return uint32(at.Sub(d.started).Microseconds())
+}
+
+// followPull sets the level of an input gpio nothing drives to the level of its pull.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) followPull(g *gpio) {
+	if g.mode == pigpio.Output || g.driven {
+		return
+	}
+	switch g.pud {
+	case pigpio.PudUp:
+		g.level = 1
+	case pigpio.PudDown:
+		g.level = 0
+	}
+}
+
+// realRange returns the number of steps the software pwm of a gpio has at its frequency.
+func (d *Daemon) realRange(g *gpio) int {
+	return int(1e6 / (uint(d.sampleRateUS) * g.pwmFreqHz))
+}
+
+// handle runs a command and returns its result and the data to send after it.
+// The daemon mutex should be locked before calling this.
+//
+//nolint:gocyclo
+func (d *Daemon) handle(cmd, p1, p2 uint32, ext []byte) (int, []byte) {
+	bcom := uint(p1)
+	if cmd <= pigpio.CmdWrite && bcom >= numGPIOs {
+		return pigpio.BadGPIO, nil
+	}
+	switch cmd {
+	case pigpio.CmdModes, pigpio.CmdModeg, pigpio.CmdPud, pigpio.CmdRead, pigpio.CmdWrite:
+		return d.handleGPIO(cmd, &d.gpios[bcom], p2), nil
+	case pigpio.CmdPWM, pigpio.CmdGDC, pigpio.CmdPRS, pigpio.CmdPRG, pigpio.CmdPRRG, pigpio.CmdPFS, pigpio.CmdPFG:
+		if bcom > 31 {
+			return pigpio.BadUserGPIO, nil
+		}
+		return d.handlePWM(cmd, &d.gpios[bcom], uint(p2)), nil
+	case pigpio.CmdFG, pigpio.CmdFN:
+		if bcom > 31 {
+			return pigpio.BadUserGPIO, nil
+		}
+		return d.handleFilter(cmd, &d.gpios[bcom], uint(p2), ext), nil
+	case pigpio.CmdWDOG:
+		switch {
+		case bcom > 31:
+			return pigpio.BadUserGPIO, nil
+		case p2 > 60000:
+			return pigpio.BadWDogTimeout, nil
+		}
+		d.setWatchdog(bcom, uint(p2))
+		return 0, nil
+	case pigpio.CmdHP:
+		return d.hardwarePWM(bcom, uint(p2), binary.LittleEndian.Uint32(ext)), nil
+	case pigpio.CmdHC:
+		return d.hardwareClock(bcom, uint(p2)), nil
+	case pigpio.CmdBR1:
+		return int(int32(d.levels())), nil
+	case pigpio.CmdTick:
+		return int(int32(d.tick())), nil
+	case pigpio.CmdHWVer:
+		return hardwareRevision, nil
+	case pigpio.CmdPigpv:
+		return version, nil
+	case pigpio.CmdNB:
+		n, ok := d.notifications[p1]
+		if !ok {
+			return pigpio.BadHandle, nil
+		}
+		n.bits = p2
+		return 0, nil
+	case pigpio.CmdNC:
+		n, ok := d.notifications[p1]
+		if !ok {
+			return pigpio.BadHandle, nil
+		}
+		delete(d.notifications, p1)
+		//nolint:errcheck  // closing the socket is how the daemon ends a notification
+		n.conn.Close()
+		return 0, nil
+	case pigpio.CmdWVClr, pigpio.CmdWVNew, pigpio.CmdWVAG, pigpio.CmdWVAS, pigpio.CmdWVCre, pigpio.CmdWVDel,
+		pigpio.CmdWVTx, pigpio.CmdWVTxR, pigpio.CmdWVCha, pigpio.CmdWVBsy, pigpio.CmdWVHlt:
+		return d.handleWave(cmd, p1, p2, ext), nil
+	case pigpio.CmdSLRO, pigpio.CmdSLR, pigpio.CmdSLRC:
+		return d.handleSerial(cmd, bcom, p2, ext)
+	case pigpio.CmdI2CO, pigpio.CmdI2CC, pigpio.CmdI2CRD, pigpio.CmdI2CWD, pigpio.CmdI2CZ,
+		pigpio.CmdBI2CO, pigpio.CmdBI2CC, pigpio.CmdBI2CZ:
+		return d.handleI2C(cmd, p1, p2, ext)
+	case pigpio.CmdBSPIO, pigpio.CmdBSPIC, pigpio.CmdBSPIX:
+		return d.handleSPI(cmd, bcom, ext)
+	default:
+		return pigpio.UnknownCommand, nil
+	}
+}
+
+// handleGPIO runs the commands that set and read the mode, pull and level of a gpio.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) handleGPIO(cmd uint32, g *gpio, p2 uint32) int {
+	old := d.levels()
+	defer d.notify(old)
+
+	switch cmd {
+	case pigpio.CmdModes:
+		if p2 > 7 {
+			return pigpio.BadMode
+		}
+		if uint(p2) != pigpio.Output {
+			g.pwm = false
+			d.runPWM(g)
+		}
+		g.mode = uint(p2)
+		g.hwPWMFreqHz, g.clockFreqHz = 0, 0
+		d.followPull(g)
+	case pigpio.CmdModeg:
+		return int(g.mode)
+	case pigpio.CmdPud:
+		if p2 > pigpio.PudUp {
+			return pigpio.BadPud
+		}
+		g.pud = uint(p2)
+		d.followPull(g)
+	case pigpio.CmdRead:
+		return int(g.level)
+	case pigpio.CmdWrite:
+		if p2 > 1 {
+			return pigpio.BadLevel
+		}
+		g.mode, g.level, g.driven = pigpio.Output, uint(p2), false
+		g.pwm, g.hwPWMFreqHz, g.clockFreqHz = false, 0, 0
+		d.runPWM(g)
+	}
+	return 0
+}
+
+// handleFilter sets the glitch or noise filter of a gpio.
+func (d *Daemon) handleFilter(cmd uint32, g *gpio, steadyUS uint, ext []byte) int {
+	if steadyUS > 300000 {
+		return pigpio.BadFilter
+	}
+	if cmd == pigpio.CmdFG {
+		g.glitchUS = steadyUS
+		return 0
+	}
+	activeUS := uint(binary.LittleEndian.Uint32(ext))
+	if activeUS > 1000000 {
+		return pigpio.BadFilter
+	}
+	g.noiseSteadyUS, g.noiseActiveUS = steadyUS, activeUS
+	return 0
+}
+
+// handlePWM runs the software pwm commands of a gpio.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) handlePWM(cmd uint32, g *gpio, p2 uint) int {
+	switch cmd {
+	case pigpio.CmdPWM:
+		if p2 > g.pwmRange {
+			return pigpio.BadDutycycle
+		}
+		g.mode, g.pwm, g.pwmDuty = pigpio.Output, true, p2
+		g.hwPWMFreqHz, g.clockFreqHz = 0, 0
+		d.runPWM(g)
+	case pigpio.CmdGDC:
+		switch {
+		case g.hwPWMFreqHz != 0:
+			return int(g.hwPWMDuty)
+		case g.pwm:
+			return int(g.pwmDuty)
+		default:
+			return pigpio.NotPWMGPIO
+		}
+	case pigpio.CmdPRS:
+		if p2 < 25 || p2 > 40000 {
+			return pigpio.BadDutyRange
+		}
+		// like the daemon, the duty cycle keeps the same proportion of the new range
+		g.pwmDuty = g.pwmDuty * p2 / g.pwmRange
+		g.pwmRange = p2
+		d.runPWM(g)
+		return d.realRange(g)
+	case pigpio.CmdPRG:
+		if g.hwPWMFreqHz != 0 {
+			return hardwarePWMRange
+		}
+		return int(g.pwmRange)
+	case pigpio.CmdPRRG:
+		if g.hwPWMFreqHz != 0 {
+			return int(250e6 / g.hwPWMFreqHz)
+		}
+		return d.realRange(g)
+	case pigpio.CmdPFS:
+		closest := uint(0)
+		for _, freqHz := range rpiutils.PWMFrequencies(d.sampleRateUS) {
+			if math.Abs(float64(freqHz)-float64(p2)) < math.Abs(float64(closest)-float64(p2)) {
+				closest = freqHz
+			}
+		}
+		g.pwmFreqHz = closest
+		d.runPWM(g)
+		return int(closest)
+	case pigpio.CmdPFG:
+		switch {
+		case g.hwPWMFreqHz != 0:
+			return int(g.hwPWMFreqHz)
+		case g.clockFreqHz != 0:
+			return int(g.clockFreqHz)
+		default:
+			return int(g.pwmFreqHz)
+		}
+	}
+	return 0
+}
+
+// runPWM starts toggling the level of a gpio over to follow its software pwm, or stops toggling it if
+// the pwm is off. A duty cycle of 0 or of the full range holds the level low or high. Each change is
+// reported with the tick it was due at rather than the tick its timer fired at, like the daemon
+// reports the tick it sampled the change at.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) runPWM(g *gpio) {
+	if g.pwmTimer != nil {
+		g.pwmTimer.Stop()
+		g.pwmTimer = nil
+	}
+	if !g.pwm {
+		return
+	}
+	if g.pwmDuty == 0 || g.pwmDuty >= g.pwmRange {
+		old := d.levels()
+		g.level = 0
+		if g.pwmDuty != 0 {
+			g.level = 1
+		}
+		d.notify(old)
+		return
+	}
+
+	period := time.Second / time.Duration(g.pwmFreqHz)
+	high := period * time.Duration(g.pwmDuty) / time.Duration(g.pwmRange)
+	var timer *time.Timer
+	var toggle func(at time.Time, rising bool)
+	toggle = func(at time.Time, rising bool) {
+		old := d.levels()
+		g.level = 0
+		next := at.Add(period - high)
+		if rising {
+			g.level, next = 1, at.Add(high)
+		}
+		d.notifyAt(old, d.tickAt(at))
+		timer = time.AfterFunc(time.Until(next), func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			if g.pwmTimer != timer {
+				// the pwm was changed or stopped while this was waiting for the lock
+				return
+			}
+			toggle(next, !rising)
+		})
+		g.pwmTimer = timer
+	}
+	toggle(time.Now(), true)
+}
+
+// hardwarePWM starts or stops the pwm peripheral on a gpio.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) hardwarePWM(bcom, freqHz uint, dutyPPM uint32) int {
+	mode, ok := hardwarePWMModes[bcom]
+	switch {
+	case !ok:
+		return pigpio.NotHPWMGPIO
+	case freqHz > 187500000:
+		return pigpio.BadHPWMFreq
+	case dutyPPM > hardwarePWMRange:
+		return pigpio.BadHPWMDuty
+	}
+	g := &d.gpios[bcom]
+	g.hwPWMFreqHz, g.hwPWMDuty = freqHz, dutyPPM
+	if freqHz != 0 {
+		g.mode, g.pwm = mode, false
+		d.runPWM(g)
+	}
+	return 0
+}
+
+// hardwareClock starts or stops a general purpose clock on a gpio.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) hardwareClock(bcom, freqHz uint) int {
+	mode, ok := clockModes[bcom]
+	switch {
+	case !ok:
+		return pigpio.NotHCLKGPIO
+	// the daemon runs on a pi 4, whose BCM2711 runs its clocks from a faster source
+	case freqHz != 0 && (freqHz < rpiutils.MinBCM2711ClockFrequencyHz || freqHz > rpiutils.MaxBCM2711ClockFrequencyHz):
+		return pigpio.BadHCLKFreq
+	}
+	g := &d.gpios[bcom]
+	g.clockFreqHz = freqHz
+	if freqHz != 0 {
+		g.mode, g.pwm = mode, false
+		d.runPWM(g)
+	}
+	return 0
+}
+
+// handleWave runs the wave commands. Waves never change any levels, but their serial data is
+// received by the serial reads on the gpios wired to theirs.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) handleWave(cmd, p1, p2 uint32, ext []byte) int {
+	switch cmd {
+	case pigpio.CmdWVClr:
+		d.waves = map[uint]wave{}
+		d.building = wave{}
+		d.waveRepeating = false
+	case pigpio.CmdWVNew:
+		d.building = wave{}
+	case pigpio.CmdWVAG:
+		d.building.pulses += len(ext) / 12
+		return d.building.pulses
+	case pigpio.CmdWVAS:
+		if p1 > 31 {
+			return pigpio.BadUserGPIO
+		}
+		if p2 < 50 || p2 > 1e6 {
+			return pigpio.BadWaveBaud
+		}
+		// every character takes a pulse for each bit plus the start and stop bits
+		dataBits := int(binary.LittleEndian.Uint32(ext))
+		d.building.pulses += (len(ext) - 12) * (dataBits + 2)
+		d.building.serial = append(d.building.serial, serialData{bcom: uint(p1), data: ext[12:]})
+		return d.building.pulses
+	case pigpio.CmdWVCre:
+		if d.building.pulses == 0 {
+			return pigpio.EmptyWaveform
+		}
+		id := uint(0)
+		for ; ; id++ {
+			if _, ok := d.waves[id]; !ok {
+				break
+			}
+		}
+		d.waves[id] = d.building
+		d.building = wave{}
+		return int(id)
+	case pigpio.CmdWVDel, pigpio.CmdWVTx, pigpio.CmdWVTxR:
+		w, ok := d.waves[uint(p1)]
+		if !ok {
+			return pigpio.BadWaveID
+		}
+		if cmd == pigpio.CmdWVDel {
+			delete(d.waves, uint(p1))
+			return 0
+		}
+		// a new wave replaces the one being transmitted
+		d.waveRepeating = cmd == pigpio.CmdWVTxR
+		for _, serial := range w.serial {
+			for _, bcom := range d.wires[serial.bcom] {
+				if received, ok := d.serialReads[bcom]; ok {
+					d.serialReads[bcom] = append(received, serial.data...)
+				}
+			}
+		}
+		return w.pulses
+	case pigpio.CmdWVBsy:
+		if d.waveRepeating {
+			return 1
+		}
+	case pigpio.CmdWVHlt:
+		d.waveRepeating = false
+	}
+	// chains are accepted as is, and finish instantly
+	return 0
+}
+
+// handleSerial runs the bit banged serial read commands.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) handleSerial(cmd uint32, bcom uint, p2 uint32, ext []byte) (int, []byte) {
+	if bcom > 31 {
+		return pigpio.BadUserGPIO, nil
+	}
+	received, open := d.serialReads[bcom]
+	switch cmd {
+	case pigpio.CmdSLRO:
+		dataBits := binary.LittleEndian.Uint32(ext)
+		switch {
+		case p2 < 50 || p2 > 250000:
+			return pigpio.BadWaveBaud, nil
+		case dataBits < 1 || dataBits > 32:
+			return pigpio.BadDatabits, nil
+		case open:
+			return pigpio.GPIOInUse, nil
+		}
+		d.serialReads[bcom] = []byte{}
+	case pigpio.CmdSLR:
+		if !open {
+			return pigpio.NotSerialGPIO, nil
+		}
+		n := min(int(p2), len(received))
+		d.serialReads[bcom] = received[n:]
+		return n, received[:n]
+	case pigpio.CmdSLRC:
+		if !open {
+			return pigpio.NotSerialGPIO, nil
+		}
+		delete(d.serialReads, bcom)
+	}
+	return 0, nil
+}
+
+// handleI2C runs the hardware and bit banged i2c commands. Devices read back zeros.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) handleI2C(cmd, p1, p2 uint32, ext []byte) (int, []byte) {
+	_, handleOpen := d.i2cHandles[p1]
+	_, busOpen := d.bbI2CBuses[uint(p1)]
+	switch cmd {
+	case pigpio.CmdI2CO:
+		switch {
+		case p1 > 1:
+			return pigpio.BadI2CBus, nil
+		case p2 > 0x7f:
+			return pigpio.BadI2CAddr, nil
+		}
+		handle := uint32(0)
+		for ; ; handle++ {
+			if _, ok := d.i2cHandles[handle]; !ok {
+				break
+			}
+		}
+		d.i2cHandles[handle] = struct{}{}
+		return int(handle), nil
+	case pigpio.CmdI2CC, pigpio.CmdI2CRD, pigpio.CmdI2CWD, pigpio.CmdI2CZ:
+		if !handleOpen {
+			return pigpio.BadHandle, nil
+		}
+		switch cmd {
+		case pigpio.CmdI2CC:
+			delete(d.i2cHandles, p1)
+		case pigpio.CmdI2CRD:
+			return int(p2), make([]byte, p2)
+		case pigpio.CmdI2CZ:
+			count := zipReadCount(ext)
+			return count, make([]byte, count)
+		}
+		return 0, nil
+	case pigpio.CmdBI2CO:
+		baud := binary.LittleEndian.Uint32(ext)
+		switch {
+		case p1 > 31 || p2 > 31:
+			return pigpio.BadUserGPIO, nil
+		case baud < 50 || baud > 500000:
+			return pigpio.BadI2CBaud, nil
+		case busOpen || p1 == p2:
+			return pigpio.GPIOInUse, nil
+		}
+		d.bbI2CBuses[uint(p1)] = struct{}{}
+		return 0, nil
+	default:
+		if !busOpen {
+			return pigpio.NotI2CGPIO, nil
+		}
+		if cmd == pigpio.CmdBI2CC {
+			delete(d.bbI2CBuses, uint(p1))
+			return 0, nil
+		}
+		count := zipReadCount(ext)
+		return count, make([]byte, count)
+	}
+}
+
+// zipReadCount returns the number of bytes the reads of an i2c zip command ask for.
+func zipReadCount(in []byte) int {
+	count := 0
+	escaped := false
+	param := func(i int) (int, int) {
+		if escaped && i+1 < len(in) {
+			return int(in[i]) | int(in[i+1])<<8, i + 2
+		}
+		if i < len(in) {
+			return int(in[i]), i + 1
+		}
+		return 0, i
+	}
+	for i := 0; i < len(in); {
+		cmd := in[i]
+		i++
+		var p int
+		switch cmd {
+		case 0: // end
+			return count
+		case 1: // escape
+			escaped = true
+			continue
+		case 4: // address
+			_, i = param(i)
+		case 5: // flags
+			i += 2
+		case 6: // read
+			p, i = param(i)
+			count += p
+		case 7: // write
+			p, i = param(i)
+			i += p
+		}
+		escaped = false
+	}
+	return count
+}
+
+// handleSPI runs the bit banged spi commands. Unless a device is connected to the chip select, MISO
+// is looped back to MOSI, so a transfer reads back what it sent.
+// The daemon mutex should be locked before calling this.
+func (d *Daemon) handleSPI(cmd uint32, cs uint, ext []byte) (int, []byte) {
+	if cs > 31 {
+		return pigpio.BadUserGPIO, nil
+	}
+	_, open := d.bbSPIBuses[cs]
+	switch cmd {
+	case pigpio.CmdBSPIO:
+		for i := 0; i < 3; i++ {
+			if binary.LittleEndian.Uint32(ext[4*i:]) > 31 {
+				return pigpio.BadUserGPIO, nil
+			}
+		}
+		if baud := binary.LittleEndian.Uint32(ext[12:]); baud < 50 || baud > 250000 {
+			return pigpio.BadSPIBaud, nil
+		}
+		if open {
+			return pigpio.GPIOInUse, nil
+		}
+		d.bbSPIBuses[cs] = struct{}{}
+		return 0, nil
+	case pigpio.CmdBSPIC:
+		if !open {
+			return pigpio.NotSPIGPIO, nil
+		}
+		delete(d.bbSPIBuses, cs)
+		return 0, nil
+	default:
+		if !open {
+			return pigpio.NotSPIGPIO, nil
+		}
+		if device, ok := d.spiDevices[cs]; ok {
+			rx := make([]byte, len(ext))
+			copy(rx, device(ext))
+			return len(rx), rx
+		}
+		return len(ext), ext
+	}
+}
@@ -0,0 +1,71 @@
+package pigpio
+
+/*
+	notification.go: Notifications opened by the caller, like notify_open and notify_begin of
+	pigpiod_if2. A notification has a socket of its own, and its reports are read in batches rather
+	than dispatched to callbacks one at a time, which keeps up with gpios that change tens of
+	thousands of times a second. The socket interface opens notifications with NOIB rather than
+	the pipes of NO, so they also work with a daemon on another machine.
+*/
+
+import (
+	"bufio"
+	"io"
+	"net"
+)
+
+// notificationBufferSize is the size of the read buffer of a notification, which bounds the reports
+// a single read of its socket can return.
+const notificationBufferSize = 4096 * reportSize
+
+// A Notification reports the changes of the gpios it watches. Its reports are read with Read.
+type Notification struct {
+	conn   net.Conn
+	handle uint32
+	r      *bufio.Reader
+	buf    [reportSize]byte
+}
+
+// Read waits for a report, then decodes it and the reports that arrived with it into reports, and
+// returns the number of reports decoded. It returns an error once the notification is closed. Read
+// must not be called from several goroutines at once.
+func (n *Notification) Read(reports []Report) (int, error) {
+	count := 0
+	for count < len(reports) && (count == 0 || n.r.Buffered() >= reportSize) {
+		if _, err := io.ReadFull(n.r, n.buf[:]); err != nil {
+			return count, err
+		}
+		reports[count] = DecodeReport(n.buf[:])
+		count++
+	}
+	return count, nil
+}
+
+// NotifyOpen opens a notification socket to the daemon.
+func (c *Client) NotifyOpen() (*Notification, int) {
+	c.mu.Lock()
+	connected := c.conn != nil
+	c.mu.Unlock()
+	if !connected {
+		return nil, UnconnectedPi
+	}
+	conn, handle, err := dialNotification(c.addr)
+	if err != nil {
+		return nil, BadNoib
+	}
+	return &Notification{conn: conn, handle: handle, r: bufio.NewReaderSize(conn, notificationBufferSize)}, 0
+}
+
+// NotifyBegin sets the gpios a notification watches.
+func (c *Client) NotifyBegin(n *Notification, bits uint32) int {
+	return c.command(CmdNB, n.handle, bits, nil, nil)
+}
+
+// NotifyClose tells the daemon to free a notification, and closes its socket. Like pigpiod_if2, the
+// socket is closed even if the daemon can no longer be told.
+func (c *Client) NotifyClose(n *Notification) int {
+	res := c.command(CmdNC, n.handle, 0, nil, nil)
+	//nolint:errcheck  // the daemon frees the handle of a closed socket anyway
+	n.conn.Close()
+	return res
+}
@@ -0,0 +1,214 @@
+package pigpio
+
+/*
+	notify.go: Calls the callbacks registered on a client. The daemon reports on a second socket
+	whenever one of the gpios it has been asked to watch changes. A report is 12 bytes: a sequence
+	number, flags, the tick and the levels of gpios 0-31. Like pigpiod_if2, the edges are found by
+	comparing the levels with those of the previous report.
+*/
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+const reportSize = 12
+
+// Report is one notification from the daemon.
+type Report struct {
+	Seq    uint16
+	Flags  uint16
+	Tick   uint32
+	Levels uint32
+}
+
+// DecodeReport decodes a report as it is sent on the notification socket.
+func DecodeReport(buf []byte) Report {
+	return Report{
+		Seq:    binary.LittleEndian.Uint16(buf[0:]),
+		Flags:  binary.LittleEndian.Uint16(buf[2:]),
+		Tick:   binary.LittleEndian.Uint32(buf[4:]),
+		Levels: binary.LittleEndian.Uint32(buf[8:]),
+	}
+}
+
+// Encode encodes a report as it is sent on the notification socket.
+func (r Report) Encode() []byte {
+	buf := make([]byte, reportSize)
+	binary.LittleEndian.PutUint16(buf[0:], r.Seq)
+	binary.LittleEndian.PutUint16(buf[2:], r.Flags)
+	binary.LittleEndian.PutUint32(buf[4:], r.Tick)
+	binary.LittleEndian.PutUint32(buf[8:], r.Levels)
+	return buf
+}
+
+type callback struct {
+	gpio, edge uint
+	f          CallbackFunc
+}
+
+// notifier reads the notification socket of a client.
+type notifier struct {
+	conn      net.Conn
+	handle    uint32
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// mu is held for reading while callbacks are called, so cancelling a callback waits for it
+	mu         sync.RWMutex
+	callbacks  map[uint]*callback
+	nextID     uint
+	lastLevels uint32 // used by the goroutine reading reports, and by Callback with mu locked
+}
+
+// dialNotification opens a notification socket to the daemon and returns its handle.
+func dialNotification(addr string) (net.Conn, uint32, error) {
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, 0, err
+	}
+	handle, err := exchange(conn, CmdNOIB, 0, 0, nil, nil)
+	if err != nil {
+		return nil, 0, multierr.Combine(err, conn.Close())
+	}
+	if handle < 0 {
+		return nil, 0, multierr.Combine(errors.Errorf("failed to open a notification socket, error code %d", handle), conn.Close())
+	}
+	// reports can be minutes apart
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, 0, multierr.Combine(err, conn.Close())
+	}
+	return conn, uint32(handle), nil
+}
+
+// openNotifier opens a notification socket to the daemon and starts reading it.
+func openNotifier(c *Client, addr string) (*notifier, error) {
+	conn, handle, err := dialNotification(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &notifier{
+		conn:       conn,
+		handle:     handle,
+		done:       make(chan struct{}),
+		callbacks:  map[uint]*callback{},
+		lastLevels: uint32(c.command(CmdBR1, 0, 0, nil, nil)),
+	}
+	go n.read()
+	return n, nil
+}
+
+// read dispatches reports until the socket is closed.
+func (n *notifier) read() {
+	defer close(n.done)
+	r := bufio.NewReader(n.conn)
+	buf := make([]byte, reportSize)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return
+		}
+		n.dispatch(DecodeReport(buf))
+	}
+}
+
+// dispatch calls the callbacks a report is relevant to.
+func (n *notifier) dispatch(r Report) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	switch {
+	case r.Flags == 0:
+		changed := r.Levels ^ n.lastLevels
+		n.lastLevels = r.Levels
+		for _, cb := range n.callbacks {
+			if changed&(1<<cb.gpio) == 0 {
+				continue
+			}
+			level := uint(r.Levels>>cb.gpio) & 1
+			if cb.edge == EitherEdge || (cb.edge == RisingEdge && level == 1) || (cb.edge == FallingEdge && level == 0) {
+				cb.f(cb.gpio, level, r.Tick)
+			}
+		}
+	case r.Flags&NotifyFlagWatchdog != 0:
+		gpio := uint(r.Flags & NotifyGPIOMask)
+		for _, cb := range n.callbacks {
+			if cb.gpio == gpio {
+				cb.f(gpio, Timeout, r.Tick)
+			}
+		}
+	}
+}
+
+// bits returns the gpios the callbacks watch.
+// The notifier mutex should be locked before calling this.
+func (n *notifier) bits() uint32 {
+	var bits uint32
+	for _, cb := range n.callbacks {
+		bits |= 1 << cb.gpio
+	}
+	return bits
+}
+
+// close closes the socket and waits for the last report to be dispatched.
+func (n *notifier) close() error {
+	var err error
+	n.closeOnce.Do(func() {
+		err = n.conn.Close()
+		<-n.done
+	})
+	return err
+}
+
+// Callback calls f whenever gpio changes on the given edge.
+func (c *Client) Callback(gpio, edge uint, f CallbackFunc) (uint, int) {
+	if gpio > 31 {
+		return 0, BadUserGPIO
+	}
+	n := c.notifier
+	if n == nil {
+		return 0, UnconnectedPi
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.bits()&(1<<gpio) == 0 {
+		// the daemon did not report the changes of the gpio while nothing watched it, so the edges
+		// are found from its level now
+		levels := uint32(c.command(CmdBR1, 0, 0, nil, nil))
+		n.lastLevels = n.lastLevels&^(1<<gpio) | levels&(1<<gpio)
+	}
+	id := n.nextID
+	n.nextID++
+	n.callbacks[id] = &callback{gpio: gpio, edge: edge, f: f}
+	if res := c.command(CmdNB, n.handle, n.bits(), nil, nil); res < 0 {
+		delete(n.callbacks, id)
+		return 0, res
+	}
+	return id, 0
+}
+
+// CallbackCancel stops a callback. Like pigpiod_if2, it succeeds even if the daemon can no longer
+// be told to stop watching the gpio.
+func (c *Client) CallbackCancel(id uint) int {
+	n := c.notifier
+	if n == nil {
+		return UnconnectedPi
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.callbacks[id]; !ok {
+		return CallbackNotFound
+	}
+	delete(n.callbacks, id)
+	c.command(CmdNB, n.handle, n.bits(), nil, nil)
+	return 0
+}
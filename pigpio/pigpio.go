@@ -0,0 +1,231 @@
+// Package pigpio talks to the pigpio daemon over its socket interface. It replaces the pigpiod_if2 C
+// library, so the boards don't need cgo and can be tested against a fake daemon on any machine.
+package pigpio
+
+/*
+	pigpio.go: The constants of the daemon's interface, and the Pi interface the boards use.
+	The functions mirror pigpiod_if2 and return its result codes, so the codes can be turned into
+	messages with rpiutils.ConvertErrorCodeToMessage.
+	Details on the socket interface can be found here -> https://abyz.me.uk/rpi/pigpio/sif.html
+*/
+
+// Modes of a gpio.
+const (
+	Input  = 0
+	Output = 1
+	Alt0   = 4
+	Alt1   = 5
+	Alt2   = 6
+	Alt3   = 7
+	Alt4   = 3
+	Alt5   = 2
+)
+
+// Pulls of a gpio.
+const (
+	PudOff  = 0
+	PudDown = 1
+	PudUp   = 2
+)
+
+// Edges a callback can be called on.
+const (
+	RisingEdge  = 0
+	FallingEdge = 1
+	EitherEdge  = 2
+)
+
+// Timeout is the level passed to a callback when the watchdog of its gpio fires.
+const Timeout = 2
+
+// Result codes used by this package, the full list is in rpiutils.PiGPIOErrorMap.
+const (
+	BadUserGPIO      = -2
+	BadGPIO          = -3
+	BadMode          = -4
+	BadLevel         = -5
+	BadPud           = -6
+	BadWDogTimeout   = -15
+	BadPulsewidth    = -7
+	BadDutycycle     = -8
+	BadDutyRange     = -21
+	BadHandle        = -25
+	BadWaveBaud      = -35
+	NotSerialGPIO    = -38
+	GPIOInUse        = -50
+	BadWaveID        = -66
+	EmptyWaveform    = -69
+	BadI2CBus        = -74
+	BadI2CAddr       = -75
+	UnknownCommand   = -88
+	NotPWMGPIO       = -92
+	NotServoGPIO     = -93
+	NotHCLKGPIO      = -94
+	NotHPWMGPIO      = -95
+	BadHPWMFreq      = -96
+	BadHPWMDuty      = -97
+	BadHCLKFreq      = -98
+	BadDatabits      = -101
+	BadFilter        = -125
+	NotI2CGPIO       = -108
+	BadI2CBaud       = -112
+	BadSPIBaud       = -141
+	NotSPIGPIO       = -142
+	BadSend          = -2000
+	BadRecv          = -2001
+	BadNoib          = -2005
+	CallbackNotFound = -2010
+	UnconnectedPi    = -2011
+)
+
+// Command numbers of the socket interface.
+const (
+	CmdModes = 0
+	CmdModeg = 1
+	CmdPud   = 2
+	CmdRead  = 3
+	CmdWrite = 4
+	CmdPWM   = 5
+	CmdPRS   = 6
+	CmdPFS   = 7
+	CmdWDOG  = 9
+	CmdBR1   = 10
+	CmdTick  = 16
+	CmdHWVer = 17
+	CmdNB    = 19
+	CmdNC    = 21
+	CmdPRG   = 22
+	CmdPFG   = 23
+	CmdPRRG  = 24
+	CmdPigpv = 26
+	CmdWVClr = 27
+	CmdWVAG  = 28
+	CmdWVAS  = 29
+	CmdWVBsy = 32
+	CmdWVHlt = 33
+	CmdSLRO  = 42
+	CmdSLR   = 43
+	CmdSLRC  = 44
+	CmdWVCre = 49
+	CmdWVDel = 50
+	CmdWVTx  = 51
+	CmdWVTxR = 52
+	CmdWVNew = 53
+	CmdI2CO  = 54
+	CmdI2CC  = 55
+	CmdI2CRD = 56
+	CmdI2CWD = 57
+	CmdGDC   = 83
+	CmdHC    = 85
+	CmdHP    = 86
+	CmdBI2CC = 89
+	CmdBI2CO = 90
+	CmdBI2CZ = 91
+	CmdI2CZ  = 92
+	CmdWVCha = 93
+	CmdFG    = 97
+	CmdFN    = 98
+	CmdNOIB  = 99
+	CmdBSPIC = 111
+	CmdBSPIO = 112
+	CmdBSPIX = 113
+)
+
+// Flags of a notification report.
+const (
+	NotifyFlagWatchdog = 1 << 5 // the watchdog of the gpio in the low 5 bits fired
+	NotifyFlagAlive    = 1 << 6 // keep alive, sent when nothing has changed for a minute
+	NotifyFlagEvent    = 1 << 7 // the event in the low 5 bits was triggered
+	NotifyGPIOMask     = 31
+)
+
+// Pulse is one step of a generic wave: the gpios in the On mask are switched on and those in the Off
+// mask are switched off, then the wave waits for Delay microseconds.
+type Pulse struct {
+	On, Off uint32
+	DelayUS uint32
+}
+
+// CallbackFunc is called with the gpio, its new level and the tick of the change. The level is
+// Timeout if the watchdog of the gpio fired instead.
+type CallbackFunc func(gpio, level uint, tick uint32)
+
+// Pi is a connection to a pigpio daemon. The functions are named after those of pigpiod_if2 and
+// return the same results: a negative result is an error code.
+type Pi interface {
+	SetMode(gpio, mode uint) int
+	GetMode(gpio uint) int
+	SetPullUpDown(gpio, pud uint) int
+	Read(gpio uint) int
+	Write(gpio, level uint) int
+	// SetGlitchFilter only reports a change of the gpio once it kept its level for steadyUS. A steadyUS
+	// of 0 clears the filter.
+	SetGlitchFilter(gpio, steadyUS uint) int
+	// SetNoiseFilter ignores changes of the gpio until it kept a level for steadyUS, then reports
+	// changes for activeUS. A steadyUS of 0 clears the filter.
+	SetNoiseFilter(gpio, steadyUS, activeUS uint) int
+	// SetWatchdog calls the callbacks of the gpio with Timeout whenever it did not change for
+	// timeoutMS, and again every timeoutMS until it changes. A timeoutMS of 0 stops the watchdog.
+	SetWatchdog(gpio, timeoutMS uint) int
+
+	SetPWMDutycycle(gpio, duty uint) int
+	GetPWMDutycycle(gpio uint) int
+	SetPWMRange(gpio, pwmRange uint) int
+	GetPWMRange(gpio uint) int
+	GetPWMRealRange(gpio uint) int
+	SetPWMFrequency(gpio, freqHz uint) int
+	GetPWMFrequency(gpio uint) int
+	HardwarePWM(gpio, freqHz uint, dutyPPM uint32) int
+	HardwareClock(gpio, freqHz uint) int
+
+	CurrentTick() uint32
+	PigpioVersion() int
+
+	WaveClear() int
+	WaveAddNew() int
+	WaveAddGeneric(pulses []Pulse) int
+	WaveAddSerial(gpio, baud, dataBits, stopHalfBits, offsetUS uint, data []byte) int
+	WaveCreate() int
+	WaveDelete(id uint) int
+	WaveSendOnce(id uint) int
+	WaveSendRepeat(id uint) int
+	WaveChain(buf []byte) int
+	WaveTxBusy() int
+	WaveTxStop() int
+
+	BBSerialReadOpen(gpio, baud, dataBits uint) int
+	BBSerialRead(gpio uint, buf []byte) int
+	BBSerialReadClose(gpio uint) int
+
+	I2COpen(bus, addr, flags uint) int
+	I2CClose(handle uint) int
+	I2CReadDevice(handle uint, buf []byte) int
+	I2CWriteDevice(handle uint, data []byte) int
+	I2CZip(handle uint, in, out []byte) int
+	BBI2COpen(sda, scl, baud uint) int
+	BBI2CClose(sda uint) int
+	BBI2CZip(sda uint, in, out []byte) int
+
+	BBSPIOpen(cs, miso, mosi, sclk, baud, flags uint) int
+	BBSPIClose(cs uint) int
+	BBSPIXfer(cs uint, tx, rx []byte) int
+
+	// Callback calls f whenever the level of the gpio changes on the given edge, and returns the id
+	// of the callback. Callbacks are called one at a time from a single goroutine, and must not add
+	// or cancel callbacks themselves.
+	Callback(gpio, edge uint, f CallbackFunc) (uint, int)
+	// CallbackCancel stops a callback. Once it returns, the callback is not running and won't be
+	// called again.
+	CallbackCancel(id uint) int
+
+	// NotifyOpen opens a notification, which reports the changes of the gpios it is told to watch
+	// with NotifyBegin. Unlike callbacks, its reports are read in batches with Notification.Read.
+	NotifyOpen() (*Notification, int)
+	// NotifyBegin sets the gpios 0-31 a notification watches, as a bit mask. A mask of 0 pauses it.
+	NotifyBegin(n *Notification, bits uint32) int
+	// NotifyClose closes a notification. Once it returns, Notification.Read returns an error.
+	NotifyClose(n *Notification) int
+
+	// Close disconnects from the daemon. Every function returns UnconnectedPi afterwards.
+	Close() error
+}
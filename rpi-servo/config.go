@@ -17,6 +17,11 @@ type ServoConfig struct {
 	HoldPos     *bool    `json:"hold_position,omitempty"`          // defaults True. False holds for 500 ms then disables servo
 	MaxRotation int      `json:"max_rotation_deg,omitempty"`       // specifies a hardware position limitation. Defaults to 180
 	Freq        int      `json:"frequency_hz,omitempty"`           // specifies the pwm frequency to drive the servo.
+
+	// PigpiodHost and PigpiodPort select the pigpio daemon driving the servo. If neither is set, the
+	// servo uses the same daemon as its board.
+	PigpiodHost string `json:"pigpiod_host,omitempty"`
+	PigpiodPort int    `json:"pigpiod_port,omitempty"`
 }
 
 // Validate ensures all parts of the config are valid.
@@ -30,6 +35,10 @@ func (config *ServoConfig) Validate(path string) ([]string, []string, error) {
 		return nil, nil, resource.NewConfigValidationError(path,
 			errors.New("need the name of the board"))
 	}
+	if config.PigpiodPort < 0 || config.PigpiodPort > 65535 {
+		return nil, nil, resource.NewConfigValidationError(path+".pigpiod_port",
+			errors.Errorf("invalid port %d", config.PigpiodPort))
+	}
 	deps = append(deps, config.BoardName)
 	return deps, nil, nil
 }
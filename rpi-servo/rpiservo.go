@@ -15,21 +15,19 @@ package rpiservo
 	http://www.ee.ic.ac.uk/pcheung/teaching/DE1_EE/stores/sg90_datasheet.pdf
 */
 
-// #include <stdlib.h>
-// #include <pigpiod_if2.h>
-// #include "../rpi/pi.h"
-// #cgo LDFLAGS: -lpigpiod_if2
-import "C"
-
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"go.viam.com/rdk/components/board"
 	"go.viam.com/rdk/components/servo"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/operation"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/utils"
+	"raspberry-pi/pigpio"
+	rpiutils "raspberry-pi/utils"
 )
 
 // Model represents a pi servo model.
@@ -54,7 +52,7 @@ func init() {
 
 func newPiServo(
 	ctx context.Context,
-	_ resource.Dependencies,
+	deps resource.Dependencies,
 	conf resource.Config,
 	logger logging.Logger,
 ) (servo.Servo, error) {
@@ -72,7 +70,7 @@ func newPiServo(
 		return nil, err
 	}
 
-	piServo, err := initializeServo(conf, logger, bcom, newConf)
+	piServo, err := initializeServo(conf, logger, bcom, newConf, pigpiodEndpoint(deps, newConf))
 	if err != nil {
 		return nil, err
 	}
@@ -89,11 +87,17 @@ func newPiServo(
 }
 
 // initializeServo creates and initializes the piPigpioServo with the provided configuration and logger.
-func initializeServo(conf resource.Config, logger logging.Logger, bcom uint, newConf *ServoConfig) (*piPigpioServo, error) {
+func initializeServo(
+	conf resource.Config,
+	logger logging.Logger,
+	bcom uint,
+	newConf *ServoConfig,
+	endpoint rpiutils.PigpiodEndpoint,
+) (*piPigpioServo, error) {
 	piServo := &piPigpioServo{
 		Named:     conf.ResourceName().AsNamed(),
 		logger:    logger,
-		pin:       C.uint(bcom),
+		pin:       bcom,
 		pinname:   newConf.Pin,
 		opMgr:     operation.NewSingleOperationManager(),
 		pwmFreqHz: 50, // default frequency for most pi hobby servos
@@ -107,28 +111,47 @@ func initializeServo(conf resource.Config, logger logging.Logger, bcom uint, new
 
 	// Start separate connection from board to pigpio daemon
 	// Needs to be called before using other pigpio functions
-	piID := C.pigpio_start(nil, nil)
-	// Set communication ID for servo
-	piServo.piID = piID
+	resolved := endpoint.Resolved()
+	daemon, err := pigpio.Connect(resolved.Host, resolved.Port)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to pigpiod at %s: %w", endpoint, err)
+	}
+	piServo.daemon = daemon
 
 	return piServo, nil
 }
 
+// pigpiodEndpoint returns the pigpio daemon the servo should use: the one configured on the servo, or
+// else the one its board uses.
+func pigpiodEndpoint(deps resource.Dependencies, conf *ServoConfig) rpiutils.PigpiodEndpoint {
+	if conf.PigpiodHost != "" || conf.PigpiodPort != 0 {
+		return rpiutils.PigpiodEndpoint{Host: conf.PigpiodHost, Port: conf.PigpiodPort}
+	}
+	b, err := board.FromDependencies(deps, conf.BoardName)
+	if err != nil {
+		return rpiutils.PigpiodEndpoint{}
+	}
+	if pigpiodBoard, ok := b.(rpiutils.PigpiodBoard); ok {
+		return pigpiodBoard.PigpiodEndpoint()
+	}
+	return rpiutils.PigpiodEndpoint{}
+}
+
 // piPigpioServo implements a servo.Servo using pigpio.
 type piPigpioServo struct {
 	resource.Named
 	resource.AlwaysRebuild
 	logger      logging.Logger
-	pin         C.uint
+	pin         uint
 	pinname     string
-	pwInUse     C.int // pulsewidth in use
+	pwInUse     int // pulsewidth in use
 	min, max    uint32
 	opMgr       *operation.SingleOperationManager
 	pulseWidth  int // pulsewidth value, 500-2500us is 0-180 degrees, 0 is off
 	holdPos     bool
 	maxRotation uint32
-	piID        C.int
-	pwmFreqHz   C.uint
+	daemon      pigpio.Pi // connection to the pigpio daemon
+	pwmFreqHz   uint
 }
 
 // Move moves the servo to the given angle (0-180 degrees)
@@ -167,7 +190,7 @@ func (s *piPigpioServo) Move(ctx context.Context, angle uint32, extra map[string
 
 // Position returns the current set angle (degrees) of the servo.
 func (s *piPigpioServo) Position(ctx context.Context, extra map[string]interface{}) (uint32, error) {
-	pwInUse := C.get_PWM_dutycycle(s.piID, s.pin)
+	pwInUse := s.daemon.GetPWMDutycycle(s.pin)
 	err := s.pigpioErrors(int(pwInUse))
 	if int(pwInUse) != 0 {
 		s.pwInUse = pwInUse
@@ -204,7 +227,9 @@ func (s *piPigpioServo) IsMoving(ctx context.Context) (bool, error) {
 // Close gracefully stops any ongoing operations and disconnects from the pigpio daemon.
 func (s *piPigpioServo) Close(ctx context.Context) error {
 	s.logger.Debug("Stopping pigpio connection")
-	C.pigpio_stop(s.piID)
+	if err := s.daemon.Close(); err != nil {
+		return err
+	}
 
 	s.logger.Info("Successfully closed pigpio connection")
 	return nil
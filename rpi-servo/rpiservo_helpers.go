@@ -1,15 +1,11 @@
 package rpiservo
 
-// #include <stdlib.h>
-// #include <pigpiod_if2.h>
-// #include "../rpi/pi.h"
-import "C"
-
 import (
 	"fmt"
 
 	"github.com/pkg/errors"
 	"go.viam.com/rdk/resource"
+	"raspberry-pi/pigpio"
 	rpiutils "raspberry-pi/utils"
 )
 
@@ -36,7 +32,7 @@ func (s *piPigpioServo) validateAndSetConfiguration(conf *ServoConfig) error {
 
 	// if user doesn't provide a frequency, we keep the default value of 50 Hz
 	if conf.Freq > 0 {
-		s.pwmFreqHz = C.uint(conf.Freq)
+		s.pwmFreqHz = uint(conf.Freq)
 	}
 
 	s.pinname = conf.Pin
@@ -64,7 +60,7 @@ func handleHoldPosition(piServo *piPigpioServo, newConf *ServoConfig) error {
 		piServo.holdPos = true
 	} else {
 		// Release the servo position and disable the servo
-		piServo.pwInUse = C.get_PWM_dutycycle(piServo.piID, piServo.pin)
+		piServo.pwInUse = piServo.daemon.GetPWMDutycycle(piServo.pin)
 		piServo.holdPos = false
 		err := piServo.setServoPulseWidth(0)
 		if err != nil {
@@ -81,15 +77,15 @@ func (s *piPigpioServo) setServoPulseWidth(pulseWidth int) error {
 		return errors.New("invalid pulse width: out of range [0, 2500]")
 	}
 
-	errCode := C.set_PWM_frequency(s.piID, s.pin, s.pwmFreqHz)
+	errCode := s.daemon.SetPWMFrequency(s.pin, s.pwmFreqHz)
 	if errCode < 0 {
 		return fmt.Errorf("servo set pwm frequency on pin %s failed: %w", s.pinname, s.pigpioErrors(int(errCode)))
 	}
-	errCode = C.set_PWM_range(s.piID, s.pin, 1e6/s.pwmFreqHz)
+	errCode = s.daemon.SetPWMRange(s.pin, 1e6/s.pwmFreqHz)
 	if errCode < 0 {
 		return fmt.Errorf("servo set pwm range on pin %s failed: %w", s.pinname, s.pigpioErrors(int(errCode)))
 	}
-	errCode = C.set_PWM_dutycycle(s.piID, s.pin, C.uint(pulseWidth))
+	errCode = s.daemon.SetPWMDutycycle(s.pin, uint(pulseWidth))
 	if errCode < 0 {
 		return fmt.Errorf("servo set pwm duty cycle on pin %s failed: %w", s.pinname, s.pigpioErrors(int(errCode)))
 	}
@@ -125,13 +121,13 @@ func getBroadcomPin(pin string) (uint, error) {
 // pigpioErrors returns piGPIO specific errors to user
 func (s *piPigpioServo) pigpioErrors(res int) error {
 	switch {
-	case res == C.PI_NOT_SERVO_GPIO:
+	case res == pigpio.NotServoGPIO:
 		return errors.Errorf("servo on pin %s is not set up to send and receive pulsewidths", s.pinname)
-	case res == C.PI_BAD_PULSEWIDTH:
+	case res == pigpio.BadPulsewidth:
 		return errors.Errorf("servo on pin %s trying to reach out of range position", s.pinname)
 	case res == 0:
 		return nil
-	case res < 0 && res != C.PI_BAD_PULSEWIDTH && res != C.PI_NOT_SERVO_GPIO:
+	case res < 0 && res != pigpio.BadPulsewidth && res != pigpio.NotServoGPIO:
 		errMsg := fmt.Sprintf("gpioServo on pin %s failed", s.pinname)
 		return rpiutils.ConvertErrorCodeToMessage(res, errMsg)
 	default:
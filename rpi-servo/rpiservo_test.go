@@ -10,11 +10,13 @@ import (
 	"go.viam.com/rdk/operation"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/test"
+	"raspberry-pi/pigpio"
+	"raspberry-pi/pigpio/fakepigpiod"
 	"raspberry-pi/rpi"
 	rpiutils "raspberry-pi/utils"
 )
 
-func createDummyBoard(t *testing.T, ctx context.Context) board.Board {
+func createDummyBoard(t *testing.T, ctx context.Context, endpoint rpiutils.PigpiodEndpoint) board.Board {
 	// create board dependency
 	piReg, ok := resource.LookupRegistration(board.API, rpi.ModelPi4)
 	test.That(t, ok, test.ShouldBeTrue)
@@ -24,8 +26,12 @@ func createDummyBoard(t *testing.T, ctx context.Context) board.Board {
 		ctx,
 		nil,
 		resource.Config{
-			Name:                "rpi",
-			ConvertedAttributes: &rpiutils.Config{},
+			Name: "rpi",
+			ConvertedAttributes: &rpiutils.Config{
+				PigpiodHost: endpoint.Host,
+				PigpiodPort: endpoint.Port,
+				Pigpiod:     rpiutils.PigpiodConfig{Mode: rpiutils.PigpiodModeExternal},
+			},
 		},
 		logging.NewTestLogger(t),
 	)
@@ -36,11 +42,22 @@ func createDummyBoard(t *testing.T, ctx context.Context) board.Board {
 	return p
 }
 
+// startFakeDaemon starts a fake pigpio daemon for the duration of the test.
+func startFakeDaemon(t *testing.T) rpiutils.PigpiodEndpoint {
+	daemon, err := fakepigpiod.New()
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() {
+		test.That(t, daemon.Close(), test.ShouldBeNil)
+	})
+	return daemon.Endpoint()
+}
+
 func TestConstructor(t *testing.T) {
 	logger := logging.NewTestLogger(t)
 	ctx := context.Background()
 
-	p := createDummyBoard(t, ctx)
+	endpoint := startFakeDaemon(t)
+	p := createDummyBoard(t, ctx, endpoint)
 	defer func() {
 		err := p.Close(ctx)
 		test.That(t, err, test.ShouldBeNil)
@@ -57,8 +74,14 @@ func TestConstructor(t *testing.T) {
 			ctx,
 			nil,
 			resource.Config{
-				Name:                "servo",
-				ConvertedAttributes: &ServoConfig{Pin: "22", StartPos: &initPos, Freq: 100},
+				Name: "servo",
+				ConvertedAttributes: &ServoConfig{
+					Pin:         "22",
+					StartPos:    &initPos,
+					Freq:        100,
+					PigpiodHost: endpoint.Host,
+					PigpiodPort: endpoint.Port,
+				},
 			},
 			logger,
 		)
@@ -85,7 +108,8 @@ func TestConstructor(t *testing.T) {
 func TestInitializationFunctions(t *testing.T) {
 	ctx := context.Background()
 
-	p := createDummyBoard(t, ctx)
+	endpoint := startFakeDaemon(t)
+	p := createDummyBoard(t, ctx, endpoint)
 	defer func() {
 		err := p.Close(ctx)
 		test.That(t, err, test.ShouldBeNil)
@@ -105,7 +129,7 @@ func TestInitializationFunctions(t *testing.T) {
 			MaxRotation: 180,
 		}
 
-		s, err := initializeServo(conf, logger, bcom, newConf)
+		s, err := initializeServo(conf, logger, bcom, newConf, endpoint)
 		test.That(t, s, test.ShouldBeNil)
 		test.That(t, err, test.ShouldNotBeNil)
 		test.That(t, err.Error(), test.ShouldContainSubstring, "maxRotation is less than minimum")
@@ -117,7 +141,7 @@ func TestInitializationFunctions(t *testing.T) {
 			MaxRotation: 179,
 		}
 
-		s, err = initializeServo(conf, logger, bcom, newConf)
+		s, err = initializeServo(conf, logger, bcom, newConf, endpoint)
 		test.That(t, s, test.ShouldBeNil)
 		test.That(t, err, test.ShouldNotBeNil)
 		test.That(t, err.Error(), test.ShouldContainSubstring, "maxRotation is less than maximum")
@@ -131,10 +155,10 @@ func TestInitializationFunctions(t *testing.T) {
 
 		targetPin := 3
 
-		s, err = initializeServo(conf, logger, bcom, newConf)
+		s, err = initializeServo(conf, logger, bcom, newConf, endpoint)
 		test.That(t, err, test.ShouldBeNil)
 		test.That(t, s, test.ShouldNotBeNil)
-		test.That(t, int(s.piID), test.ShouldBeGreaterThanOrEqualTo, 0)
+		test.That(t, s.daemon, test.ShouldNotBeNil)
 		test.That(t, int(s.pin), test.ShouldEqual, targetPin)
 		test.That(t, s.max, test.ShouldEqual, 180)
 		test.That(t, s.min, test.ShouldEqual, 0)
@@ -155,7 +179,7 @@ func TestInitializationFunctions(t *testing.T) {
 		}
 
 		// create servo
-		s, err := initializeServo(conf, logger, bcom, newConf)
+		s, err := initializeServo(conf, logger, bcom, newConf, endpoint)
 		test.That(t, err, test.ShouldBeNil)
 
 		// default(nil) initial position
@@ -195,7 +219,7 @@ func TestInitializationFunctions(t *testing.T) {
 		}
 
 		// create servo
-		s, err := initializeServo(conf, logger, bcom, newConf)
+		s, err := initializeServo(conf, logger, bcom, newConf, endpoint)
 		test.That(t, err, test.ShouldBeNil)
 
 		// default(nil) hold position is true
@@ -340,7 +364,12 @@ func TestServoFunctions(t *testing.T) {
 
 	t.Run(("check Move IsMoving and pigpio errors"), func(t *testing.T) {
 		ctx := context.Background()
-		s := &piPigpioServo{pinname: "1", maxRotation: 180, opMgr: operation.NewSingleOperationManager()}
+		s := &piPigpioServo{
+			pinname:     "1",
+			maxRotation: 180,
+			opMgr:       operation.NewSingleOperationManager(),
+			daemon:      &pigpio.Client{},
+		}
 
 		s.pwInUse = -93
 		err := s.pigpioErrors(int(s.pwInUse))
@@ -0,0 +1,62 @@
+package rpi
+
+/*
+	alt_functions.go: Muxes pins to their alternate functions (GPCLK, PCM, etc).
+*/
+
+import (
+	"github.com/pkg/errors"
+	"raspberry-pi/pigpio"
+	rpiutils "raspberry-pi/utils"
+)
+
+// pigpioAltModes maps alternate function numbers to the modes used by set_mode.
+var pigpioAltModes = []uint{pigpio.Alt0, pigpio.Alt1, pigpio.Alt2, pigpio.Alt3, pigpio.Alt4, pigpio.Alt5}
+
+// reconfigureAltFunctions muxes every pin configured as `alt` to its alternate function. Pins that
+// were previously configured as `alt` but no longer are get returned to inputs.
+func (pi *piPigpio) reconfigureAltFunctions(cfg *rpiutils.Config) error {
+	newAlts := map[uint]rpiutils.PinConfig{}
+	for _, newConfig := range cfg.Pins {
+		if newConfig.Type != rpiutils.PinAlt {
+			continue
+		}
+		bcom, ok := rpiutils.BroadcomPinFromHardwareLabel(newConfig.Pin)
+		if !ok {
+			return errors.Errorf("no hw pin for (%s)", newConfig.Pin)
+		}
+		newAlts[bcom] = newConfig
+	}
+
+	for _, oldConfig := range pi.pinConfigs {
+		if oldConfig.Type != rpiutils.PinAlt {
+			continue
+		}
+		bcom, ok := rpiutils.BroadcomPinFromHardwareLabel(oldConfig.Pin)
+		if !ok {
+			return errors.Errorf("cannot find GPIO for unknown pin: %s", oldConfig.Name)
+		}
+		if _, ok := newAlts[bcom]; ok {
+			continue
+		}
+		if res := pi.daemon.SetMode(bcom, pigpio.Input); res != 0 {
+			return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to set mode")
+		}
+	}
+
+	for bcom, altConfig := range newAlts {
+		function, isRP1, err := rpiutils.ParseAltFunction(altConfig.Function)
+		if err != nil {
+			return err
+		}
+		if isRP1 {
+			return errors.Errorf("alternate function %s on pin %s is only supported on the Pi 5, use alt0-alt5",
+				altConfig.Function, altConfig.Name)
+		}
+		if res := pi.daemon.SetMode(bcom, pigpioAltModes[function]); res != 0 {
+			return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to set mode")
+		}
+		pi.logger.Debugf("set pin %s to %s", altConfig.Name, altConfig.Function)
+	}
+	return nil
+}
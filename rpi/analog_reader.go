@@ -5,6 +5,7 @@ package rpi
 */
 
 import (
+	"context"
 	"strconv"
 
 	"github.com/pkg/errors"
@@ -15,16 +16,32 @@ import (
 	rpiutils "raspberry-pi/utils"
 )
 
-// Helper functions to configure analog readers and interrupts.
-func (pi *piPigpio) reconfigureAnalogReaders(cfg *rpiutils.Config) error {
-	// No need to reconfigure the old analog readers; just throw them out and make new ones.
-	pi.analogReaders = map[string]*pinwrappers.AnalogSmoother{}
+// Helper functions to configure analog readers and interrupts. Analogs whose spi_bus names one of
+// the soft spi buses use that bus, and can use any pin as their chip select.
+func (pi *piPigpio) reconfigureAnalogReaders(ctx context.Context, cfg *rpiutils.Config) error {
+	// No need to reconfigure the old analog readers; just close them and make new ones. They are
+	// closed before the soft spi buses change so they never read from a closed bus.
+	if err := closeAnalogReaders(ctx, pi); err != nil {
+		return err
+	}
+	if err := pi.reconfigureSoftSPIs(cfg); err != nil {
+		return err
+	}
 	for _, ac := range cfg.AnalogReaders {
 		channel, err := strconv.Atoi(ac.Channel)
 		if err != nil {
 			return errors.Errorf("bad analog pin (%s)", ac.Channel)
 		}
 
+		if softBus, ok := pi.softSPIs[ac.SPIBus]; ok {
+			pi.addAnalogReader(ac, &mcp3008helper.MCP3008AnalogReader{
+				Channel: channel,
+				Bus:     softBus,
+				Chip:    ac.ChipSelect,
+			})
+			continue
+		}
+
 		chipSelect := ac.ChipSelect
 
 		// Use genericlinux implementation for SPI bus.
@@ -41,19 +58,22 @@ func (pi *piPigpio) reconfigureAnalogReaders(cfg *rpiutils.Config) error {
 
 		bus := buses.NewSpiBus(ac.SPIBus)
 
-		ar := &mcp3008helper.MCP3008AnalogReader{
+		pi.addAnalogReader(ac, &mcp3008helper.MCP3008AnalogReader{
 			Channel: channel,
 			Bus:     bus,
 			Chip:    chipSelect,
-		}
-
-		pi.analogReaders[ac.Name] = pinwrappers.SmoothAnalogReader(ar, board.AnalogReaderConfig{
-			AverageOverMillis: ac.AverageOverMillis, SamplesPerSecond: ac.SamplesPerSecond,
-		}, pi.logger)
+		})
 	}
 	return nil
 }
 
+// addAnalogReader wraps the reader with the smoothing configured for the analog.
+func (pi *piPigpio) addAnalogReader(ac mcp3008helper.MCP3008AnalogConfig, ar *mcp3008helper.MCP3008AnalogReader) {
+	pi.analogReaders[ac.Name] = pinwrappers.SmoothAnalogReader(ar, board.AnalogReaderConfig{
+		AverageOverMillis: ac.AverageOverMillis, SamplesPerSecond: ac.SamplesPerSecond,
+	}, pi.logger)
+}
+
 // AnalogNames returns the names of all known analog pins.
 func (pi *piPigpio) AnalogNames() []string {
 	pi.mu.Lock()
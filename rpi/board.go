@@ -3,28 +3,27 @@ package rpi
 
 /*
 	This driver contains various functionalities of raspberry pi board using the
-	pigpio daemon library (https://abyz.me.uk/rpi/pigpio/pdif2.html).
-	NOTE: This driver only supports software PWM functionality of raspberry pi.
-		  For software PWM, we currently support the default sample rate of
-		  5 microseconds, which supports the following 18 frequencies (Hz):
+	pigpio daemon, over its socket interface (https://abyz.me.uk/rpi/pigpio/sif.html).
+	NOTE: Software PWM is available on every pin. The frequencies it supports depend on the sample
+		  rate of the daemon, see rpiutils.PWMFrequencies. At the default sample rate of 5
+		  microseconds, these are the following 18 frequencies (Hz):
 		  8000  4000  2000 1600 1000  800  500  400  320
 		  250   200   160  100   80   50   40   20   10
 		  Details on this can be found here -> https://abyz.me.uk/rpi/pigpio/pdif2.html#set_PWM_frequency
+		  Pins configured with `pwm_mode: hardware` or `pwm_mode: auto` on GPIO 12, 13, 18 and 19
+		  use the PWM peripheral instead, which supports arbitrary frequencies and a duty cycle
+		  resolution of up to 1M steps -> https://abyz.me.uk/rpi/pigpio/pdif2.html#hardware_PWM
+		  Pins configured as `clock` on GPIO 4, 5, 6, 20 and 21 output a general purpose clock
+		  -> https://abyz.me.uk/rpi/pigpio/pdif2.html#hardware_clock
 */
 
-// #include <stdlib.h>
-// #include <pigpiod_if2.h>
-// #include "pi.h"
-// #cgo LDFLAGS: -lpigpiod_if2
-import "C"
-
 import (
 	"context"
-	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -36,6 +35,8 @@ import (
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/utils"
+	"raspberry-pi/pi5"
+	"raspberry-pi/pigpio"
 	rpiutils "raspberry-pi/utils"
 )
 
@@ -50,18 +51,13 @@ var (
 	ModelPi0   = rpiutils.RaspiFamily.WithModel("rpi0")   // Raspberry Pi 0 model
 )
 
-var (
-	boardInstance   *piPigpio    // global instance of raspberry pi board for interrupt callbacks
-	boardInstanceMu sync.RWMutex // mutex to protect boardInstance
-)
-
 // init registers a pi board based on pigpio.
 func init() {
 	resource.RegisterComponent(
 		board.API,
 		ModelPi,
 		resource.Registration[board.Board, *rpiutils.Config]{
-			Constructor: newPigpio,
+			Constructor: newDetectedPi,
 		})
 	resource.RegisterComponent(
 		board.API,
@@ -119,21 +115,40 @@ type piPigpio struct {
 	logger     logging.Logger
 	isClosed   bool
 
-	piID C.int // id to communicate with pigpio daemon
+	tickQueue       rpiutils.TickQueueConfig              // the default queue of a StreamTicks client
+	tickSubscribers map[*rpiutils.TickSubscriber]struct{} // the queues of the StreamTicks clients
+
+	highRate *highRateCapture // captures the interrupts with high_rate: true, nil if there are none
 
-	pulls map[int]string // mapping of gpio pin to pull up/down
+	daemon   pigpio.Pi // connection to the pigpio daemon
+	endpoint rpiutils.PigpiodEndpoint
+	health   pigpiodHealth
+	// pigpiod holds the options of the daemon, and managesDaemon whether the board holds a reference
+	// to the daemon started by the module.
+	pigpiod       rpiutils.PigpiodConfig
+	managesDaemon bool
+	hardware      rpiutils.PiHardware
+
+	pulls  map[int]string    // mapping of gpio pin to pull up/down
+	clocks map[uint]uint     // mapping of gpio pin to the frequency of its general purpose clock
+	waves  map[uint]struct{} // ids of the waves created through DoCommand, deleted on close
+	// waveBuilding is whether pulses were added through DoCommand that no wave was created from yet
+	waveBuilding bool
+
+	softUARTs map[string]*softUART
+	i2cBuses  map[string]*pigpioI2CBus
+	softSPIs  map[string]*softSPIBus
+	waveTxMu  sync.Mutex // serializes wave transmissions, since only one wave can be transmitted at a time
 
 	activeBackgroundWorkers sync.WaitGroup
-}
 
-var (
-	pigpioInitialized bool
-	// To prevent deadlocks, we must never lock the mutex of a specific piPigpio struct, above,
-	// while this is locked. It is okay to lock this while one of those other mutexes is locked
-	// instead.
-	instanceMu sync.RWMutex
-	instances  = map[*piPigpio]struct{}{}
-)
+	// tickClock converts the ticks of the daemon to Unix time. Each daemon has its own clock, so it
+	// is kept per board. lastTickSync is when it was last synced, and is protected by the board mutex.
+	tickClock    rpiutils.TickClock
+	lastTickSync time.Time
+
+	totalizers *rpiutils.Totalizers // the persisted counts of the interrupts
+}
 
 // newPigpio makes a new pigpio based Board using the given config.
 func newPigpio(
@@ -142,62 +157,127 @@ func newPigpio(
 	conf resource.Config,
 	logger logging.Logger,
 ) (board.Board, error) {
-	piModel, err := os.ReadFile("/proc/device-tree/model")
+	cfg, err := resource.NativeConfig[*rpiutils.Config](conf)
 	if err != nil {
+		return nil, err
+	}
+	endpoint := cfg.PigpiodEndpoint()
+
+	// the hardware of a remote daemon cannot be detected from here
+	hw, err := rpiutils.DetectPiHardware()
+	if err != nil && endpoint.IsLocal() {
 		logger.Errorw("Cannot determine raspberry pi model", "error", err)
 	}
-	isPi5 := strings.Contains(string(piModel), "Raspberry Pi 5")
-	if isPi5 {
+	if hw.UsesRP1() && endpoint.IsLocal() {
 		return nil, rpiutils.WrongModelErr(conf.Name)
 	}
 
-	piID, err := initializePigpio()
+	totalizers, err := rpiutils.LoadTotalizers(conf.Name, cfg, logger)
 	if err != nil {
 		return nil, err
 	}
-	logger.CInfo(ctx, "successfully started pigpiod")
 
-	cancelCtx, cancelFunc := context.WithCancel(context.Background())
-	piInstance := &piPigpio{
-		Named:      conf.ResourceName().AsNamed(),
-		logger:     logger,
-		isClosed:   false,
-		cancelCtx:  cancelCtx,
-		cancelFunc: cancelFunc,
-		piID:       piID,
-		model:      conf.Model.Name,
-		interrupts: make(map[uint]*rpiInterrupt),
+	managesDaemon, err := startPigpiod(ctx, cfg, logger)
+	if err != nil {
+		return nil, err
 	}
+	daemon, err := initializePigpio(endpoint)
+	if err != nil {
+		if managesDaemon {
+			err = multierr.Combine(err, releasePigpiod())
+		}
+		return nil, err
+	}
+	logger.CInfof(ctx, "successfully connected to pigpiod at %s", endpoint)
 
+	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+	piInstance := &piPigpio{
+		Named:           conf.ResourceName().AsNamed(),
+		logger:          logger,
+		isClosed:        false,
+		cancelCtx:       cancelCtx,
+		cancelFunc:      cancelFunc,
+		daemon:          daemon,
+		endpoint:        endpoint,
+		health:          pigpiodHealth{connected: true},
+		pigpiod:         cfg.Pigpiod,
+		managesDaemon:   managesDaemon,
+		model:           conf.Model.Name,
+		hardware:        hw,
+		interrupts:      make(map[uint]*rpiInterrupt),
+		tickSubscribers: map[*rpiutils.TickSubscriber]struct{}{},
+		pulls:           map[int]string{},
+		clocks:          map[uint]uint{},
+		waves:           map[uint]struct{}{},
+		softUARTs:       map[string]*softUART{},
+		i2cBuses:        map[string]*pigpioI2CBus{},
+		softSPIs:        map[string]*softSPIBus{},
+		totalizers:      totalizers,
+	}
+	piInstance.syncTickClock()
 	if err := piInstance.Reconfigure(ctx, nil, conf); err != nil {
 		// This has to happen outside of the lock to avoid a deadlock with interrupts.
-		C.pigpio_stop(piID)
+		err = multierr.Combine(err, daemon.Close())
+		if managesDaemon {
+			err = multierr.Combine(err, releasePigpiod())
+		}
 		logger.CError(ctx, "Pi GPIO terminated due to failed init.")
 		return nil, err
 	}
 
+	piInstance.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(piInstance.superviseConnection, piInstance.activeBackgroundWorkers.Done)
+	piInstance.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() {
+		totalizers.CheckpointEvery(cancelCtx, rpiutils.TotalizerCheckpointInterval, func() []*rpiutils.BasicDigitalInterrupt {
+			piInstance.mu.Lock()
+			defer piInstance.mu.Unlock()
+			return piInstance.basicInterrupts()
+		}, logger)
+	}, piInstance.activeBackgroundWorkers.Done)
+
 	return piInstance, nil
 }
 
-// Function initializes connection to pigpio daemon.
-func initializePigpio() (C.int, error) {
-	boardInstanceMu.Lock()
-	defer boardInstanceMu.Unlock()
+// newDetectedPi detects which Raspberry Pi we are running on and creates a board with the matching
+// backend: pinctrl on RP1 based pis (Pi 5, Pi 500, CM5) and pigpio on everything else. This lets a
+// single config work across a fleet with mixed hardware.
+func newDetectedPi(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (board.Board, error) {
+	hw, err := rpiutils.DetectPiHardware()
+	if err != nil {
+		logger.Errorw("Cannot determine raspberry pi model", "error", err)
+		return newPigpio(ctx, deps, conf, logger)
+	}
+	if hw.UsesRP1() {
+		logger.CInfof(ctx, "detected %s, using the pinctrl backend", hw.Description)
+		return pi5.NewBoard(ctx, conf, logger)
+	}
+	logger.CInfof(ctx, "detected %s, using the pigpio backend", hw.Description)
+	return newPigpio(ctx, deps, conf, logger)
+}
 
-	piID := C.pigpio_start(nil, nil)
-	if int(piID) < 0 {
+// Function initializes connection to pigpio daemon.
+func initializePigpio(endpoint rpiutils.PigpiodEndpoint) (pigpio.Pi, error) {
+	resolved := endpoint.Resolved()
+	daemon, err := pigpio.Connect(resolved.Host, resolved.Port)
+	if err == nil {
+		return daemon, nil
+	}
+	if endpoint.IsLocal() {
 		// failed to init, check for common causes
-		_, err := os.Stat("/sys/bus/platform/drivers/raspberrypi-firmware")
-		if err != nil {
-			return -1, errors.New("not running on a pi")
+		if _, statErr := os.Stat("/sys/bus/platform/drivers/raspberrypi-firmware"); statErr != nil {
+			return nil, fmt.Errorf("failed to connect to pigpiod at %s: not running on a pi", endpoint)
 		}
 		if os.Getuid() != 0 {
-			return -1, errors.New("not running as root, try sudo")
+			return nil, fmt.Errorf("failed to connect to pigpiod at %s: not running as root, try sudo", endpoint)
 		}
-		return -1, rpiutils.ConvertErrorCodeToMessage(int(piID), "error")
 	}
-
-	return piID, nil
+	return nil, fmt.Errorf("failed to connect to pigpiod at %s: %w", endpoint, err)
 }
 
 func (pi *piPigpio) Reconfigure(
@@ -219,7 +299,12 @@ func (pi *piPigpio) Reconfigure(
 	pi.mu.Lock()
 	defer pi.mu.Unlock()
 
-	if err := pi.reconfigureAnalogReaders(cfg); err != nil {
+	// the daemon is only started and connected to when the board is built
+	if cfg.PigpiodEndpoint().String() != pi.endpoint.String() || !cfg.Pigpiod.Equals(pi.pigpiod) {
+		return resource.NewMustRebuildError(conf.ResourceName())
+	}
+
+	if err := pi.reconfigureAnalogReaders(ctx, cfg); err != nil {
 		return err
 	}
 
@@ -237,6 +322,22 @@ func (pi *piPigpio) Reconfigure(
 		return err
 	}
 
+	if err := pi.reconfigureAltFunctions(cfg); err != nil {
+		return err
+	}
+
+	if err := pi.reconfigureClocks(cfg); err != nil {
+		return err
+	}
+
+	if err := pi.reconfigureSoftUARTs(cfg); err != nil {
+		return err
+	}
+
+	if err := pi.reconfigureI2Cs(cfg); err != nil {
+		return err
+	}
+
 	if err := pi.configureI2C(cfg); err != nil {
 		return err
 	}
@@ -246,10 +347,7 @@ func (pi *piPigpio) Reconfigure(
 	}
 
 	pi.pinConfigs = cfg.Pins
-
-	boardInstanceMu.Lock()
-	defer boardInstanceMu.Unlock()
-	boardInstance = pi
+	pi.tickQueue = cfg.TickQueue
 
 	return nil
 }
@@ -266,21 +364,24 @@ func (pi *piPigpio) reconfigurePulls(cfg *rpiutils.Config) error {
 		}
 		switch pullConf.PullState {
 		case rpiutils.PullNone:
-			if result := C.setPullNone(pi.piID, C.int(gpioNum)); result != 0 {
+			if result := pi.daemon.SetPullUpDown(gpioNum, pigpio.PudOff); result != 0 {
 				pi.logger.Error(rpiutils.ConvertErrorCodeToMessage(int(result), "error"))
+				continue
 			}
 		case rpiutils.PullUp:
-			if result := C.setPullUp(pi.piID, C.int(gpioNum)); result != 0 {
+			if result := pi.daemon.SetPullUpDown(gpioNum, pigpio.PudUp); result != 0 {
 				pi.logger.Error(rpiutils.ConvertErrorCodeToMessage(int(result), "error"))
+				continue
 			}
 		case rpiutils.PullDown:
-			if result := C.setPullDown(pi.piID, C.int(gpioNum)); result != 0 {
+			if result := pi.daemon.SetPullUpDown(gpioNum, pigpio.PudDown); result != 0 {
 				pi.logger.Error(rpiutils.ConvertErrorCodeToMessage(int(result), "error"))
+				continue
 			}
 		default:
 			return fmt.Errorf("error configuring gpio pin %v pull: unexpected pull method %v", pullConf.Name, pullConf.PullState)
 		}
-
+		pi.pulls[int(gpioNum)] = string(pullConf.PullState)
 	}
 	return nil
 }
@@ -530,6 +631,10 @@ func (pi *piPigpio) updateI2CModule(enable bool) (bool, error) {
 
 // Close attempts to close all parts of the board cleanly.
 func (pi *piPigpio) Close(ctx context.Context) error {
+	// the connection supervisor locks the board, so it has to be stopped before we lock it
+	pi.cancelFunc()
+	pi.activeBackgroundWorkers.Wait()
+
 	pi.mu.Lock()
 	defer pi.mu.Unlock()
 
@@ -538,19 +643,20 @@ func (pi *piPigpio) Close(ctx context.Context) error {
 		return nil
 	}
 
-	pi.cancelFunc()
-	pi.activeBackgroundWorkers.Wait()
-
 	var err error
 	err = multierr.Combine(err,
 		closeAnalogReaders(ctx, pi),
-		teardownInterrupts(pi))
+		closeSoftSPIs(pi),
+		pi.totalizers.Checkpoint(pi.basicInterrupts()),
+		teardownInterrupts(pi),
+		closeSoftUARTs(pi),
+		closeI2CBuses(pi),
+		teardownWaves(pi))
 
-	boardInstanceMu.Lock()
-	boardInstance = nil
-	boardInstanceMu.Unlock()
-	// TODO: test this with multiple instences of the board.
-	C.pigpio_stop(pi.piID)
+	err = multierr.Combine(err, pi.daemon.Close())
+	if pi.managesDaemon {
+		err = multierr.Combine(err, releasePigpiod())
+	}
 	pi.logger.CDebug(ctx, "Pi GPIO terminated properly.")
 
 	pi.isClosed = true
@@ -561,30 +667,93 @@ func (pi *piPigpio) Close(ctx context.Context) error {
 func (pi *piPigpio) StreamTicks(ctx context.Context, interrupts []board.DigitalInterrupt, ch chan board.Tick,
 	extra map[string]interface{},
 ) error {
+	pi.mu.Lock()
+	queueConfig, err := rpiutils.TickQueueConfigFromExtra(pi.tickQueue, extra)
+	pi.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(interrupts))
+	for _, i := range interrupts {
+		names = append(names, i.(*rpiutils.BasicDigitalInterrupt).Name())
+	}
+	subscriber := rpiutils.NewTickSubscriber(ch, queueConfig, names)
 	for _, i := range interrupts {
-		rpiutils.AddCallback(i.(*rpiutils.BasicDigitalInterrupt), ch)
+		rpiutils.AddSubscriber(i.(*rpiutils.BasicDigitalInterrupt), subscriber)
 	}
+	pi.mu.Lock()
+	pi.tickSubscribers[subscriber] = struct{}{}
+	pi.mu.Unlock()
 
 	pi.activeBackgroundWorkers.Add(1)
 
 	utils.ManagedGo(func() {
-		// Wait until it's time to shut down then remove callbacks.
+		// Wait until it's time to shut down then remove the subscriber. It is closed first, so an
+		// interrupt blocked on its queue lets go of the interrupt.
 		select {
 		case <-ctx.Done():
 		case <-pi.cancelCtx.Done():
 		}
+		subscriber.Close()
 		for _, i := range interrupts {
-			rpiutils.RemoveCallback(i.(*rpiutils.BasicDigitalInterrupt), ch)
+			rpiutils.RemoveSubscriber(i.(*rpiutils.BasicDigitalInterrupt), subscriber)
 		}
+		pi.mu.Lock()
+		delete(pi.tickSubscribers, subscriber)
+		pi.mu.Unlock()
 	}, pi.activeBackgroundWorkers.Done)
 
 	return nil
 }
 
+// PigpiodEndpoint returns the address of the pigpio daemon the board talks to.
+func (pi *piPigpio) PigpiodEndpoint() rpiutils.PigpiodEndpoint {
+	return pi.endpoint
+}
+
+// DoCommand runs the board specific command named by the "command" key.
+func (pi *piPigpio) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	switch cmd[rpiutils.DoCommandKey] {
+	case rpiutils.PinStateCommand:
+		return pi.pinStateCommand(cmd)
+	case rpiutils.SetClockFrequencyCommand:
+		return pi.setClockFrequencyCommand(cmd)
+	case rpiutils.WaveAddGenericCommand, rpiutils.WaveCreateCommand, rpiutils.WaveDeleteCommand,
+		rpiutils.WaveSendOnceCommand, rpiutils.WaveSendRepeatCommand, rpiutils.WaveChainCommand,
+		rpiutils.WaveTxBusyCommand, rpiutils.WaveTxStopCommand:
+		return pi.waveCommand(cmd[rpiutils.DoCommandKey].(string), cmd)
+	case rpiutils.SoftUARTReadCommand, rpiutils.SoftUARTWriteCommand:
+		return pi.softUARTCommand(cmd[rpiutils.DoCommandKey].(string), cmd)
+	case rpiutils.I2CTransactionCommand:
+		return pi.i2cTransactionCommand(ctx, cmd)
+	case rpiutils.WatchdogTimeoutsCommand:
+		return pi.watchdogTimeoutsCommand(cmd)
+	case rpiutils.MeasureCommand:
+		return pi.measureCommand(cmd)
+	case rpiutils.ResetCounterCommand:
+		return pi.resetCounterCommand(cmd)
+	case rpiutils.TickHistoryCommand:
+		return pi.tickHistoryCommand(cmd)
+	case rpiutils.TickQueueStatsCommand:
+		return pi.tickQueueStatsCommand(), nil
+	case rpiutils.PigpiodHealthCommand:
+		return pi.pigpiodHealthCommand()
+	default:
+		return nil, fmt.Errorf("unknown command %v", cmd[rpiutils.DoCommandKey])
+	}
+}
+
 func (pi *piPigpio) SetPowerMode(ctx context.Context, mode pb.PowerMode, duration *time.Duration) error {
 	return grpc.UnimplementedError
 }
 
+// tickQueueStatsCommand handles the tick_queue_stats DoCommand.
+func (pi *piPigpio) tickQueueStatsCommand() map[string]interface{} {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	return rpiutils.TickQueueStatsResult(slices.Collect(maps.Keys(pi.tickSubscribers)))
+}
+
 // closeAnalogReaders closes all analog readers associated with the board.
 func closeAnalogReaders(ctx context.Context, pi *piPigpio) error {
 	var err error
@@ -598,10 +767,18 @@ func closeAnalogReaders(ctx context.Context, pi *piPigpio) error {
 // teardownInterrupts removes all hardware interrupts and cleans up.
 func teardownInterrupts(pi *piPigpio) error {
 	var err error
-	for _, rpiInterrupt := range pi.interrupts {
-		if result := C.teardownInterrupt(rpiInterrupt.callbackID); result != 0 {
+	for bcom, rpiInterrupt := range pi.interrupts {
+		if result := pi.cancelInterruptCallback(bcom, rpiInterrupt); result != 0 {
 			err = multierr.Combine(err, rpiutils.ConvertErrorCodeToMessage(int(result), "error"))
 		}
+		rpiInterrupt.debouncer.Stop()
+		// the filters and watchdogs are kept by the daemon, which may outlive the board
+		if result := pi.setDebounceFilters(bcom, rpiutils.DebounceModeDefault, 0); result != 0 {
+			err = multierr.Combine(err, rpiutils.ConvertErrorCodeToMessage(result, "error"))
+		}
+		if result := pi.daemon.SetWatchdog(bcom, 0); result != 0 {
+			err = multierr.Combine(err, rpiutils.ConvertErrorCodeToMessage(result, "error"))
+		}
 	}
 	pi.interrupts = map[uint]*rpiInterrupt{}
 	return err
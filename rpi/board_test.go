@@ -2,22 +2,99 @@ package rpi
 
 import (
 	"context"
-	"os"
+	"fmt"
+	"strconv"
 	"testing"
 	"time"
 
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/components/board/mcp3008helper"
 	"go.viam.com/rdk/components/servo"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/test"
+	"raspberry-pi/pigpio"
+	"raspberry-pi/pigpio/fakepigpiod"
 	rpiservo "raspberry-pi/rpi-servo"
 	rpiutils "raspberry-pi/utils"
 )
 
-func TestPiPigpio(t *testing.T) {
+// newTestBoard starts a fake daemon and a board on it with the pins of cfg. The board and the daemon
+// are closed when the test ends.
+func newTestBoard(t *testing.T, cfg *rpiutils.Config) (*piPigpio, *fakepigpiod.Daemon) {
+	t.Helper()
+	daemon, err := fakepigpiod.New()
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() {
+		test.That(t, daemon.Close(), test.ShouldBeNil)
+	})
+	return newTestBoardOn(t, daemon, cfg), daemon
+}
+
+// newTestBoardOn starts a board with the pins of cfg on a fake daemon, and closes it when the test
+// ends. The endpoint of the daemon is set in cfg.
+func newTestBoardOn(t *testing.T, daemon *fakepigpiod.Daemon, cfg *rpiutils.Config) *piPigpio {
+	t.Helper()
 	ctx := context.Background()
-	logger := logging.NewTestLogger(t)
+	endpoint := daemon.Endpoint()
+	cfg.PigpiodHost = endpoint.Host
+	cfg.PigpiodPort = endpoint.Port
+	cfg.Pigpiod = rpiutils.PigpiodConfig{Mode: rpiutils.PigpiodModeExternal}
+
+	pp, err := newPigpio(ctx, nil, testBoardConfig(cfg), logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	p := pp.(*piPigpio)
+	t.Cleanup(func() {
+		test.That(t, p.Close(ctx), test.ShouldBeNil)
+	})
+	return p
+}
+
+// waitFor polls done until it returns true, and fails the test if it doesn't within a second.
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	waitForWithin(t, time.Second, what, done)
+}
+
+// waitForWithin polls done until it returns true, and fails the test if it doesn't within timeout.
+func waitForWithin(t *testing.T, timeout time.Duration, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitForValue waits for the value of an interrupt to reach want.
+func waitForValue(t *testing.T, i board.DigitalInterrupt, want int64) {
+	t.Helper()
+	waitFor(t, fmt.Sprintf("interrupt %s to reach %d", i.Name(), want), func() bool {
+		value, err := i.Value(context.Background(), nil)
+		test.That(t, err, test.ShouldBeNil)
+		return value == want
+	})
+}
 
+// waitForHighTime waits for an interrupt to measure pulses that are high for want, give or take tolerance.
+func waitForHighTime(t *testing.T, i board.DigitalInterrupt, want, tolerance time.Duration) {
+	t.Helper()
+	waitFor(t, fmt.Sprintf("interrupt %s to measure %v pulses", i.Name(), want), func() bool {
+		highNs, err := i.Value(context.Background(), map[string]interface{}{"measurement": "high_time_ns"})
+		test.That(t, err, test.ShouldBeNil)
+		return (time.Duration(highNs) - want).Abs() <= tolerance
+	})
+}
+
+// testBoardConfig returns the config of a test board with cfg as its attributes.
+func testBoardConfig(cfg *rpiutils.Config) resource.Config {
+	return resource.Config{Name: "foo", ConvertedAttributes: cfg}
+}
+
+func TestPiPigpio(t *testing.T) {
+	ctx := context.Background()
 	cfg := rpiutils.Config{
 		Pins: []rpiutils.PinConfig{
 			{Name: "i1", Pin: "11", Type: "interrupt"}, // bcom 17
@@ -25,24 +102,7 @@ func TestPiPigpio(t *testing.T) {
 			{Name: "blue", Pin: "33", Type: "gpio"},
 		},
 	}
-	resourceConfig := resource.Config{
-		Name:                "foo",
-		ConvertedAttributes: &cfg,
-	}
-
-	pp, err := newPigpio(ctx, nil, resourceConfig, logger)
-	if os.Getuid() != 0 || err != nil && err.Error() == "not running on a pi" {
-		t.Skip("not running as root on a pi")
-		return
-	}
-	test.That(t, err, test.ShouldBeNil)
-
-	p := pp.(*piPigpio)
-
-	defer func() {
-		err := p.Close(ctx)
-		test.That(t, err, test.ShouldBeNil)
-	}()
+	p, daemon := newTestBoard(t, &cfg)
 
 	t.Run("test interrupts on reconfigure", func(t *testing.T) {
 		expectedNumInterrupts := 2
@@ -58,7 +118,7 @@ func TestPiPigpio(t *testing.T) {
 		test.That(t, len(p.interrupts), test.ShouldEqual, expectedNumInterrupts)
 
 		// test that the number of interrupts is the same after reconfigure
-		err = p.Reconfigure(ctx, nil, resourceConfig)
+		err = p.Reconfigure(ctx, nil, testBoardConfig(&cfg))
 		test.That(t, err, test.ShouldBeNil)
 		test.That(t, len(p.interrupts), test.ShouldEqual, expectedNumInterrupts)
 	})
@@ -155,16 +215,14 @@ func TestPiPigpio(t *testing.T) {
 		i1, err := p.DigitalInterruptByName("i1")
 		test.That(t, err, test.ShouldBeNil)
 
-		err = p.SetGPIOBcom(17, false)
-		test.That(t, err, test.ShouldBeNil)
+		daemon.SetLevel(17, false)
 
 		time.Sleep(5 * time.Millisecond)
 
 		before, err := i1.Value(context.Background(), nil)
 		test.That(t, err, test.ShouldBeNil)
 
-		err = p.SetGPIOBcom(17, true)
-		test.That(t, err, test.ShouldBeNil)
+		daemon.SetLevel(17, true)
 
 		time.Sleep(5 * time.Millisecond)
 
@@ -179,8 +237,7 @@ func TestPiPigpio(t *testing.T) {
 		i2, err := p.DigitalInterruptByName("13")
 		test.That(t, err, test.ShouldBeNil)
 		// Set pin 13 (bcom 27) to LOW
-		err = p.SetGPIOBcom(27, false)
-		test.That(t, err, test.ShouldBeNil)
+		daemon.SetLevel(27, false)
 
 		time.Sleep(5 * time.Millisecond)
 
@@ -192,8 +249,7 @@ func TestPiPigpio(t *testing.T) {
 		test.That(t, err, test.ShouldBeNil)
 
 		// Set pin 13 (bcom 27) to HIGH
-		err = p.SetGPIOBcom(27, true)
-		test.That(t, err, test.ShouldBeNil)
+		daemon.SetLevel(27, true)
 
 		time.Sleep(5 * time.Millisecond)
 
@@ -215,10 +271,14 @@ func TestPiPigpio(t *testing.T) {
 			ctx,
 			nil,
 			resource.Config{
-				Name:                "servo",
-				ConvertedAttributes: &rpiservo.ServoConfig{Pin: "22"},
+				Name: "servo",
+				ConvertedAttributes: &rpiservo.ServoConfig{
+					Pin:         "22",
+					PigpiodHost: daemon.Endpoint().Host,
+					PigpiodPort: daemon.Endpoint().Port,
+				},
 			},
-			logger,
+			logging.NewTestLogger(t),
 		)
 		test.That(t, err, test.ShouldBeNil)
 		servo1 := servoInt.(servo.Servo)
@@ -239,13 +299,13 @@ func TestPiPigpio(t *testing.T) {
 		test.That(t, err, test.ShouldBeNil)
 		test.That(t, int(v), test.ShouldEqual, 180)
 
-		time.Sleep(300 * time.Millisecond)
+		// pin 22 is bcom 25
+		test.That(t, servoPulseWidthUS(daemon, 25), test.ShouldEqual, 2500)
 
+		// the daemon toggles the pin of the servo, so servo-i measures its pulses
 		servoI, err := p.DigitalInterruptByName("servo-i")
 		test.That(t, err, test.ShouldBeNil)
-		val, err := servoI.Value(context.Background(), nil)
-		test.That(t, err, test.ShouldBeNil)
-		test.That(t, val, test.ShouldAlmostEqual, int64(2500), 100) // this is a tad noisy
+		waitForHighTime(t, servoI, 2500*time.Microsecond, 100*time.Microsecond)
 
 		// Next position (120 deg)
 		err = servo1.Move(ctx, 120, nil)
@@ -255,9 +315,744 @@ func TestPiPigpio(t *testing.T) {
 		test.That(t, err, test.ShouldBeNil)
 		test.That(t, int(v), test.ShouldEqual, 120)
 
-		time.Sleep(300 * time.Millisecond)
-		val, err = servoI.Value(context.Background(), nil)
+		test.That(t, servoPulseWidthUS(daemon, 25), test.ShouldEqual, 1833)
+		waitForHighTime(t, servoI, 1833*time.Microsecond, 50*time.Microsecond)
+	})
+}
+
+// servoPulseWidthUS returns the width of the pulses the daemon outputs on a pin, in microseconds.
+func servoPulseWidthUS(daemon *fakepigpiod.Daemon, bcom uint) uint {
+	duty, pwmRange, freqHz := daemon.PWM(bcom)
+	return duty * 1e6 / (pwmRange * freqHz)
+}
+
+func TestInterruptEdgeAndPull(t *testing.T) {
+	ctx := context.Background()
+	cfg := rpiutils.Config{
+		Pins: []rpiutils.PinConfig{
+			// bcom 22
+			{Name: "button", Pin: "15", Type: rpiutils.PinInterrupt, Edge: rpiutils.EdgeFalling, PullState: rpiutils.PullDown},
+		},
+	}
+	p, daemon := newTestBoard(t, &cfg)
+
+	button, err := p.DigitalInterruptByName("button")
+	test.That(t, err, test.ShouldBeNil)
+
+	toggle := func(times int) {
+		for range times {
+			daemon.SetLevel(22, true)
+			daemon.SetLevel(22, false)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Run("falling edge with a pull down", func(t *testing.T) {
+		test.That(t, daemon.Pull(22), test.ShouldEqual, pigpio.PudDown)
+
+		ticks := make(chan board.Tick, 10)
+		test.That(t, p.StreamTicks(ctx, []board.DigitalInterrupt{button}, ticks, nil), test.ShouldBeNil)
+		toggle(3)
+		test.That(t, len(ticks), test.ShouldEqual, 3)
+		for range 3 {
+			test.That(t, (<-ticks).High, test.ShouldBeFalse)
+		}
+
+		count, err := button.Value(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, count, test.ShouldEqual, 3)
+	})
+
+	t.Run("reconfigure to the rising edge with a pull up", func(t *testing.T) {
+		cfg.Pins[0].Edge = rpiutils.EdgeRising
+		cfg.Pins[0].PullState = rpiutils.PullUp
+		test.That(t, p.Reconfigure(ctx, nil, testBoardConfig(&cfg)), test.ShouldBeNil)
+		test.That(t, daemon.Pull(22), test.ShouldEqual, pigpio.PudUp)
+
+		toggle(2)
+		count, err := button.Value(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, count, test.ShouldEqual, 5)
+	})
+}
+
+func TestInterruptDebounceModes(t *testing.T) {
+	ctx := context.Background()
+	cfg := rpiutils.Config{
+		Pins: []rpiutils.PinConfig{
+			// bcom 22
+			{
+				Name: "button", Pin: "15", Type: rpiutils.PinInterrupt, Edge: rpiutils.EdgeBoth, PullState: rpiutils.PullDown,
+				DebounceMode: rpiutils.DebounceModeGlitch, DebounceMS: 5,
+			},
+		},
+	}
+	p, daemon := newTestBoard(t, &cfg)
+
+	t.Run("glitch and noise use the filters of the daemon", func(t *testing.T) {
+		glitchUS, noiseSteadyUS, _ := daemon.Filters(22)
+		test.That(t, glitchUS, test.ShouldEqual, 5000)
+		test.That(t, noiseSteadyUS, test.ShouldEqual, 0)
+
+		cfg.Pins[0].DebounceMode = rpiutils.DebounceModeNoise
+		test.That(t, p.Reconfigure(ctx, nil, testBoardConfig(&cfg)), test.ShouldBeNil)
+		glitchUS, noiseSteadyUS, _ = daemon.Filters(22)
+		test.That(t, glitchUS, test.ShouldEqual, 0)
+		test.That(t, noiseSteadyUS, test.ShouldEqual, 5000)
+	})
+
+	t.Run("software reports the level the pin settled on", func(t *testing.T) {
+		cfg.Pins[0].DebounceMode = rpiutils.DebounceModeSoftware
+		cfg.Pins[0].DebounceMS = 50
+		test.That(t, p.Reconfigure(ctx, nil, testBoardConfig(&cfg)), test.ShouldBeNil)
+		glitchUS, noiseSteadyUS, _ := daemon.Filters(22)
+		test.That(t, glitchUS, test.ShouldEqual, 0)
+		test.That(t, noiseSteadyUS, test.ShouldEqual, 0)
+
+		button, err := p.DigitalInterruptByName("button")
+		test.That(t, err, test.ShouldBeNil)
+		ticks := make(chan board.Tick, 10)
+		test.That(t, p.StreamTicks(ctx, []board.DigitalInterrupt{button}, ticks, nil), test.ShouldBeNil)
+
+		// a bouncy press and release
+		daemon.SetLevel(22, true)
+		daemon.SetLevel(22, false)
+		daemon.SetLevel(22, true)
+		daemon.SetLevel(22, false)
+		time.Sleep(100 * time.Millisecond)
+		test.That(t, len(ticks), test.ShouldEqual, 2)
+		test.That(t, (<-ticks).High, test.ShouldBeTrue)
+		test.That(t, (<-ticks).High, test.ShouldBeFalse)
+
+		count, err := button.Value(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, count, test.ShouldEqual, 1)
+	})
+
+	t.Run("close clears the filters", func(t *testing.T) {
+		cfg.Pins[0].DebounceMode = rpiutils.DebounceModeGlitch
+		cfg.Pins[0].DebounceMS = 5
+		test.That(t, p.Reconfigure(ctx, nil, testBoardConfig(&cfg)), test.ShouldBeNil)
+		test.That(t, teardownInterrupts(p), test.ShouldBeNil)
+		glitchUS, _, _ := daemon.Filters(22)
+		test.That(t, glitchUS, test.ShouldEqual, 0)
+	})
+}
+
+func TestInterruptWatchdog(t *testing.T) {
+	ctx := context.Background()
+	cfg := rpiutils.Config{
+		Pins: []rpiutils.PinConfig{
+			// bcom 22
+			{Name: "flow", Pin: "15", Type: rpiutils.PinInterrupt, PullState: rpiutils.PullUp, WatchdogMS: 20},
+		},
+	}
+	p, daemon := newTestBoard(t, &cfg)
+	test.That(t, daemon.Watchdog(22), test.ShouldEqual, 20)
+
+	flow, err := p.DigitalInterruptByName("flow")
+	test.That(t, err, test.ShouldBeNil)
+	ticks := make(chan board.Tick, 10)
+	test.That(t, p.StreamTicks(ctx, []board.DigitalInterrupt{flow}, ticks, nil), test.ShouldBeNil)
+
+	// the watchdog may already have fired while the pin stayed high
+	daemon.SetLevel(22, false)
+	for tick := range ticks {
+		if tick.Name == "flow" {
+			test.That(t, tick.High, test.ShouldBeFalse)
+			break
+		}
+		test.That(t, tick.Name, test.ShouldEqual, "flow"+rpiutils.WatchdogTickSuffix)
+		test.That(t, tick.High, test.ShouldBeTrue)
+	}
+	select {
+	case tick := <-ticks:
+		test.That(t, tick.Name, test.ShouldEqual, "flow"+rpiutils.WatchdogTickSuffix)
+		test.That(t, tick.High, test.ShouldBeFalse)
+	case <-time.After(time.Second):
+		t.Fatal("watchdog did not fire")
+	}
+
+	resp, err := p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: rpiutils.WatchdogTimeoutsCommand, "pin": "15"})
+	test.That(t, err, test.ShouldBeNil)
+	timeouts := resp["timeouts"].(map[string]interface{})
+	test.That(t, timeouts["flow"], test.ShouldBeGreaterThanOrEqualTo, 1)
+
+	_, err = p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: rpiutils.WatchdogTimeoutsCommand, "pin": "16"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	cfg.Pins = nil
+	test.That(t, p.Reconfigure(ctx, nil, testBoardConfig(&cfg)), test.ShouldBeNil)
+	test.That(t, daemon.Watchdog(22), test.ShouldEqual, 0)
+}
+
+func TestStreamTicksQueue(t *testing.T) {
+	ctx := context.Background()
+	cfg := rpiutils.Config{
+		Pins: []rpiutils.PinConfig{
+			// bcom 22
+			{Name: "button", Pin: "15", Type: rpiutils.PinInterrupt, Edge: rpiutils.EdgeRising, PullState: rpiutils.PullDown},
+		},
+		TickQueue: rpiutils.TickQueueConfig{Size: 16},
+	}
+	p, daemon := newTestBoard(t, &cfg)
+
+	button, err := p.DigitalInterruptByName("button")
+	test.That(t, err, test.ShouldBeNil)
+
+	// a client that never reads its ticks
+	streamCtx, cancel := context.WithCancel(ctx)
+	stalled := make(chan board.Tick)
+	test.That(t, p.StreamTicks(streamCtx, []board.DigitalInterrupt{button}, stalled,
+		map[string]interface{}{"tick_queue_size": 2.0, "tick_overflow": "drop_newest"}), test.ShouldBeNil)
+	ticks := make(chan board.Tick, 10)
+	test.That(t, p.StreamTicks(ctx, []board.DigitalInterrupt{button}, ticks, nil), test.ShouldBeNil)
+
+	err = p.StreamTicks(ctx, []board.DigitalInterrupt{button}, ticks, map[string]interface{}{"tick_overflow": "drop_all"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	for range 5 {
+		daemon.SetLevel(22, true)
+		daemon.SetLevel(22, false)
+	}
+	for range 5 {
+		select {
+		case tick := <-ticks:
+			test.That(t, tick.High, test.ShouldBeTrue)
+			// ticks are in Unix time
+			test.That(t, time.Since(time.Unix(0, int64(tick.TimestampNanosec))), test.ShouldBeBetween, 0, time.Second)
+		case <-time.After(time.Second):
+			t.Fatal("the stalled client held up the other one")
+		}
+	}
+
+	resp, err := p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: rpiutils.TickQueueStatsCommand})
+	test.That(t, err, test.ShouldBeNil)
+	subscribers := resp["subscribers"].([]interface{})
+	test.That(t, len(subscribers), test.ShouldEqual, 2)
+	stalledStats := subscribers[0].(map[string]interface{})
+	test.That(t, stalledStats["overflow"], test.ShouldEqual, "drop_newest")
+	test.That(t, stalledStats["interrupts"], test.ShouldResemble, []interface{}{"button"})
+	// at most one tick waits to be sent and two are queued, the others were dropped
+	test.That(t, stalledStats["queued"], test.ShouldBeBetweenOrEqual, 1, 2)
+	test.That(t, stalledStats["dropped"], test.ShouldBeGreaterThanOrEqualTo, uint64(2))
+	readStats := subscribers[1].(map[string]interface{})
+	test.That(t, readStats["size"], test.ShouldEqual, 16)
+	test.That(t, readStats["overflow"], test.ShouldEqual, "drop_oldest")
+	test.That(t, readStats["dropped"], test.ShouldEqual, uint64(0))
+
+	cancel()
+	waitFor(t, "the stalled client to be removed", func() bool {
+		resp, err := p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: rpiutils.TickQueueStatsCommand})
+		test.That(t, err, test.ShouldBeNil)
+		return len(resp["subscribers"].([]interface{})) == 1
+	})
+}
+
+func TestInterruptCounter(t *testing.T) {
+	ctx := context.Background()
+	cfg := rpiutils.Config{
+		Pins: []rpiutils.PinConfig{
+			// bcom 22
+			{
+				Name: "flow", Pin: "15", Type: rpiutils.PinInterrupt, PullState: rpiutils.PullDown,
+				Edge: rpiutils.EdgeRising, CountMode: rpiutils.CountModeBoth,
+			},
+		},
+	}
+	p, daemon := newTestBoard(t, &cfg)
+
+	flow, err := p.DigitalInterruptByName("flow")
+	test.That(t, err, test.ShouldBeNil)
+	ticks := make(chan board.Tick, 10)
+	test.That(t, p.StreamTicks(ctx, []board.DigitalInterrupt{flow}, ticks, nil), test.ShouldBeNil)
+
+	// both edges are counted, and only the rising ones are ticked
+	for range 3 {
+		daemon.SetLevel(22, true)
+		daemon.SetLevel(22, false)
+	}
+	for range 3 {
+		test.That(t, (<-ticks).High, test.ShouldBeTrue)
+	}
+	// the last falling edge may still be on its way
+	waitForValue(t, flow, 6)
+
+	// the three pulses hold two periods
+	resp, err := p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: rpiutils.MeasureCommand, "pin": "15"})
+	test.That(t, err, test.ShouldBeNil)
+	measurement := resp["measurements"].(map[string]interface{})["flow"].(map[string]interface{})
+	test.That(t, measurement["periods"], test.ShouldEqual, 2)
+	test.That(t, measurement["frequency_hz"], test.ShouldBeGreaterThan, 0)
+	period, err := flow.Value(ctx, map[string]interface{}{"measurement": "period_ns"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, period, test.ShouldBeGreaterThan, 0)
+
+	// the history holds the ticks of the rising edges
+	resp, err = p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: rpiutils.TickHistoryCommand})
+	test.That(t, err, test.ShouldBeNil)
+	history := resp["ticks"].(map[string]interface{})["flow"].([]interface{})
+	test.That(t, len(history), test.ShouldEqual, 3)
+	first := history[0].(map[string]interface{})
+	test.That(t, first["high"], test.ShouldBeTrue)
+	since := strconv.FormatUint(first["timestamp_ns"].(uint64), 10)
+	resp, err = p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: rpiutils.TickHistoryCommand, "pin": "flow", "since": since})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(resp["ticks"].(map[string]interface{})["flow"].([]interface{})), test.ShouldEqual, 2)
+
+	resp, err = p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: rpiutils.ResetCounterCommand, "pin": "flow", "preset": 100.0})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["previous"], test.ShouldResemble, map[string]interface{}{"flow": int64(6)})
+	count, err := flow.Value(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 100)
+
+	resp, err = p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: rpiutils.ResetCounterCommand})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["previous"], test.ShouldResemble, map[string]interface{}{"flow": int64(100)})
+	count, err = flow.Value(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 0)
+
+	_, err = p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: rpiutils.ResetCounterCommand, "pin": "16"})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestPersistedInterrupt(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("VIAM_MODULE_DATA", t.TempDir())
+
+	cfg := rpiutils.Config{
+		Pins: []rpiutils.PinConfig{
+			// bcom 22
+			{Name: "flow", Pin: "15", Type: rpiutils.PinInterrupt, PullState: rpiutils.PullDown, Persist: true},
+		},
+	}
+	p, daemon := newTestBoard(t, &cfg)
+	_, err := p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: rpiutils.ResetCounterCommand, "preset": 1000.0})
+	test.That(t, err, test.ShouldBeNil)
+	flow, err := p.DigitalInterruptByName("flow")
+	test.That(t, err, test.ShouldBeNil)
+	ticks := make(chan board.Tick, 10)
+	test.That(t, p.StreamTicks(ctx, []board.DigitalInterrupt{flow}, ticks, nil), test.ShouldBeNil)
+	daemon.SetLevel(22, true)
+	<-ticks
+	test.That(t, p.Close(ctx), test.ShouldBeNil)
+
+	// the count is restored when the board starts again
+	p = newTestBoardOn(t, daemon, &cfg)
+	flow, err = p.DigitalInterruptByName("flow")
+	test.That(t, err, test.ShouldBeNil)
+	count, err := flow.Value(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 1001)
+
+	// and when its interrupt is removed and added again
+	cfg.Pins = nil
+	test.That(t, p.Reconfigure(ctx, nil, testBoardConfig(&cfg)), test.ShouldBeNil)
+	cfg.Pins = []rpiutils.PinConfig{
+		{Name: "flow", Pin: "15", Type: rpiutils.PinInterrupt, PullState: rpiutils.PullDown, Persist: true},
+	}
+	test.That(t, p.Reconfigure(ctx, nil, testBoardConfig(&cfg)), test.ShouldBeNil)
+	flow, err = p.DigitalInterruptByName("flow")
+	test.That(t, err, test.ShouldBeNil)
+	count, err = flow.Value(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 1001)
+}
+
+func TestHighRateInterrupt(t *testing.T) {
+	ctx := context.Background()
+	cfg := rpiutils.Config{
+		Pins: []rpiutils.PinConfig{
+			// bcom 22 and 23
+			{Name: "a", Pin: "15", Type: rpiutils.PinInterrupt, PullState: rpiutils.PullDown, HighRate: true, Edge: rpiutils.EdgeRising},
+			{Name: "b", Pin: "16", Type: rpiutils.PinInterrupt, PullState: rpiutils.PullDown, HighRate: true, CountMode: rpiutils.CountModeBoth},
+		},
+	}
+	p, daemon := newTestBoard(t, &cfg)
+	test.That(t, p.highRate, test.ShouldNotBeNil)
+
+	a, err := p.DigitalInterruptByName("a")
+	test.That(t, err, test.ShouldBeNil)
+	b, err := p.DigitalInterruptByName("b")
+	test.That(t, err, test.ShouldBeNil)
+	ticks := make(chan board.Tick, 1000)
+	test.That(t, p.StreamTicks(ctx, []board.DigitalInterrupt{a}, ticks, nil), test.ShouldBeNil)
+
+	// the changes of both pins come from the same capture, in order
+	for range 500 {
+		daemon.SetLevel(22, true)
+		daemon.SetLevel(23, true)
+		daemon.SetLevel(22, false)
+		daemon.SetLevel(23, false)
+	}
+	waitForValue(t, a, 500)
+	waitForValue(t, b, 1000)
+	var last uint64
+	for range 500 {
+		tick := <-ticks
+		test.That(t, tick.High, test.ShouldBeTrue)
+		test.That(t, tick.TimestampNanosec, test.ShouldBeGreaterThanOrEqualTo, last)
+		last = tick.TimestampNanosec
+	}
+
+	// an interrupt moved to a callback keeps its count
+	cfg.Pins[0].HighRate = false
+	test.That(t, p.Reconfigure(ctx, nil, testBoardConfig(&cfg)), test.ShouldBeNil)
+	test.That(t, p.highRate, test.ShouldNotBeNil)
+	daemon.SetLevel(22, true)
+	daemon.SetLevel(23, true)
+	waitForValue(t, a, 501)
+	waitForValue(t, b, 1001)
+
+	// the capture is closed with the last high rate interrupt
+	cfg.Pins[1].HighRate = false
+	test.That(t, p.Reconfigure(ctx, nil, testBoardConfig(&cfg)), test.ShouldBeNil)
+	test.That(t, p.highRate, test.ShouldBeNil)
+	daemon.SetLevel(23, false)
+	waitForValue(t, b, 1002)
+
+	cfg.Pins[0].HighRate = true
+	test.That(t, p.Reconfigure(ctx, nil, testBoardConfig(&cfg)), test.ShouldBeNil)
+	test.That(t, p.highRate, test.ShouldNotBeNil)
+	daemon.SetLevel(22, false)
+	daemon.SetLevel(22, true)
+	waitForValue(t, a, 502)
+
+	// watchdog reports are passed on by the capture
+	cfg.Pins[0].WatchdogMS = 10
+	test.That(t, p.Reconfigure(ctx, nil, testBoardConfig(&cfg)), test.ShouldBeNil)
+	for tick := range ticks {
+		if tick.Name == "a"+rpiutils.WatchdogTickSuffix {
+			test.That(t, tick.High, test.ShouldBeTrue)
+			break
+		}
+	}
+}
+
+func TestSetClockFrequency(t *testing.T) {
+	ctx := context.Background()
+	cfg := rpiutils.Config{
+		Pins: []rpiutils.PinConfig{
+			{Name: "gpclk", Pin: "7", Type: rpiutils.PinClock, FrequencyHz: 1000000},
+		},
+	}
+	p, _ := newTestBoard(t, &cfg)
+	setFrequency := func(freqHz float64) error {
+		_, err := p.DoCommand(ctx, map[string]interface{}{
+			rpiutils.DoCommandKey: rpiutils.SetClockFrequencyCommand, "pin": "gpclk", "frequency_hz": freqHz,
+		})
+		return err
+	}
+
+	// the clocks of the BCM2711 run faster, but can't go as slow
+	p.hardware = rpiutils.PiHardware{ModelName: "rpi4"}
+	test.That(t, setFrequency(300e6), test.ShouldBeNil)
+	test.That(t, setFrequency(5000), test.ShouldNotBeNil)
+
+	// the fake daemon runs on a pi 4, so only the limits the board checks for older pis can be tried
+	p.hardware = rpiutils.PiHardware{ModelName: "rpi3"}
+	err := setFrequency(300e6)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "4689-250000000 Hz")
+}
+
+func TestStartPigpiodWithRunningDaemon(t *testing.T) {
+	ctx := context.Background()
+	daemon, err := fakepigpiod.New()
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, daemon.Close(), test.ShouldBeNil)
+	}()
+	endpoint := daemon.Endpoint()
+	cfg := rpiutils.Config{PigpiodHost: endpoint.Host, PigpiodPort: endpoint.Port}
+
+	// auto uses the running daemon
+	cfg.Pigpiod = rpiutils.PigpiodConfig{Mode: rpiutils.PigpiodModeAuto, SampleRateUS: 2}
+	managed, err := startPigpiod(ctx, &cfg, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, managed, test.ShouldBeFalse)
+
+	// managed can't start a daemon of its own on the port
+	cfg.Pigpiod = rpiutils.PigpiodConfig{Mode: rpiutils.PigpiodModeManaged}
+	_, err = startPigpiod(ctx, &cfg, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "already running")
+}
+
+func TestSoftUART(t *testing.T) {
+	ctx := context.Background()
+	cfg := rpiutils.Config{
+		Pins: []rpiutils.PinConfig{
+			{Name: "step", Pin: "37", Type: rpiutils.PinGPIO},
+		},
+		SoftUARTs: []rpiutils.SoftUARTConfig{
+			{Name: "loop", TXPin: "16", RXPin: "18", BaudRate: 115200}, // bcom 23 and 24
+		},
+	}
+	p, daemon := newTestBoard(t, &cfg)
+	daemon.Wire(23, 24)
+	do := func(cmd map[string]interface{}) (map[string]interface{}, error) {
+		return p.DoCommand(ctx, cmd)
+	}
+	write := func(data string) error {
+		_, err := do(map[string]interface{}{rpiutils.DoCommandKey: rpiutils.SoftUARTWriteCommand, "name": "loop", "data": data})
+		return err
+	}
+	read := func() string {
+		resp, err := do(map[string]interface{}{rpiutils.DoCommandKey: rpiutils.SoftUARTReadCommand, "name": "loop"})
 		test.That(t, err, test.ShouldBeNil)
-		test.That(t, val, test.ShouldAlmostEqual, int64(1833), 50) // this is a tad noisy
+		return resp["data"].(string)
+	}
+
+	test.That(t, write("hello"), test.ShouldBeNil)
+	test.That(t, read(), test.ShouldEqual, "hello")
+	test.That(t, read(), test.ShouldEqual, "")
+
+	// a write would throw away the pulses of a wave that is being built
+	_, err := do(map[string]interface{}{
+		rpiutils.DoCommandKey: rpiutils.WaveAddGenericCommand,
+		"pulses":              []interface{}{map[string]interface{}{"on": []interface{}{"step"}, "delay_us": 100.0}},
 	})
+	test.That(t, err, test.ShouldBeNil)
+	err = write("lost")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, rpiutils.WaveCreateCommand)
+	resp, err := do(map[string]interface{}{rpiutils.DoCommandKey: rpiutils.WaveCreateCommand})
+	test.That(t, err, test.ShouldBeNil)
+
+	// a repeating wave keeps the daemon busy, and is replaced by the next write
+	_, err = do(map[string]interface{}{rpiutils.DoCommandKey: rpiutils.WaveSendRepeatCommand, "wave_id": resp["wave_id"]})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, write("again"), test.ShouldBeNil)
+	test.That(t, read(), test.ShouldEqual, "again")
+}
+
+func TestReconnect(t *testing.T) {
+	ctx := context.Background()
+	bus := 1
+	cfg := rpiutils.Config{
+		Pins: []rpiutils.PinConfig{
+			{Name: "out", Pin: "37", Type: rpiutils.PinGPIO},                                     // bcom 26
+			{Name: "pwm", Pin: "36", Type: rpiutils.PinGPIO},                                     // bcom 16
+			{Name: "button", Pin: "15", Type: rpiutils.PinInterrupt, PullState: rpiutils.PullUp}, // bcom 22
+		},
+		I2Cs: []rpiutils.I2CConfig{{Name: "i2c1", Bus: &bus}},
+	}
+	daemon, err := fakepigpiod.New()
+	test.That(t, err, test.ShouldBeNil)
+	p := newTestBoardOn(t, daemon, &cfg)
+
+	out, err := p.GPIOPinByName("out")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Set(ctx, true, nil), test.ShouldBeNil)
+	pwm, err := p.GPIOPinByName("pwm")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pwm.SetPWMFreq(ctx, 100, nil), test.ShouldBeNil)
+	test.That(t, pwm.SetPWM(ctx, 0.25, nil), test.ShouldBeNil)
+	duty, pwmRange, freqHz := daemon.PWM(16)
+	i2c, err := p.I2CByName("i2c1")
+	test.That(t, err, test.ShouldBeNil)
+	handle, err := i2c.OpenHandle(0x40)
+	test.That(t, err, test.ShouldBeNil)
+
+	// restart the daemon, which forgets everything the board set up
+	test.That(t, daemon.Close(), test.ShouldBeNil)
+	daemon, err = fakepigpiod.NewOnPort(cfg.PigpiodPort)
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() {
+		test.That(t, p.Close(ctx), test.ShouldBeNil)
+		test.That(t, daemon.Close(), test.ShouldBeNil)
+	})
+	waitForWithin(t, 5*time.Second, "the board to reconnect", func() bool {
+		health, err := p.pigpiodHealthCommand()
+		test.That(t, err, test.ShouldBeNil)
+		return health["reconnects"] == 1 && health["connected"] == true
+	})
+
+	test.That(t, daemon.Mode(26), test.ShouldEqual, pigpio.Output)
+	test.That(t, daemon.Level(26), test.ShouldBeTrue)
+	newDuty, newRange, newFreqHz := daemon.PWM(16)
+	test.That(t, []uint{newDuty, newRange, newFreqHz}, test.ShouldResemble, []uint{duty, pwmRange, freqHz})
+	test.That(t, daemon.Pull(22), test.ShouldEqual, pigpio.PudUp)
+
+	button, err := p.DigitalInterruptByName("button")
+	test.That(t, err, test.ShouldBeNil)
+	before, err := button.Value(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	daemon.SetLevel(22, false)
+	daemon.SetLevel(22, true)
+	waitForValue(t, button, before+1)
+
+	// the device of the open i2c handle was opened again
+	test.That(t, handle.Write(ctx, []byte{1}), test.ShouldBeNil)
+	test.That(t, handle.Close(), test.ShouldBeNil)
+}
+
+func TestTwoBoards(t *testing.T) {
+	ctx := context.Background()
+	newBoard := func() (*piPigpio, *fakepigpiod.Daemon, *rpiutils.Config) {
+		cfg := rpiutils.Config{
+			Pins: []rpiutils.PinConfig{
+				{Name: "i1", Pin: "11", Type: rpiutils.PinInterrupt}, // bcom 17
+			},
+		}
+		p, daemon := newTestBoard(t, &cfg)
+		return p, daemon, &cfg
+	}
+	// pulse ticks the interrupt of a board once, and checks that it counted the tick
+	pulse := func(p *piPigpio, daemon *fakepigpiod.Daemon) {
+		t.Helper()
+		i1, err := p.DigitalInterruptByName("i1")
+		test.That(t, err, test.ShouldBeNil)
+		before, err := i1.Value(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		daemon.SetLevel(17, false)
+		daemon.SetLevel(17, true)
+		waitForValue(t, i1, before+1)
+	}
+
+	first, firstDaemon, _ := newBoard()
+	second, secondDaemon, secondCfg := newBoard()
+	pulse(first, firstDaemon)
+	pulse(second, secondDaemon)
+
+	secondCfg.Pins = append(secondCfg.Pins, rpiutils.PinConfig{Name: "i2", Pin: "13", Type: rpiutils.PinInterrupt})
+	test.That(t, second.Reconfigure(ctx, nil, testBoardConfig(secondCfg)), test.ShouldBeNil)
+	pulse(first, firstDaemon)
+	pulse(second, secondDaemon)
+
+	test.That(t, second.Close(ctx), test.ShouldBeNil)
+	pulse(first, firstDaemon)
+}
+
+func TestWaves(t *testing.T) {
+	ctx := context.Background()
+	cfg := rpiutils.Config{
+		Pins: []rpiutils.PinConfig{
+			{Name: "step", Pin: "37", Type: rpiutils.PinGPIO}, // bcom 26
+		},
+	}
+	p, daemon := newTestBoard(t, &cfg)
+
+	resp, err := p.DoCommand(ctx, map[string]interface{}{
+		rpiutils.DoCommandKey: rpiutils.WaveAddGenericCommand,
+		"pulses": []interface{}{
+			map[string]interface{}{"on": []interface{}{"step"}, "delay_us": 100.0},
+			map[string]interface{}{"off": []interface{}{"step"}, "delay_us": 100.0},
+		},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["pulses"], test.ShouldEqual, 2)
+	test.That(t, daemon.Mode(26), test.ShouldEqual, pigpio.Output)
+	resp, err = p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: rpiutils.WaveCreateCommand})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: rpiutils.WaveSendOnceCommand, "wave_id": resp["wave_id"]})
+	test.That(t, err, test.ShouldBeNil)
+
+	// another client of the daemon creates a wave, which the board must not touch
+	endpoint := daemon.Endpoint()
+	other, err := pigpio.Connect(endpoint.Host, endpoint.Port)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, other.Close(), test.ShouldBeNil)
+	}()
+	test.That(t, other.WaveAddGeneric([]pigpio.Pulse{{On: 1 << 26, DelayUS: 10}}), test.ShouldEqual, 1)
+	otherID := other.WaveCreate()
+	test.That(t, otherID, test.ShouldBeGreaterThanOrEqualTo, 0)
+	test.That(t, daemon.Waves(), test.ShouldEqual, 2)
+	for _, command := range []string{rpiutils.WaveSendOnceCommand, rpiutils.WaveSendRepeatCommand, rpiutils.WaveDeleteCommand} {
+		_, err = p.DoCommand(ctx, map[string]interface{}{rpiutils.DoCommandKey: command, "wave_id": float64(otherID)})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "not created by this board")
+	}
+	_, err = p.DoCommand(ctx, map[string]interface{}{
+		rpiutils.DoCommandKey: rpiutils.WaveChainCommand, "chain": []interface{}{float64(otherID)},
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "not created by this board")
+
+	// closing the board deletes its own waves only
+	test.That(t, p.Close(ctx), test.ShouldBeNil)
+	test.That(t, daemon.Waves(), test.ShouldEqual, 1)
+	test.That(t, other.WaveDelete(uint(otherID)), test.ShouldEqual, 0)
+}
+
+func TestI2CBuses(t *testing.T) {
+	ctx := context.Background()
+	bus := 1
+	cfg := rpiutils.Config{
+		I2Cs: []rpiutils.I2CConfig{
+			{Name: "hw", Bus: &bus},
+			{Name: "bb", BBSDA: "16", BBSCL: "18"},
+		},
+	}
+	p, _ := newTestBoard(t, &cfg)
+
+	for _, name := range []string{"hw", "bb"} {
+		t.Run(name, func(t *testing.T) {
+			i2c, err := p.I2CByName(name)
+			test.That(t, err, test.ShouldBeNil)
+			handle, err := i2c.OpenHandle(0x40)
+			test.That(t, err, test.ShouldBeNil)
+			data, err := handle.ReadBlockData(ctx, 0x10, 2)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, data, test.ShouldResemble, []byte{0, 0})
+
+			// a transaction from another user of the bus waits until the handle is closed
+			done := make(chan error, 1)
+			go func() {
+				_, err := p.DoCommand(ctx, map[string]interface{}{
+					rpiutils.DoCommandKey: rpiutils.I2CTransactionCommand, "bus": name, "address": 0x41, "read": 1.0,
+				})
+				done <- err
+			}()
+			select {
+			case <-done:
+				t.Fatal("transaction did not wait for the open handle")
+			case <-time.After(20 * time.Millisecond):
+			}
+			test.That(t, handle.WriteByteData(ctx, 0x10, 1), test.ShouldBeNil)
+			test.That(t, handle.Close(), test.ShouldBeNil)
+			select {
+			case err := <-done:
+				test.That(t, err, test.ShouldBeNil)
+			case <-time.After(time.Second):
+				t.Fatal("transaction did not run once the handle was closed")
+			}
+		})
+	}
+}
+
+func TestSoftSPIAnalog(t *testing.T) {
+	ctx := context.Background()
+	cfg := rpiutils.Config{
+		SoftSPIs: []rpiutils.SoftSPIConfig{{Name: "adcs", MISO: "21", MOSI: "19", SCLK: "23"}},
+		AnalogReaders: []mcp3008helper.MCP3008AnalogConfig{
+			{Name: "a0", SPIBus: "adcs", ChipSelect: "29", Channel: "0"},
+			{Name: "a3", SPIBus: "adcs", ChipSelect: "29", Channel: "3"},
+		},
+	}
+	p, daemon := newTestBoard(t, &cfg)
+
+	// an mcp3008 on gpio 5 (pin 29) answers each single ended read with the value of its channel
+	values := []int{512, 0, 0, 1023}
+	daemon.ConnectSPIDevice(5, func(tx []byte) []byte {
+		if len(tx) != 3 || tx[0] != 1 || tx[1]&0x80 == 0 {
+			return make([]byte, len(tx))
+		}
+		value := values[(tx[1]>>4)&7]
+		return []byte{0, byte(value >> 8), byte(value)}
+	})
+
+	for name, want := range map[string]int{"a0": 512, "a3": 1023} {
+		analog, err := p.AnalogByName(name)
+		test.That(t, err, test.ShouldBeNil)
+		// the smoother reads the adc in the background, so the first value takes a moment
+		waitFor(t, fmt.Sprintf("%s to read %d", name, want), func() bool {
+			value, err := analog.Read(ctx, nil)
+			return err == nil && value.Value == want
+		})
+	}
 }
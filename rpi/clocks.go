@@ -0,0 +1,98 @@
+package rpi
+
+/*
+	clocks.go: Drives pins configured as `clock` with the general purpose clocks (GPCLK).
+*/
+
+import (
+	"github.com/pkg/errors"
+	"raspberry-pi/pigpio"
+	rpiutils "raspberry-pi/utils"
+)
+
+// reconfigureClocks starts the general purpose clock on every pin configured as `clock`. Pins that
+// were previously configured as `clock` but no longer are have their clock stopped and are returned
+// to inputs.
+func (pi *piPigpio) reconfigureClocks(cfg *rpiutils.Config) error {
+	newClocks := map[uint]uint{}
+	for _, newConfig := range cfg.Pins {
+		if newConfig.Type != rpiutils.PinClock {
+			continue
+		}
+		bcom, ok := rpiutils.BroadcomPinFromHardwareLabel(newConfig.Pin)
+		if !ok {
+			return errors.Errorf("no hw pin for (%s)", newConfig.Pin)
+		}
+		// validation only lets a clock without a frequency through on the Pi 5
+		if newConfig.FrequencyHz == 0 {
+			return errors.Errorf("clock pin %s needs a frequency_hz on the Pi 0-4", newConfig.Name)
+		}
+		newClocks[bcom] = newConfig.FrequencyHz
+	}
+
+	for bcom := range pi.clocks {
+		if _, ok := newClocks[bcom]; ok {
+			continue
+		}
+		if res := pi.daemon.HardwareClock(bcom, 0); res != 0 {
+			return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to stop clock")
+		}
+		if res := pi.daemon.SetMode(bcom, pigpio.Input); res != 0 {
+			return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to set mode")
+		}
+		delete(pi.clocks, bcom)
+	}
+
+	for bcom, freqHz := range newClocks {
+		if err := pi.setClockFrequency(bcom, freqHz); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setClockFrequency starts or retunes the general purpose clock on a pin.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) setClockFrequency(bcom, freqHz uint) error {
+	if res := pi.daemon.HardwareClock(bcom, freqHz); res != 0 {
+		return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to set clock frequency")
+	}
+	pi.clocks[bcom] = freqHz
+	pi.logger.Debugf("set clock on gpio %d to %d Hz", bcom, freqHz)
+	return nil
+}
+
+// setClockFrequencyCommand handles the set_clock_frequency DoCommand, which retunes a pin that is
+// already configured as a clock.
+func (pi *piPigpio) setClockFrequencyCommand(cmd map[string]interface{}) (map[string]interface{}, error) {
+	pin, err := rpiutils.PinFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if pin == "" {
+		return nil, errors.New("missing \"pin\"")
+	}
+	freqHz, err := rpiutils.UintFromCommand(cmd, "frequency_hz")
+	if err != nil {
+		return nil, err
+	}
+	// the pi is told apart like in hardwarePWMClockHz
+	if err := rpiutils.ValidateClockFrequency(freqHz, pi.hardware); err != nil {
+		return nil, err
+	}
+
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+
+	bcom, ok := pi.broadcomFromName(pin)
+	if !ok {
+		return nil, errors.Errorf("no hw pin for (%s)", pin)
+	}
+	if _, ok := pi.clocks[bcom]; !ok {
+		return nil, errors.Errorf("pin %s is not configured as a clock", pin)
+	}
+	if err := pi.setClockFrequency(bcom, freqHz); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"frequency_hz": freqHz}, nil
+}
@@ -0,0 +1,174 @@
+package rpi
+
+/*
+	daemon.go: Starts and supervises the pigpio daemon when the module manages it. The daemon is
+	restarted if it exits, after which the connection supervisor reconnects the boards using it.
+	Only one daemon can run on a Pi, so every board managing it has to use the same options.
+	Details on the daemon's options can be found here -> https://abyz.me.uk/rpi/pigpio/pigpiod.html
+*/
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/utils/pexec"
+	rpiutils "raspberry-pi/utils"
+)
+
+const (
+	// pigpiodStartTimeout is how long a daemon started by the module gets to accept connections.
+	pigpiodStartTimeout = 10 * time.Second
+	// pigpiodDialTimeout is how long to wait for an existing daemon to accept a connection.
+	pigpiodDialTimeout = 500 * time.Millisecond
+)
+
+// managedPigpiod is a pigpio daemon run by the module, shared by every board that manages it.
+type managedPigpiod struct {
+	process pexec.ManagedProcess
+	config  rpiutils.PigpiodConfig
+	port    int
+	refs    int
+}
+
+var (
+	managedDaemonMu sync.Mutex
+	managedDaemon   *managedPigpiod // protected by managedDaemonMu
+)
+
+// startPigpiod makes sure a daemon is running for the board if the config asks the module to manage
+// it. It returns whether the board uses the daemon managed by the module, in which case
+// releasePigpiod must be called once the board is done with it.
+func startPigpiod(ctx context.Context, cfg *rpiutils.Config, logger logging.Logger) (bool, error) {
+	endpoint := cfg.PigpiodEndpoint()
+	mode := cfg.Pigpiod.ModeOrDefault()
+	if mode == rpiutils.PigpiodModeExternal || !endpoint.IsLocal() {
+		return false, nil
+	}
+	port := endpoint.Resolved().Port
+
+	managedDaemonMu.Lock()
+	defer managedDaemonMu.Unlock()
+
+	if managedDaemon != nil {
+		if managedDaemon.port != port || !managedDaemon.config.Equals(cfg.Pigpiod) {
+			return false, errors.New("pigpiod is already started by another board with different options, " +
+				"only one pigpiod can run on a pi")
+		}
+		managedDaemon.refs++
+		return true, nil
+	}
+
+	if pigpiodReachable(endpoint) {
+		if mode == rpiutils.PigpiodModeManaged {
+			// a new daemon could not bind the port, and the board would use the running one with
+			// whatever options it was started with
+			return false, errors.Errorf("pigpiod is already running at %s, stop it or use the auto or external pigpiod mode",
+				endpoint)
+		}
+		logger.CInfof(ctx, "using the pigpiod that is already running at %s", endpoint)
+		if cfg.Pigpiod.SampleRateUS != 0 || cfg.Pigpiod.ClockPeripheral != rpiutils.ClockPeripheralDefault ||
+			cfg.Pigpiod.DMAChannel != nil || cfg.Pigpiod.Path != "" {
+			logger.CWarn(ctx, "pigpiod was not started by the module, so sample_rate_us, clock_peripheral, "+
+				"dma_channel and path are ignored by it, and the board assumes it runs with the configured "+
+				"sample_rate_us and clock_peripheral")
+		}
+		return false, nil
+	}
+
+	path, err := pigpiodPath(cfg.Pigpiod.Path)
+	if err != nil {
+		return false, err
+	}
+	process := pexec.NewManagedProcess(pexec.ProcessConfig{
+		ID:   "pigpiod",
+		Name: path,
+		Args: cfg.Pigpiod.Args(port),
+		Log:  true,
+	}, logger)
+	if err := process.Start(ctx); err != nil {
+		return false, errors.Wrapf(err, "failed to start %s", path)
+	}
+	if err := waitForPigpiod(ctx, endpoint); err != nil {
+		return false, multierr.Combine(err, process.Stop())
+	}
+	logger.CInfof(ctx, "started pigpiod at %s with a sample rate of %dus", endpoint, cfg.Pigpiod.SampleRateOrDefault())
+
+	managedDaemon = &managedPigpiod{process: process, config: cfg.Pigpiod, port: port, refs: 1}
+	return true, nil
+}
+
+// releasePigpiod stops the daemon managed by the module once no board uses it anymore.
+func releasePigpiod() error {
+	managedDaemonMu.Lock()
+	defer managedDaemonMu.Unlock()
+	if managedDaemon == nil {
+		return nil
+	}
+	managedDaemon.refs--
+	if managedDaemon.refs > 0 {
+		return nil
+	}
+	err := managedDaemon.process.Stop()
+	managedDaemon = nil
+	return err
+}
+
+// pigpiodPath returns the daemon binary to start: the configured one, the one bundled with the
+// module, or else the one installed on the system.
+func pigpiodPath(configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+	if exe, err := os.Executable(); err == nil {
+		arch := "arm"
+		if runtime.GOARCH == "arm64" {
+			arch = "arm64"
+		}
+		bundled := filepath.Join(filepath.Dir(exe), "pigpiod-"+arch, "pigpiod")
+		if _, err := os.Stat(bundled); err == nil {
+			return bundled, nil
+		}
+	}
+	path, err := exec.LookPath("pigpiod")
+	if err != nil {
+		return "", errors.New("cannot find pigpiod, install it or set pigpiod.path")
+	}
+	return path, nil
+}
+
+// pigpiodReachable returns whether a daemon accepts connections at the endpoint.
+func pigpiodReachable(endpoint rpiutils.PigpiodEndpoint) bool {
+	conn, err := net.DialTimeout("tcp", endpoint.String(), pigpiodDialTimeout)
+	if err != nil {
+		return false
+	}
+	//nolint:errcheck  // nothing was sent, so there is nothing to flush
+	conn.Close()
+	return true
+}
+
+// waitForPigpiod waits until a freshly started daemon accepts connections.
+func waitForPigpiod(ctx context.Context, endpoint rpiutils.PigpiodEndpoint) error {
+	ctx, cancel := context.WithTimeout(ctx, pigpiodStartTimeout)
+	defer cancel()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for !pigpiodReachable(endpoint) {
+		select {
+		case <-ctx.Done():
+			return errors.Errorf("pigpiod did not accept connections at %s within %v, is another pigpiod already running?",
+				endpoint, pigpiodStartTimeout)
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -4,19 +4,15 @@ package rpi
 	gpio.go: Implements GPIO functionality on Raspberry Pi.
 */
 
-// #include <stdlib.h>
-// #include <pigpiod_if2.h>
-// #include "pi.h"
-// #cgo LDFLAGS: -lpigpiod_if2
-import "C"
-
 import (
 	"context"
 	"fmt"
+	"math"
 
 	"github.com/pkg/errors"
 	"go.viam.com/rdk/components/board"
 	rdkutils "go.viam.com/rdk/utils"
+	"raspberry-pi/pigpio"
 	rpiutils "raspberry-pi/utils"
 )
 
@@ -36,6 +32,18 @@ type rpiGPIO struct {
 	pin           uint
 	configuration GPIOConfig
 	pwmEnabled    bool
+
+	// hardwarePWM is set for pins driven by the PWM peripheral instead of software PWM. pigpio sets
+	// the frequency and duty cycle of hardware PWM together, so we track the requested values.
+	hardwarePWM  bool
+	hwPWMFreqHz  uint
+	hwPWMDutyPPM uint32 // duty cycle in parts per million, as used by hardware_PWM
+
+	// the last level and software pwm settings written to the pin, restored after reconnecting to
+	// the daemon
+	outputHigh bool
+	pwmDuty    uint // software pwm duty cycle out of 255
+	pwmFreqHz  uint // software pwm frequency, 0 if it was never set
 }
 
 // GPIOPinByName returns a GPIOPin by name.
@@ -96,8 +104,11 @@ func (gp gpioPin) SetPWMFreq(ctx context.Context, freqHz uint, extra map[string]
 }
 
 func (pi *piPigpio) reconfigureGPIOs(cfg *rpiutils.Config) error {
+	oldPins := pi.gpioPins
+
 	// Set new pins based on config
 	pi.gpioPins = map[int]*rpiGPIO{}
+	usedPWMChannels := map[int]bool{}
 	for _, newConfig := range cfg.Pins {
 		if newConfig.Type != rpiutils.PinGPIO {
 			continue
@@ -106,12 +117,58 @@ func (pi *piPigpio) reconfigureGPIOs(cfg *rpiutils.Config) error {
 		if !have {
 			return errors.Errorf("no hw pin for (%s)", newConfig.Pin)
 		}
-		pin := &rpiGPIO{name: newConfig.Name, pin: bcom}
+		pin := &rpiGPIO{name: newConfig.Name, pin: bcom, hwPWMFreqHz: rpiutils.DefaultPWMFreqHz}
+
+		channel, supportsHardwarePWM := rpiutils.HardwarePWMChannel(bcom)
+		// the PWM peripheral cannot generate pwm while the daemon uses it as its clock
+		supportsHardwarePWM = supportsHardwarePWM && !pi.pigpiod.UsesPWMClock()
+		switch newConfig.PWMMode {
+		case rpiutils.PWMModeHardware:
+			if !supportsHardwarePWM {
+				return errors.Errorf("pin %s does not support hardware pwm", newConfig.Name)
+			}
+			if usedPWMChannels[channel] {
+				return errors.Errorf("pin %s cannot use hardware pwm channel %d, it is already in use", newConfig.Name, channel)
+			}
+			pin.hardwarePWM = true
+		case rpiutils.PWMModeAuto:
+			pin.hardwarePWM = supportsHardwarePWM && !usedPWMChannels[channel]
+		case rpiutils.PWMModeDefault, rpiutils.PWMModeSoftware:
+		}
+		if pin.hardwarePWM {
+			usedPWMChannels[channel] = true
+		}
 		pi.gpioPins[int(bcom)] = pin
 	}
+
+	// stop hardware pwm on pins that are no longer using it, otherwise the peripheral keeps running
+	for bcom, oldPin := range oldPins {
+		if !oldPin.hardwarePWM || !oldPin.pwmEnabled {
+			continue
+		}
+		if newPin, ok := pi.gpioPins[bcom]; ok && newPin.hardwarePWM {
+			newPin.pwmEnabled = true
+			newPin.configuration = GPIOPWM
+			newPin.hwPWMFreqHz = oldPin.hwPWMFreqHz
+			newPin.hwPWMDutyPPM = oldPin.hwPWMDutyPPM
+			continue
+		}
+		if res := pi.daemon.HardwarePWM(uint(bcom), 0, 0); res != 0 {
+			return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to stop hardware pwm")
+		}
+	}
 	return nil
 }
 
+// hardwarePWMClockHz is the clock the PWM peripheral divides down from, see hardware_PWM in pigpio.
+func (pi *piPigpio) hardwarePWMClockHz() float64 {
+	// the BCM2711 in the Pi 4 family runs the PWM peripheral from a faster clock
+	if pi.hardware.ModelName == "rpi4" {
+		return 375e6
+	}
+	return 250e6
+}
+
 // GetGPIOBcom gets the level of the given broadcom pin
 func (pi *piPigpio) GetGPIOBcom(bcom int) (bool, error) {
 	pi.mu.Lock()
@@ -124,7 +181,7 @@ func (pi *piPigpio) GetGPIOBcom(bcom int) (bool, error) {
 	}
 	// configure the pin to be an input if it is not already an input
 	if pin.configuration != GPIOInput {
-		res := C.set_mode(pi.piID, C.uint(pin.pin), C.PI_INPUT)
+		res := pi.daemon.SetMode(pin.pin, pigpio.Input)
 		if res != 0 {
 			return false, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to set mode")
 		}
@@ -132,7 +189,7 @@ func (pi *piPigpio) GetGPIOBcom(bcom int) (bool, error) {
 	pin.configuration = GPIOInput
 
 	// gpioRead retrns an int 1 or 0, we convert to a bool
-	return C.gpio_read(pi.piID, C.uint(bcom)) != 0, nil
+	return pi.daemon.Read(uint(bcom)) != 0, nil
 }
 
 // SetGPIOBcom sets the given broadcom pin to high or low.
@@ -149,28 +206,38 @@ func (pi *piPigpio) SetGPIOBcom(bcom int, high bool) error {
 	if pin.configuration != GPIOOutput {
 		// first if the pin was configured for pwm, we should turn off the pwm
 		if pin.pwmEnabled {
-			res := C.set_PWM_dutycycle(pi.piID, C.uint(pin.pin), C.uint(0))
+			var res int
+			if pin.hardwarePWM {
+				res = pi.daemon.HardwarePWM(pin.pin, 0, 0)
+			} else {
+				res = pi.daemon.SetPWMDutycycle(pin.pin, 0)
+			}
 			if res != 0 {
 				return errors.Errorf("pwm set fail %d", res)
 			}
 			pin.pwmEnabled = false
 		}
-		res := C.set_mode(pi.piID, C.uint(pin.pin), C.PI_OUTPUT)
+		res := pi.daemon.SetMode(pin.pin, pigpio.Output)
 		if res != 0 {
 			return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to set mode")
 		}
+		pin.configuration = GPIOOutput
 	}
 
-	v := 0
+	var v uint
 	if high {
 		v = 1
 	}
-	C.gpio_write(pi.piID, C.uint(pin.pin), C.uint(v))
+	pi.daemon.Write(pin.pin, v)
+	pin.outputHigh = high
 
 	return nil
 }
 
 func (pi *piPigpio) pwmBcom(bcom int) (float64, error) {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+
 	// verify we are currently managing this pin via GPIOPinByName or reconfigure
 	pin, ok := pi.gpioPins[bcom]
 	if !ok {
@@ -180,8 +247,25 @@ func (pi *piPigpio) pwmBcom(bcom int) (float64, error) {
 		pi.logger.Debugf("pin %v is currently not configured as pwm", bcom)
 		return 0, nil
 	}
-	res := C.get_PWM_dutycycle(pi.piID, C.uint(pin.pin))
-	return float64(res) / 255, nil
+	res := pi.daemon.GetPWMDutycycle(pin.pin)
+	if res < 0 {
+		return 0, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to get pwm duty cycle")
+	}
+	if pin.hardwarePWM {
+		realRange := pi.daemon.GetPWMRealRange(pin.pin)
+		if realRange <= 0 {
+			return 0, rpiutils.ConvertErrorCodeToMessage(int(realRange), "failed to get pwm range")
+		}
+		// hardware pwm reports the duty cycle out of 1M, but the peripheral can only output whole
+		// steps of its real range
+		steps := uint64(res) * uint64(realRange) / 1e6
+		return float64(steps) / float64(realRange), nil
+	}
+	pwmRange := pi.daemon.GetPWMRange(pin.pin)
+	if pwmRange <= 0 {
+		return 0, rpiutils.ConvertErrorCodeToMessage(int(pwmRange), "failed to get pwm range")
+	}
+	return float64(res) / float64(pwmRange), nil
 }
 
 // SetPWMBcom sets the given broadcom pin to the given PWM duty cycle.
@@ -193,10 +277,20 @@ func (pi *piPigpio) SetPWMBcom(bcom int, dutyCyclePct float64) error {
 		return fmt.Errorf("error getting GPIO pin, pin %v not found", bcom)
 	}
 
-	dutyCycle := rdkutils.ScaleByPct(255, dutyCyclePct)
-	res := C.set_PWM_dutycycle(pi.piID, C.uint(pin.pin), C.uint(dutyCycle))
-	if res != 0 {
-		return errors.Errorf("pwm set fail %d", res)
+	if pin.hardwarePWM {
+		dutyPPM := uint32(rdkutils.ScaleByPct(1e6, dutyCyclePct))
+		res := pi.daemon.HardwarePWM(pin.pin, pin.hwPWMFreqHz, dutyPPM)
+		if res != 0 {
+			return rpiutils.ConvertErrorCodeToMessage(int(res), "hardware pwm set failed")
+		}
+		pin.hwPWMDutyPPM = dutyPPM
+	} else {
+		dutyCycle := rdkutils.ScaleByPct(255, dutyCyclePct)
+		res := pi.daemon.SetPWMDutycycle(pin.pin, uint(dutyCycle))
+		if res != 0 {
+			return errors.Errorf("pwm set fail %d", res)
+		}
+		pin.pwmDuty = uint(dutyCycle)
 	}
 	pin.configuration = GPIOPWM
 	pin.pwmEnabled = true
@@ -204,7 +298,26 @@ func (pi *piPigpio) SetPWMBcom(bcom int, dutyCyclePct float64) error {
 }
 
 func (pi *piPigpio) pwmFreqBcom(bcom int) (uint, error) {
-	res := C.get_PWM_frequency(pi.piID, C.uint(bcom))
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+
+	if pin, ok := pi.gpioPins[bcom]; ok && pin.hardwarePWM {
+		if !pin.pwmEnabled {
+			return pin.hwPWMFreqHz, nil
+		}
+		// the peripheral divides its clock by a whole number of steps, so the frequency it outputs
+		// can differ slightly from the requested one
+		realRange := pi.daemon.GetPWMRealRange(uint(bcom))
+		if realRange <= 0 {
+			return 0, rpiutils.ConvertErrorCodeToMessage(int(realRange), "failed to get pwm range")
+		}
+		return uint(math.Round(pi.hardwarePWMClockHz() / float64(realRange))), nil
+	}
+
+	res := pi.daemon.GetPWMFrequency(uint(bcom))
+	if res < 0 {
+		return 0, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to get pwm freq")
+	}
 	return uint(res), nil
 }
 
@@ -215,14 +328,30 @@ func (pi *piPigpio) SetPWMFreqBcom(bcom int, freqHz uint) error {
 	if freqHz == 0 {
 		freqHz = rpiutils.DefaultPWMFreqHz
 	}
-	newRes := C.set_PWM_frequency(pi.piID, C.uint(bcom), C.uint(freqHz))
 
-	if newRes == C.PI_BAD_USER_GPIO {
+	if pin, ok := pi.gpioPins[bcom]; ok && pin.hardwarePWM {
+		if pin.pwmEnabled {
+			res := pi.daemon.HardwarePWM(uint(bcom), freqHz, pin.hwPWMDutyPPM)
+			if res != 0 {
+				return rpiutils.ConvertErrorCodeToMessage(int(res), "hardware pwm set freq failed")
+			}
+		}
+		pin.hwPWMFreqHz = freqHz
+		return nil
+	}
+
+	newRes := pi.daemon.SetPWMFrequency(uint(bcom), freqHz)
+
+	if newRes == pigpio.BadUserGPIO {
 		return rpiutils.ConvertErrorCodeToMessage(int(newRes), "pwm set freq failed")
 	}
 
-	if newRes != C.int(freqHz) {
-		pi.logger.Infof("cannot set pwm freq to %d, setting to closest freq %d", freqHz, newRes)
+	if pin, ok := pi.gpioPins[bcom]; ok && newRes > 0 {
+		pin.pwmFreqHz = uint(newRes)
+	}
+	if newRes != int(freqHz) {
+		pi.logger.Infof("cannot set pwm freq to %d, setting to closest freq %d. At a sample rate of %dus, the supported freqs are %v",
+			freqHz, newRes, pi.pigpiod.SampleRateOrDefault(), rpiutils.PWMFrequencies(pi.pigpiod.SampleRateOrDefault()))
 	}
 	return nil
 }
@@ -0,0 +1,153 @@
+package rpi
+
+/*
+	high_rate.go: Captures the changes of interrupt pins with high_rate: true, such as fast
+	encoders. Rather than a callback per pin, which the client dispatches one change at a time,
+	the board opens a notification of its own for the bit mask of these pins and reads its reports
+	in batches, decoding the changes of every pin in Go. The changes are handled like those of the
+	callbacks: they go through the debouncer of their interrupt, and watchdog reports are passed on.
+*/
+
+import (
+	mathbits "math/bits"
+	"sync"
+
+	"raspberry-pi/pigpio"
+)
+
+// highRateBatchSize is the largest number of reports decoded at once.
+const highRateBatchSize = 1024
+
+// highRateCapture reads the changes of the high rate interrupts of a board from a notification.
+type highRateCapture struct {
+	notification *pigpio.Notification
+	done         chan struct{}
+
+	// mu is held while a batch of reports is decoded, so removing an interrupt waits for the batch
+	mu         sync.Mutex
+	interrupts map[uint]*rpiInterrupt // by broadcom pin
+	levels     uint32                 // the levels of gpios 0-31 in the last report
+}
+
+// bits returns the gpios the capture watches.
+// The capture mutex should be locked before calling this.
+func (c *highRateCapture) bits() uint32 {
+	var bits uint32
+	for bcom := range c.interrupts {
+		bits |= 1 << bcom
+	}
+	return bits
+}
+
+// addHighRateInterrupt starts capturing the changes of an interrupt pin. The notification of the
+// capture is opened with the first high rate interrupt. It returns the result code of the daemon.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) addHighRateInterrupt(bcom uint, interrupt *rpiInterrupt) int {
+	if pi.highRate == nil {
+		notification, res := pi.daemon.NotifyOpen()
+		if res != 0 {
+			return res
+		}
+		pi.highRate = &highRateCapture{
+			notification: notification,
+			done:         make(chan struct{}),
+			interrupts:   map[uint]*rpiInterrupt{},
+		}
+		go pi.captureHighRate(pi.highRate)
+	}
+
+	c := pi.highRate
+	level := pi.daemon.Read(bcom)
+	res := level
+	if level >= 0 {
+		// the daemon did not report the changes of the pin while nothing watched it, so its edges are
+		// found from its level now
+		c.mu.Lock()
+		c.levels = c.levels&^(1<<bcom) | uint32(level)<<bcom
+		c.interrupts[bcom] = interrupt
+		res = pi.daemon.NotifyBegin(c.notification, c.bits())
+		c.mu.Unlock()
+	}
+	if res != 0 {
+		pi.removeHighRateInterrupt(bcom)
+		return res
+	}
+	return 0
+}
+
+// removeHighRateInterrupt stops capturing the changes of an interrupt pin, and closes the capture
+// once no interrupt is left. Once it returns, the interrupt won't be called back again. Like
+// CallbackCancel, it succeeds even if the daemon can no longer be told.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) removeHighRateInterrupt(bcom uint) {
+	c := pi.highRate
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.interrupts, bcom)
+	bits := c.bits()
+	c.mu.Unlock()
+	if bits != 0 {
+		pi.daemon.NotifyBegin(c.notification, bits)
+		return
+	}
+	pi.highRate = nil
+	pi.daemon.NotifyClose(c.notification)
+	<-c.done
+}
+
+// captureHighRate passes the changes reported to a capture on to their interrupts, until its
+// notification is closed.
+func (pi *piPigpio) captureHighRate(c *highRateCapture) {
+	defer close(c.done)
+	reports := make([]pigpio.Report, highRateBatchSize)
+	for {
+		n, err := c.notification.Read(reports)
+		pi.dispatchHighRate(c, reports[:n])
+		if err != nil {
+			return
+		}
+	}
+}
+
+// dispatchHighRate passes the changes in a batch of reports to the interrupts of their pins, in the
+// order they happened. Like interruptCallback, it doesn't lock the board.
+func (pi *piPigpio) dispatchHighRate(c *highRateCapture, reports []pigpio.Report) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range reports {
+		switch {
+		case r.Flags == 0:
+			changed := r.Levels ^ c.levels
+			c.levels = r.Levels
+			if changed == 0 {
+				continue
+			}
+			nanoseconds := pi.tickClock.Nanoseconds(r.Tick)
+			for changed != 0 {
+				bcom := uint(mathbits.TrailingZeros32(changed))
+				changed &^= 1 << bcom
+				if interrupt, ok := c.interrupts[bcom]; ok {
+					interrupt.debouncer.Change(r.Levels&(1<<bcom) != 0, nanoseconds)
+				}
+			}
+		case r.Flags&pigpio.NotifyFlagWatchdog != 0:
+			if interrupt, ok := c.interrupts[uint(r.Flags&pigpio.NotifyGPIOMask)]; ok {
+				pi.interruptTimeout(interrupt, pi.tickClock.Nanoseconds(r.Tick))
+			}
+		}
+	}
+}
+
+// cancelInterruptCallback stops calling back an interrupt, whether its changes come from a callback
+// or from the high rate capture. It returns the result code of the daemon.
+// The board mutex should be locked before calling this, and before the config of the interrupt
+// changes.
+func (pi *piPigpio) cancelInterruptCallback(bcom uint, interrupt *rpiInterrupt) int {
+	if interrupt.cfg.HighRate {
+		pi.removeHighRateInterrupt(bcom)
+		return 0
+	}
+	return pi.daemon.CallbackCancel(interrupt.callbackID)
+}
@@ -0,0 +1,310 @@
+package rpi
+
+/*
+	i2c_buses.go: I2C buses provided by the board through the pigpio daemon, either on one of the
+	hardware buses -> https://abyz.me.uk/rpi/pigpio/pdif2.html#i2c_open
+	or bit-banged on arbitrary GPIOs -> https://abyz.me.uk/rpi/pigpio/pdif2.html#bb_i2c_zip
+*/
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/components/board/genericlinux/buses"
+	rpiutils "raspberry-pi/utils"
+)
+
+// pigpioI2CBus is an i2c bus driven by the pigpio daemon. Opening a handle locks the bus until the
+// handle is closed, so transactions from different components never interleave.
+type pigpioI2CBus struct {
+	pi  *piPigpio
+	cfg rpiutils.I2CConfig
+	sda uint // data pin of a bit-banged bus
+	scl uint // clock pin of a bit-banged bus
+
+	mu     sync.Mutex // held while a handle is open
+	closed bool       // protected by the board mutex
+	// handle is the open handle of a hardware bus, protected by the board mutex
+	handle *pigpioI2CHandle
+}
+
+// reconfigureI2Cs opens every configured i2c bus. Buses whose config did not change are kept, so
+// components holding them keep working, while removed or changed ones are closed first.
+func (pi *piPigpio) reconfigureI2Cs(cfg *rpiutils.Config) error {
+	newConfigs := map[string]rpiutils.I2CConfig{}
+	for _, c := range cfg.I2Cs {
+		newConfigs[c.Name] = c
+	}
+
+	for name, bus := range pi.i2cBuses {
+		if newConfig, ok := newConfigs[name]; ok && newConfig.Equals(bus.cfg) {
+			continue
+		}
+		if err := bus.close(); err != nil {
+			return err
+		}
+		delete(pi.i2cBuses, name)
+	}
+
+	for name, c := range newConfigs {
+		if _, ok := pi.i2cBuses[name]; ok {
+			continue
+		}
+		bus := &pigpioI2CBus{pi: pi, cfg: c}
+		if c.Bus == nil {
+			sda, okSDA := rpiutils.BroadcomPinFromHardwareLabel(c.BBSDA)
+			scl, okSCL := rpiutils.BroadcomPinFromHardwareLabel(c.BBSCL)
+			if !okSDA || !okSCL {
+				return errors.Errorf("no hw pins for i2c bus (%s)", name)
+			}
+			bus.sda, bus.scl = sda, scl
+		}
+		if err := bus.open(); err != nil {
+			return err
+		}
+		pi.i2cBuses[name] = bus
+	}
+	return nil
+}
+
+// open claims the pins of a bit-banged bus. Hardware buses are opened per handle instead. It is also
+// used to restore the bus after reconnecting to the daemon, which opens the device of the handle
+// of a hardware bus again, since the daemon forgot its handles. A handle that can't be opened again
+// fails from then on.
+// The board mutex should be locked before calling this.
+func (bus *pigpioI2CBus) open() error {
+	if bus.closed {
+		return nil
+	}
+	if bus.cfg.Bus != nil {
+		if bus.handle == nil {
+			return nil
+		}
+		res := bus.pi.daemon.I2COpen(uint(*bus.cfg.Bus), uint(bus.handle.addr), 0)
+		if res < 0 {
+			bus.handle.lost = true
+			return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to open i2c device on bus "+bus.cfg.Name+" again")
+		}
+		bus.handle.handle = uint(res)
+		return nil
+	}
+	if res := bus.pi.daemon.BBI2COpen(bus.sda, bus.scl, uint(bus.cfg.BaudRateOrDefault())); res != 0 {
+		return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to open i2c bus "+bus.cfg.Name)
+	}
+	return nil
+}
+
+// close releases the pins of a bit-banged bus. Handles that are still open fail from now on.
+// The board mutex should be locked before calling this.
+func (bus *pigpioI2CBus) close() error {
+	if bus.closed {
+		return nil
+	}
+	bus.closed = true
+	if bus.cfg.Bus == nil {
+		if res := bus.pi.daemon.BBI2CClose(bus.sda); res != 0 {
+			return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to close i2c bus "+bus.cfg.Name)
+		}
+	}
+	return nil
+}
+
+// OpenHandle locks the bus and returns a handle to the device at addr. The handle MUST be closed to
+// release the bus.
+func (bus *pigpioI2CBus) OpenHandle(addr byte) (buses.I2CHandle, error) {
+	bus.mu.Lock()
+
+	bus.pi.mu.Lock()
+	defer bus.pi.mu.Unlock()
+	if bus.closed {
+		bus.mu.Unlock()
+		return nil, errors.Errorf("i2c bus %s is closed", bus.cfg.Name)
+	}
+
+	handle := &pigpioI2CHandle{bus: bus, addr: addr}
+	if bus.cfg.Bus != nil {
+		res := bus.pi.daemon.I2COpen(uint(*bus.cfg.Bus), uint(addr), 0)
+		if res < 0 {
+			bus.mu.Unlock()
+			return nil, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to open i2c device")
+		}
+		handle.handle = uint(res)
+		bus.handle = handle
+	}
+	return handle, nil
+}
+
+// pigpioI2CHandle talks to a single device on a pigpioI2CBus.
+type pigpioI2CHandle struct {
+	bus    *pigpioI2CBus
+	addr   byte
+	handle uint // daemon handle of the device on a hardware bus
+	lost   bool // the daemon restarted and the device could not be opened again, protected by the board mutex
+}
+
+// usable returns an error if the handle can no longer be used.
+// The board mutex should be locked before calling this.
+func (h *pigpioI2CHandle) usable() error {
+	if h.bus.closed {
+		return errors.Errorf("i2c bus %s is closed", h.bus.cfg.Name)
+	}
+	if h.lost {
+		return errors.Errorf("i2c device on bus %s was lost when pigpiod restarted, open a new handle", h.bus.cfg.Name)
+	}
+	return nil
+}
+
+// Write writes the given bytes to the device.
+func (h *pigpioI2CHandle) Write(ctx context.Context, tx []byte) error {
+	if len(tx) == 0 {
+		return nil
+	}
+	if h.bus.cfg.Bus == nil {
+		_, err := h.transact(tx, 0)
+		return err
+	}
+	pi := h.bus.pi
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	if err := h.usable(); err != nil {
+		return err
+	}
+	if res := pi.daemon.I2CWriteDevice(h.handle, tx); res != 0 {
+		return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to write to i2c device")
+	}
+	return nil
+}
+
+// Read reads the given number of bytes from the device.
+func (h *pigpioI2CHandle) Read(ctx context.Context, count int) ([]byte, error) {
+	if count <= 0 {
+		return []byte{}, nil
+	}
+	if h.bus.cfg.Bus == nil {
+		return h.transact(nil, count)
+	}
+	pi := h.bus.pi
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	if err := h.usable(); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, count)
+	res := pi.daemon.I2CReadDevice(h.handle, buf)
+	if res < 0 {
+		return nil, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to read from i2c device")
+	}
+	return buf[:res], nil
+}
+
+// ReadByteData reads a single byte from the given register.
+func (h *pigpioI2CHandle) ReadByteData(ctx context.Context, register byte) (byte, error) {
+	data, err := h.transact([]byte{register}, 1)
+	if err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// WriteByteData writes a single byte to the given register.
+func (h *pigpioI2CHandle) WriteByteData(ctx context.Context, register, data byte) error {
+	_, err := h.transact([]byte{register, data}, 0)
+	return err
+}
+
+// ReadBlockData reads the given number of bytes, starting at the given register.
+func (h *pigpioI2CHandle) ReadBlockData(ctx context.Context, register byte, numBytes uint8) ([]byte, error) {
+	return h.transact([]byte{register}, int(numBytes))
+}
+
+// WriteBlockData writes the given bytes, starting at the given register.
+func (h *pigpioI2CHandle) WriteBlockData(ctx context.Context, register byte, data []byte) error {
+	_, err := h.transact(append([]byte{register}, data...), 0)
+	return err
+}
+
+// transact writes and then reads in a single transaction, with a repeated start between the two.
+func (h *pigpioI2CHandle) transact(write []byte, readLen int) ([]byte, error) {
+	bitBang := h.bus.cfg.Bus == nil
+	in, err := rpiutils.EncodeI2CZip(h.addr, write, readLen, bitBang)
+	if err != nil {
+		return nil, err
+	}
+	// the daemon needs somewhere to write to even if nothing is read
+	out := make([]byte, readLen+1)
+
+	pi := h.bus.pi
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	if err := h.usable(); err != nil {
+		return nil, err
+	}
+	var res int
+	if bitBang {
+		res = pi.daemon.BBI2CZip(h.bus.sda, in, out)
+	} else {
+		res = pi.daemon.I2CZip(h.handle, in, out)
+	}
+	if res < 0 {
+		return nil, rpiutils.ConvertErrorCodeToMessage(int(res), "i2c transaction failed")
+	}
+	if int(res) != readLen {
+		return nil, errors.Errorf("expected to read %d bytes from i2c device, got %d", readLen, res)
+	}
+	return out[:readLen], nil
+}
+
+// Close releases the device handle and unlocks the bus.
+func (h *pigpioI2CHandle) Close() error {
+	defer h.bus.mu.Unlock()
+	if h.bus.cfg.Bus == nil {
+		return nil
+	}
+	pi := h.bus.pi
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	h.bus.handle = nil
+	if h.bus.closed || h.lost || pi.isClosed {
+		return nil
+	}
+	if res := pi.daemon.I2CClose(h.handle); res != 0 {
+		return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to close i2c device")
+	}
+	return nil
+}
+
+// I2CByName returns the i2c bus with the given name, so other components can borrow it.
+func (pi *piPigpio) I2CByName(name string) (buses.I2C, error) {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	bus, ok := pi.i2cBuses[name]
+	if !ok {
+		return nil, errors.Errorf("can't find i2c bus (%s)", name)
+	}
+	return bus, nil
+}
+
+// i2cTransactionCommand handles the i2c_transaction DoCommand.
+func (pi *piPigpio) i2cTransactionCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	name, ok := cmd["bus"].(string)
+	if !ok {
+		return nil, errors.New("expected \"bus\" to be the name of an i2c bus")
+	}
+	bus, err := pi.I2CByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return rpiutils.RunI2CTransaction(ctx, bus, cmd)
+}
+
+// closeI2CBuses closes every i2c bus of the board.
+func closeI2CBuses(pi *piPigpio) error {
+	var err error
+	for _, bus := range pi.i2cBuses {
+		err = multierr.Combine(err, bus.close())
+	}
+	pi.i2cBuses = map[string]*pigpioI2CBus{}
+	return err
+}
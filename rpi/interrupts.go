@@ -4,26 +4,21 @@ package rpi
 	This file implements digital interrupt functionality for the Raspberry Pi.
 */
 
-// #include <stdlib.h>
-// #include <pigpiod_if2.h>
-// #include "pi.h"
-// #cgo LDFLAGS: -lpigpiod_if2
-import "C"
-
 import (
 	"fmt"
-	"math"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.viam.com/rdk/components/board"
+	"raspberry-pi/pigpio"
 	rpiutils "raspberry-pi/utils"
 )
 
 type rpiInterrupt struct {
-	interrupt            rpiutils.ReconfigurableDigitalInterrupt
-	callbackID           C.uint // callback ID to close pi callback connection
-	lastTicks            uint64
-	debounceMicroSeconds uint64
+	interrupt  rpiutils.ReconfigurableDigitalInterrupt
+	callbackID uint // callback ID to close pi callback connection
+	cfg        rpiutils.PinConfig
+	debouncer  *rpiutils.Debouncer
 }
 
 // findInterruptByName finds an interrupt by its name, such as: "interrupt-1"
@@ -69,9 +64,19 @@ func (pi *piPigpio) reconfigureInterrupts(cfg *rpiutils.Config) error {
 		}
 		interrupt, ok := pi.interrupts[bcom]
 		if ok {
-			if result := C.teardownInterrupt(interrupt.callbackID); result != 0 {
+			if di, ok := interrupt.interrupt.(*rpiutils.BasicDigitalInterrupt); ok {
+				pi.totalizers.Record(di)
+			}
+			if result := pi.cancelInterruptCallback(bcom, interrupt); result != 0 {
 				return rpiutils.ConvertErrorCodeToMessage(int(result), "error")
 			}
+			interrupt.debouncer.Stop()
+			if result := pi.setDebounceFilters(bcom, rpiutils.DebounceModeDefault, 0); result != 0 {
+				return rpiutils.ConvertErrorCodeToMessage(result, "error")
+			}
+			if result := pi.daemon.SetWatchdog(bcom, 0); result != 0 {
+				return rpiutils.ConvertErrorCodeToMessage(result, "error")
+			}
 			delete(pi.interrupts, bcom)
 		}
 	}
@@ -90,7 +95,18 @@ func (pi *piPigpio) reconfigureInterrupts(cfg *rpiutils.Config) error {
 		// check if we are already managing pin
 		interrupt, ok := pi.interrupts[bcom]
 		if ok {
-			pi.logger.Infof("interrupt %v is already a tracked interrupt", interrupt.interrupt.Name())
+			if interruptChanged(interrupt.cfg, newConfig) {
+				if err := pi.updateInterrupt(bcom, interrupt, newConfig); err != nil {
+					return err
+				}
+				continue
+			}
+			// settings such as the edge and count mode only change how the interrupt handles the changes
+			// of the pin, so the interrupt does not have to be set up again for them
+			if err := interrupt.interrupt.Reconfigure(newConfig); err != nil {
+				return err
+			}
+			interrupt.cfg = newConfig
 			continue
 		}
 
@@ -110,19 +126,232 @@ func (pi *piPigpio) createNewInterrupt(newConfig rpiutils.PinConfig, bcom uint)
 	if err != nil {
 		return nil, err
 	}
-	callbackID := C.setupInterrupt(pi.piID, C.int(bcom))
-	if callbackID < 0 {
-		err := rpiutils.ConvertErrorCodeToMessage(int(callbackID), "error")
+	if di, ok := d.(*rpiutils.BasicDigitalInterrupt); ok {
+		pi.totalizers.Restore(di)
+	}
+	interrupt := &rpiInterrupt{interrupt: d, cfg: newConfig}
+	if res := pi.setupInterrupt(bcom, interrupt); res != 0 {
+		err := rpiutils.ConvertErrorCodeToMessage(res, "error")
 		return nil, errors.Errorf("Unable to set up interrupt on pin %s: %s", newConfig.Name, err)
 	}
+	pi.interrupts[bcom] = interrupt
+
+	return d, nil
+}
+
+// interruptChanged returns whether an interrupt has to be set up again for its new config.
+func interruptChanged(oldConfig, newConfig rpiutils.PinConfig) bool {
+	return oldConfig.PullState != newConfig.PullState ||
+		oldConfig.DebounceMS != newConfig.DebounceMS || oldConfig.DebounceMode != newConfig.DebounceMode ||
+		oldConfig.WatchdogMS != newConfig.WatchdogMS || oldConfig.HighRate != newConfig.HighRate
+}
+
+// updateInterrupt registers an interrupt again after its pull, debounce, watchdog or high_rate changed.
+// Its count and ticks streams are kept.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) updateInterrupt(bcom uint, interrupt *rpiInterrupt, newConfig rpiutils.PinConfig) error {
+	if res := pi.cancelInterruptCallback(bcom, interrupt); res != 0 {
+		return rpiutils.ConvertErrorCodeToMessage(res, "error")
+	}
+	if err := interrupt.interrupt.Reconfigure(newConfig); err != nil {
+		return err
+	}
+	interrupt.cfg = newConfig
+	if res := pi.setupInterrupt(bcom, interrupt); res != 0 {
+		err := rpiutils.ConvertErrorCodeToMessage(res, "error")
+		return errors.Errorf("Unable to set up interrupt on pin %s: %s", newConfig.Name, err)
+	}
+	return nil
+}
+
+// setupInterrupt makes the pin an input with its configured pull, debounce and watchdog, and calls
+// back the interrupt whenever the pin changes, from the high rate capture if it has high_rate. Every
+// change is reported by the daemon, so the debouncer knows the level of the pin, and the interrupt
+// picks the changes it counts and ticks. It returns the result code of the daemon.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) setupInterrupt(bcom uint, interrupt *rpiInterrupt) int {
+	if res := pi.daemon.SetMode(bcom, pigpio.Input); res != 0 {
+		return res
+	}
+	if res := pi.daemon.SetPullUpDown(bcom, pi.interruptPull(bcom, interrupt)); res != 0 {
+		return res
+	}
+	if res := pi.setDebounceFilters(bcom, interrupt.cfg.DebounceMode, interrupt.cfg.DebounceMS); res != 0 {
+		return res
+	}
+	if res := pi.daemon.SetWatchdog(bcom, uint(interrupt.cfg.WatchdogMS)); res != 0 {
+		return res
+	}
 
-	pi.interrupts[bcom] = &rpiInterrupt{
-		interrupt:            d,
-		callbackID:           C.uint(callbackID),
-		debounceMicroSeconds: uint64(newConfig.DebounceMS) * 1000,
+	// the filters of the daemon debounce the glitch and noise modes, so only the software mode is
+	// debounced here
+	var period time.Duration
+	if interrupt.cfg.DebounceMode == rpiutils.DebounceModeSoftware || interrupt.cfg.DebounceMode == rpiutils.DebounceModeDefault {
+		period = time.Duration(interrupt.cfg.DebounceMS) * time.Millisecond
+	}
+	if interrupt.debouncer != nil {
+		interrupt.debouncer.Stop()
+	}
+	interrupt.debouncer = rpiutils.NewDebouncer(interrupt.cfg.DebounceMode, period, func(high bool, nanoseconds uint64) {
+		pi.tickInterrupt(interrupt, high, nanoseconds)
+	})
+	if level := pi.daemon.Read(bcom); level >= 0 {
+		interrupt.debouncer.Init(level == 1)
 	}
 
-	return d, nil
+	if interrupt.cfg.HighRate {
+		return pi.addHighRateInterrupt(bcom, interrupt)
+	}
+	callbackID, res := pi.daemon.Callback(bcom, pigpio.EitherEdge, func(_, level uint, tick uint32) {
+		pi.interruptCallback(interrupt, level, tick)
+	})
+	if res != 0 {
+		return res
+	}
+	interrupt.callbackID = callbackID
+	return 0
+}
+
+// setDebounceFilters sets the glitch and noise filters of the daemon for the debounce mode of an
+// interrupt, and clears the filter that is not used. It returns the result code of the daemon.
+func (pi *piPigpio) setDebounceFilters(bcom uint, mode rpiutils.DebounceMode, debounceMS int) int {
+	var glitchUS, noiseUS uint
+	switch mode {
+	case rpiutils.DebounceModeGlitch:
+		glitchUS = uint(debounceMS) * 1000
+	case rpiutils.DebounceModeNoise:
+		noiseUS = uint(debounceMS) * 1000
+	case rpiutils.DebounceModeSoftware, rpiutils.DebounceModeDefault:
+	}
+	if res := pi.daemon.SetGlitchFilter(bcom, glitchUS); res != 0 {
+		return res
+	}
+	return pi.daemon.SetNoiseFilter(bcom, noiseUS, 0)
+}
+
+// interruptPull returns the pull of an interrupt pin: the pull configured on the interrupt, else the
+// pull configured by another entry for the pin, else pull up.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) interruptPull(bcom uint, interrupt *rpiInterrupt) uint {
+	pull := interrupt.cfg.PullState
+	if pull == rpiutils.PullDefault {
+		pull = rpiutils.Pull(pi.pulls[int(bcom)])
+	}
+	switch pull {
+	case rpiutils.PullNone:
+		return pigpio.PudOff
+	case rpiutils.PullDown:
+		return pigpio.PudDown
+	case rpiutils.PullUp, rpiutils.PullDefault:
+	}
+	return pigpio.PudUp
+}
+
+// interruptTimeout reports that the watchdog of an interrupt fired, with the level its pin stayed at.
+func (pi *piPigpio) interruptTimeout(interrupt *rpiInterrupt, nanoseconds uint64) {
+	switch di := interrupt.interrupt.(type) {
+	case *rpiutils.BasicDigitalInterrupt:
+		err := rpiutils.WatchdogTimeout(pi.cancelCtx, di, interrupt.debouncer.Level(), nanoseconds)
+		if err != nil {
+			pi.logger.Error(err)
+		}
+	default:
+		pi.logger.Error("unknown digital interrupt type")
+	}
+}
+
+// watchdogTimeoutsCommand handles the watchdog_timeouts DoCommand.
+func (pi *piPigpio) watchdogTimeoutsCommand(cmd map[string]interface{}) (map[string]interface{}, error) {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	interrupts, err := pi.commandInterrupts(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return rpiutils.WatchdogTimeoutsResult(interrupts), nil
+}
+
+// measureCommand handles the measure DoCommand.
+func (pi *piPigpio) measureCommand(cmd map[string]interface{}) (map[string]interface{}, error) {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	interrupts, err := pi.commandInterrupts(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return rpiutils.MeasurementsResult(interrupts), nil
+}
+
+// tickHistoryCommand handles the tick_history DoCommand, which returns the ticks after the optional
+// "since".
+func (pi *piPigpio) tickHistoryCommand(cmd map[string]interface{}) (map[string]interface{}, error) {
+	since, err := rpiutils.SinceFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	interrupts, err := pi.commandInterrupts(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return rpiutils.TickHistoryResult(interrupts, since), nil
+}
+
+// resetCounterCommand handles the reset_counter DoCommand, which sets the values of the interrupts to
+// the optional "preset", or 0.
+func (pi *piPigpio) resetCounterCommand(cmd map[string]interface{}) (map[string]interface{}, error) {
+	preset, err := rpiutils.PresetFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	interrupts, err := pi.commandInterrupts(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return rpiutils.ResetCounterResult(interrupts, preset), nil
+}
+
+// commandInterrupts returns the interrupt selected by the optional "pin" argument of a DoCommand, or
+// every interrupt if no pin is given. The pin is the name of the interrupt or the label of its pin.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) commandInterrupts(cmd map[string]interface{}) ([]*rpiutils.BasicDigitalInterrupt, error) {
+	pin, err := rpiutils.PinFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var interrupts []*rpiutils.BasicDigitalInterrupt
+	for bcom, interrupt := range pi.interrupts {
+		di, ok := interrupt.interrupt.(*rpiutils.BasicDigitalInterrupt)
+		if !ok {
+			continue
+		}
+		if pin != "" && di.Name() != pin {
+			if labelBcom, ok := rpiutils.BroadcomPinFromHardwareLabel(pin); !ok || labelBcom != bcom {
+				continue
+			}
+		}
+		interrupts = append(interrupts, di)
+	}
+	if pin != "" && len(interrupts) == 0 {
+		return nil, fmt.Errorf("interrupt %s does not exist", pin)
+	}
+	return interrupts, nil
+}
+
+// basicInterrupts returns the interrupts of the board.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) basicInterrupts() []*rpiutils.BasicDigitalInterrupt {
+	var interrupts []*rpiutils.BasicDigitalInterrupt
+	for _, interrupt := range pi.interrupts {
+		if di, ok := interrupt.interrupt.(*rpiutils.BasicDigitalInterrupt); ok {
+			interrupts = append(interrupts, di)
+		}
+	}
+	return interrupts
 }
 
 // DigitalInterruptNames returns the names of all known digital interrupts.
@@ -161,54 +390,27 @@ func (pi *piPigpio) DigitalInterruptByName(name string) (board.DigitalInterrupt,
 	return d, nil
 }
 
-var (
-	lastTick = uint32(0)
-	// the interrupt callback returns the time since boot in microseconds, but will wrap every ~72 minutes
-	// we use the tickRollovers global variable to track each time this has occurred, and update the ticks for every active interrupt
-	// we assume that uint64 will be large enough for us to not worry about the ticks overflowing further
-	tickRollovers = 0
-)
-
-//export pigpioInterruptCallback
-func pigpioInterruptCallback(gpio, level int, rawTick uint32) {
-	if rawTick < lastTick {
-		tickRollovers++
-	}
-	lastTick = rawTick
-
-	// tick is the time since the hardware was started in microseconds.
-	tick := (uint64(tickRollovers) * uint64(math.MaxUint32)) + uint64(rawTick)
-
-	// global lock to prevent multiple pins from interacting with the board
-	boardInstanceMu.RLock()
-	defer boardInstanceMu.RUnlock()
-
-	// boardInstance has to be initialized before callback can be called
-	if boardInstance == nil {
+// interruptCallback passes a change of an interrupt pin reported by the daemon to its debouncer, or
+// reports that its watchdog fired. It doesn't lock the board, since the board is locked while
+// callbacks are cancelled, and cancelling waits for the callback to return.
+func (pi *piPigpio) interruptCallback(interrupt *rpiInterrupt, level uint, tick uint32) {
+	nanoseconds := pi.tickClock.Nanoseconds(tick)
+	if level == pigpio.Timeout {
+		pi.interruptTimeout(interrupt, nanoseconds)
 		return
 	}
-	interrupt := boardInstance.interrupts[uint(gpio)]
-	if interrupt == nil {
-		boardInstance.logger.Infof("no DigitalInterrupt configured for gpio %d", gpio)
-		return
-	}
-	if interrupt.debounceMicroSeconds != 0 && tick-interrupt.lastTicks < interrupt.debounceMicroSeconds {
-		// we have not passed the debounce time, ignore this interrupt
-		return
-	}
-	high := true
-	if level == 0 {
-		high = false
-	}
+	interrupt.debouncer.Change(level == 1, nanoseconds)
+}
+
+// tickInterrupt ticks an interrupt for a change its debouncer reported.
+func (pi *piPigpio) tickInterrupt(interrupt *rpiInterrupt, high bool, nanoseconds uint64) {
 	switch di := interrupt.interrupt.(type) {
 	case *rpiutils.BasicDigitalInterrupt:
-		err := rpiutils.Tick(boardInstance.cancelCtx, di, high, tick*1000)
+		err := rpiutils.Tick(pi.cancelCtx, di, high, nanoseconds)
 		if err != nil {
-			boardInstance.logger.Error(err)
+			pi.logger.Error(err)
 		}
 	default:
-		boardInstance.logger.Error("unknown digital interrupt type")
+		pi.logger.Error("unknown digital interrupt type")
 	}
-	// store the current ticks for debouncing
-	interrupt.lastTicks = tick
 }
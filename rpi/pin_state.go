@@ -0,0 +1,92 @@
+package rpi
+
+/*
+	pin_state.go: Reads back the live state of pins from the pigpio daemon.
+*/
+
+import (
+	"github.com/pkg/errors"
+	"raspberry-pi/pigpio"
+	rpiutils "raspberry-pi/utils"
+)
+
+// pigpioModeNames maps the modes returned by get_mode to readable function names.
+var pigpioModeNames = map[int]string{
+	pigpio.Input:  "input",
+	pigpio.Output: "output",
+	pigpio.Alt0:   "alt0",
+	pigpio.Alt1:   "alt1",
+	pigpio.Alt2:   "alt2",
+	pigpio.Alt3:   "alt3",
+	pigpio.Alt4:   "alt4",
+	pigpio.Alt5:   "alt5",
+}
+
+// pinStateCommand handles the pin_state DoCommand for one pin, or every header pin if no pin is given.
+func (pi *piPigpio) pinStateCommand(cmd map[string]interface{}) (map[string]interface{}, error) {
+	pin, err := rpiutils.PinFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+
+	bcoms := rpiutils.HeaderBroadcomPins()
+	if pin != "" {
+		bcom, ok := pi.broadcomFromName(pin)
+		if !ok {
+			return nil, errors.Errorf("no hw pin for (%s)", pin)
+		}
+		bcoms = []uint{bcom}
+	}
+
+	states := make([]rpiutils.PinState, 0, len(bcoms))
+	for _, bcom := range bcoms {
+		state, err := pi.pinState(bcom)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return rpiutils.PinStatesToResponse(states), nil
+}
+
+// pinState reads the function and level of a pin from the daemon. pigpio cannot read pulls back
+// from the hardware, so the pull reported is the one this board last applied, if any.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) pinState(bcom uint) (rpiutils.PinState, error) {
+	mode := pi.daemon.GetMode(bcom)
+	if mode < 0 {
+		return rpiutils.PinState{}, rpiutils.ConvertErrorCodeToMessage(int(mode), "failed to get mode")
+	}
+	level := pi.daemon.Read(bcom)
+	if level < 0 {
+		return rpiutils.PinState{}, rpiutils.ConvertErrorCodeToMessage(int(level), "failed to read gpio")
+	}
+
+	state := rpiutils.PinState{
+		GPIO:     bcom,
+		Function: pigpioModeNames[int(mode)],
+		Level:    level != 0,
+		Pull:     rpiutils.Pull(pi.pulls[int(bcom)]),
+	}
+	for _, c := range pi.pinConfigs {
+		if b, ok := rpiutils.BroadcomPinFromHardwareLabel(c.Pin); ok && b == bcom {
+			state.Name = c.Name
+			break
+		}
+	}
+	return state, nil
+}
+
+// broadcomFromName resolves a configured pin name or a hardware label to a broadcom pin.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) broadcomFromName(name string) (uint, bool) {
+	for _, c := range pi.pinConfigs {
+		if c.Name == name {
+			return rpiutils.BroadcomPinFromHardwareLabel(c.Pin)
+		}
+	}
+	return rpiutils.BroadcomPinFromHardwareLabel(name)
+}
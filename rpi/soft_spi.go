@@ -0,0 +1,179 @@
+package rpi
+
+/*
+	soft_spi.go: SPI buses bit-banged on arbitrary GPIOs through the pigpio daemon, which lets more
+	devices share the board than the two chip selects of the hardware bus allow.
+	Details can be found here -> https://abyz.me.uk/rpi/pigpio/pdif2.html#bb_spi_open
+*/
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/components/board/genericlinux/buses"
+	rpiutils "raspberry-pi/utils"
+)
+
+// softSPIChipSelect is the baud rate and mode a chip select was last opened with.
+type softSPIChipSelect struct {
+	baud uint
+	mode uint
+}
+
+// softSPIBus is an spi bus bit-banged by the pigpio daemon. Opening a handle locks the bus until the
+// handle is closed, so transfers from different devices never interleave.
+type softSPIBus struct {
+	pi               *piPigpio
+	cfg              rpiutils.SoftSPIConfig
+	miso, mosi, sclk uint
+
+	// mu is held while a handle is open. Transfers only need this lock and not the board mutex, so
+	// analog readers can be closed while the board is locked.
+	mu          sync.Mutex
+	chipSelects map[uint]softSPIChipSelect // protected by mu
+	closed      bool                       // protected by mu
+}
+
+// reconfigureSoftSPIs creates every configured soft spi bus. Buses whose config did not change are
+// kept, while removed or changed ones are closed first.
+func (pi *piPigpio) reconfigureSoftSPIs(cfg *rpiutils.Config) error {
+	newConfigs := map[string]rpiutils.SoftSPIConfig{}
+	for _, c := range cfg.SoftSPIs {
+		newConfigs[c.Name] = c
+	}
+
+	for name, bus := range pi.softSPIs {
+		if newConfig, ok := newConfigs[name]; ok && newConfig == bus.cfg {
+			continue
+		}
+		if err := bus.close(); err != nil {
+			return err
+		}
+		delete(pi.softSPIs, name)
+	}
+
+	for name, c := range newConfigs {
+		if _, ok := pi.softSPIs[name]; ok {
+			continue
+		}
+		miso, okMISO := rpiutils.BroadcomPinFromHardwareLabel(c.MISO)
+		mosi, okMOSI := rpiutils.BroadcomPinFromHardwareLabel(c.MOSI)
+		sclk, okSCLK := rpiutils.BroadcomPinFromHardwareLabel(c.SCLK)
+		if !okMISO || !okMOSI || !okSCLK {
+			return errors.Errorf("no hw pins for spi bus (%s)", name)
+		}
+		pi.softSPIs[name] = &softSPIBus{
+			pi:          pi,
+			cfg:         c,
+			miso:        miso,
+			mosi:        mosi,
+			sclk:        sclk,
+			chipSelects: map[uint]softSPIChipSelect{},
+		}
+	}
+	return nil
+}
+
+// close releases every chip select opened on the bus, waiting for any open handle to be closed first.
+func (bus *softSPIBus) close() error {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.closed {
+		return nil
+	}
+	bus.closed = true
+	var err error
+	for cs := range bus.chipSelects {
+		if res := bus.pi.daemon.BBSPIClose(cs); res != 0 {
+			err = multierr.Combine(err, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to close spi bus "+bus.cfg.Name))
+		}
+	}
+	bus.chipSelects = map[uint]softSPIChipSelect{}
+	return err
+}
+
+// OpenHandle locks the bus and returns a handle that MUST be closed to release the bus.
+func (bus *softSPIBus) OpenHandle() (buses.SPIHandle, error) {
+	bus.mu.Lock()
+	return &softSPIHandle{bus: bus}, nil
+}
+
+// Close does nothing, the bus is closed with the board.
+func (bus *softSPIBus) Close(ctx context.Context) error {
+	return nil
+}
+
+// softSPIHandle runs transfers on a softSPIBus.
+type softSPIHandle struct {
+	bus *softSPIBus
+}
+
+// Xfer performs a single transfer with the device behind the chip select pin. The bus is bit-banged,
+// so the transfer runs at the slower of the requested baud rate and the bus's baud rate.
+func (h *softSPIHandle) Xfer(ctx context.Context, baud uint, chipSelect string, mode uint, tx []byte) ([]byte, error) {
+	cs, ok := rpiutils.BroadcomPinFromHardwareLabel(chipSelect)
+	if !ok {
+		return nil, errors.Errorf("bad chip select (%s)", chipSelect)
+	}
+	if busBaud := uint(h.bus.cfg.BaudRateOrDefault()); baud == 0 || baud > busBaud {
+		baud = busBaud
+	}
+	if len(tx) == 0 {
+		return []byte{}, nil
+	}
+
+	if h.bus.closed {
+		return nil, errors.Errorf("spi bus %s is closed", h.bus.cfg.Name)
+	}
+	if err := h.bus.openChipSelect(cs, softSPIChipSelect{baud: baud, mode: mode}); err != nil {
+		return nil, err
+	}
+
+	rx := make([]byte, len(tx))
+	res := h.bus.pi.daemon.BBSPIXfer(cs, tx, rx)
+	if res < 0 {
+		return nil, rpiutils.ConvertErrorCodeToMessage(int(res), "spi transfer failed")
+	}
+	return rx[:res], nil
+}
+
+// openChipSelect opens the chip select with the given settings, reopening it if it was last used
+// with different ones.
+// The bus mutex should be locked before calling this.
+func (bus *softSPIBus) openChipSelect(cs uint, settings softSPIChipSelect) error {
+	if current, ok := bus.chipSelects[cs]; ok {
+		if current == settings {
+			return nil
+		}
+		if res := bus.pi.daemon.BBSPIClose(cs); res != 0 {
+			return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to close chip select")
+		}
+		delete(bus.chipSelects, cs)
+	}
+	// the low two bits of the flags are the spi mode
+	res := bus.pi.daemon.BBSPIOpen(cs, bus.miso, bus.mosi, bus.sclk,
+		settings.baud, settings.mode&3)
+	if res != 0 {
+		return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to open spi bus "+bus.cfg.Name)
+	}
+	bus.chipSelects[cs] = settings
+	return nil
+}
+
+// Close unlocks the bus.
+func (h *softSPIHandle) Close() error {
+	h.bus.mu.Unlock()
+	return nil
+}
+
+// closeSoftSPIs closes every soft spi bus of the board.
+func closeSoftSPIs(pi *piPigpio) error {
+	var err error
+	for _, bus := range pi.softSPIs {
+		err = multierr.Combine(err, bus.close())
+	}
+	pi.softSPIs = map[string]*softSPIBus{}
+	return err
+}
@@ -0,0 +1,321 @@
+package rpi
+
+/*
+	soft_uart.go: Bit-banged serial ports on arbitrary GPIOs. Receiving uses pigpio's bit-bang serial
+	reader -> https://abyz.me.uk/rpi/pigpio/pdif2.html#bb_serial_read_open
+	and transmitting uses serial waves -> https://abyz.me.uk/rpi/pigpio/pdif2.html#wave_add_serial
+*/
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"raspberry-pi/pigpio"
+	rpiutils "raspberry-pi/utils"
+)
+
+const (
+	// softUARTReadBufferSize is the size of the daemon's cyclic receive buffer, and the most a single
+	// soft_uart_read DoCommand returns.
+	softUARTReadBufferSize = 8192
+	// softUARTWriteChunkSize is the most bytes transmitted with a single wave, which keeps each wave
+	// well under the daemon's pulse limit.
+	softUARTWriteChunkSize = 128
+	// softUARTStopHalfBits is one stop bit, counted in half bits as wave_add_serial expects.
+	softUARTStopHalfBits = 2
+	// softUARTWriteSlack is how much longer than its data takes to send a wave gets to finish, before
+	// the write gives up on it.
+	softUARTWriteSlack = 100 * time.Millisecond
+)
+
+// softUART is a bit-banged serial port driven by the pigpio daemon.
+type softUART struct {
+	pi     *piPigpio
+	cfg    rpiutils.SoftUARTConfig
+	rx, tx uint
+	hasRX  bool
+	hasTX  bool
+	closed bool // protected by the board mutex
+}
+
+// reconfigureSoftUARTs opens every configured soft uart. Soft uarts whose config did not change are
+// kept open so no received data is lost, while removed or changed ones are closed first.
+func (pi *piPigpio) reconfigureSoftUARTs(cfg *rpiutils.Config) error {
+	newConfigs := map[string]rpiutils.SoftUARTConfig{}
+	for _, c := range cfg.SoftUARTs {
+		newConfigs[c.Name] = c
+	}
+
+	for name, uart := range pi.softUARTs {
+		if newConfig, ok := newConfigs[name]; ok && newConfig == uart.cfg {
+			continue
+		}
+		if err := uart.close(); err != nil {
+			return err
+		}
+		delete(pi.softUARTs, name)
+	}
+
+	for name, c := range newConfigs {
+		if _, ok := pi.softUARTs[name]; ok {
+			continue
+		}
+		uart, err := pi.openSoftUART(c)
+		if err != nil {
+			return err
+		}
+		pi.softUARTs[name] = uart
+	}
+	return nil
+}
+
+// openSoftUART starts receiving on the rx pin and idles the tx pin high.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) openSoftUART(cfg rpiutils.SoftUARTConfig) (*softUART, error) {
+	uart := &softUART{pi: pi, cfg: cfg}
+	if cfg.RXPin != "" {
+		rx, ok := rpiutils.BroadcomPinFromHardwareLabel(cfg.RXPin)
+		if !ok {
+			return nil, errors.Errorf("no hw pin for (%s)", cfg.RXPin)
+		}
+		uart.rx, uart.hasRX = rx, true
+	}
+	if cfg.TXPin != "" {
+		tx, ok := rpiutils.BroadcomPinFromHardwareLabel(cfg.TXPin)
+		if !ok {
+			return nil, errors.Errorf("no hw pin for (%s)", cfg.TXPin)
+		}
+		uart.tx, uart.hasTX = tx, true
+	}
+	if err := uart.open(); err != nil {
+		return nil, err
+	}
+	pi.logger.Debugf("opened soft uart %s at %d baud", cfg.Name, cfg.BaudRate)
+	return uart, nil
+}
+
+// open sets up the pins of the soft uart on the daemon. It is also used to restore the soft uart
+// after reconnecting to the daemon.
+// The board mutex should be locked before calling this.
+func (u *softUART) open() error {
+	pi := u.pi
+	if u.hasRX {
+		res := pi.daemon.BBSerialReadOpen(u.rx, uint(u.cfg.BaudRate), uint(u.cfg.DataBitsOrDefault()))
+		if res != 0 {
+			return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to open soft uart "+u.cfg.Name)
+		}
+	}
+	if u.hasTX {
+		if res := pi.daemon.SetMode(u.tx, pigpio.Output); res != 0 {
+			return multierr.Combine(rpiutils.ConvertErrorCodeToMessage(int(res), "failed to set mode"), u.close())
+		}
+		if res := pi.daemon.Write(u.tx, 1); res != 0 {
+			return multierr.Combine(rpiutils.ConvertErrorCodeToMessage(int(res), "failed to idle tx pin"), u.close())
+		}
+	}
+	return nil
+}
+
+// close stops receiving and returns the tx pin to an input.
+// The board mutex should be locked before calling this.
+func (u *softUART) close() error {
+	if u.closed {
+		return nil
+	}
+	u.closed = true
+	var err error
+	if u.hasRX {
+		if res := u.pi.daemon.BBSerialReadClose(u.rx); res != 0 {
+			err = multierr.Combine(err, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to close soft uart "+u.cfg.Name))
+		}
+	}
+	if u.hasTX {
+		if res := u.pi.daemon.SetMode(u.tx, pigpio.Input); res != 0 {
+			err = multierr.Combine(err, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to set mode"))
+		}
+	}
+	return err
+}
+
+// Read returns the data received since the last read without blocking.
+func (u *softUART) Read(p []byte) (int, error) {
+	if !u.hasRX {
+		return 0, errors.Errorf("soft uart %s does not have an rx pin", u.cfg.Name)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	u.pi.mu.Lock()
+	defer u.pi.mu.Unlock()
+	if u.closed {
+		return 0, errors.Errorf("soft uart %s is closed", u.cfg.Name)
+	}
+	n := u.pi.daemon.BBSerialRead(u.rx, p)
+	if n < 0 {
+		return 0, rpiutils.ConvertErrorCodeToMessage(int(n), "failed to read soft uart "+u.cfg.Name)
+	}
+	return int(n), nil
+}
+
+// Write transmits the data and blocks until it has been sent. Only one wave can be transmitted at a
+// time, so writes to all soft uarts of the board and the wave DoCommands that transmit are
+// serialized, and a write stops any wave started through DoCommand. A write fails while a wave is
+// being built through DoCommand, since it would throw the pulses added so far away.
+func (u *softUART) Write(p []byte) (int, error) {
+	if !u.hasTX {
+		return 0, errors.Errorf("soft uart %s does not have a tx pin", u.cfg.Name)
+	}
+
+	u.pi.waveTxMu.Lock()
+	defer u.pi.waveTxMu.Unlock()
+
+	written := 0
+	for written < len(p) {
+		end := written + softUARTWriteChunkSize
+		if end > len(p) {
+			end = len(p)
+		}
+		if err := u.writeChunk(p[written:end]); err != nil {
+			return written, err
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// writeChunk transmits a single serial wave and waits for it to finish. The board's wave transmit
+// mutex should be locked before calling this.
+func (u *softUART) writeChunk(chunk []byte) error {
+	pi := u.pi
+	pi.mu.Lock()
+	if u.closed {
+		pi.mu.Unlock()
+		return errors.Errorf("soft uart %s is closed", u.cfg.Name)
+	}
+	id, err := u.sendWave(chunk)
+	pi.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// each character takes a start bit, the data bits and a stop bit
+	bits := len(chunk) * (u.cfg.DataBitsOrDefault() + 2)
+	duration := time.Duration(bits) * time.Second / time.Duration(u.cfg.BaudRate)
+	time.Sleep(duration)
+	deadline := time.Now().Add(duration + softUARTWriteSlack)
+	for {
+		pi.mu.Lock()
+		if pi.isClosed {
+			// closing the board deleted the wave already
+			pi.mu.Unlock()
+			return errors.New("board closed while writing to soft uart " + u.cfg.Name)
+		}
+		busy := pi.daemon.WaveTxBusy()
+		if busy != 1 {
+			res := pi.daemon.WaveDelete(uint(id))
+			delete(pi.waves, id)
+			pi.mu.Unlock()
+			if busy < 0 {
+				return rpiutils.ConvertErrorCodeToMessage(int(busy), "failed to check wave transmission")
+			}
+			if res != 0 {
+				return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to delete wave")
+			}
+			return nil
+		}
+		if time.Now().After(deadline) {
+			// the wave was kept from finishing, stop it so the next write doesn't wait for it too
+			pi.daemon.WaveTxStop()
+			pi.daemon.WaveDelete(uint(id))
+			delete(pi.waves, id)
+			pi.mu.Unlock()
+			return errors.Errorf("soft uart %s did not finish sending within %v", u.cfg.Name, 2*duration+softUARTWriteSlack)
+		}
+		pi.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// sendWave builds a serial wave from the chunk and starts transmitting it. The wave is tracked with
+// the board's waves so it is cleaned up if the board closes mid write.
+// The board mutex should be locked before calling this.
+func (u *softUART) sendWave(chunk []byte) (uint, error) {
+	pi := u.pi
+	if pi.waveBuilding {
+		return 0, errors.Errorf("cannot write to soft uart %s while a wave is being built with %s, create it with %s first",
+			u.cfg.Name, rpiutils.WaveAddGenericCommand, rpiutils.WaveCreateCommand)
+	}
+	if res := pi.daemon.WaveAddNew(); res != 0 {
+		return 0, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to start wave")
+	}
+	res := pi.daemon.WaveAddSerial(u.tx, uint(u.cfg.BaudRate), uint(u.cfg.DataBitsOrDefault()),
+		softUARTStopHalfBits, 0, chunk)
+	if res < 0 {
+		return 0, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to add serial data to wave")
+	}
+	id := pi.daemon.WaveCreate()
+	if id < 0 {
+		return 0, rpiutils.ConvertErrorCodeToMessage(int(id), "failed to create wave")
+	}
+	pi.waves[uint(id)] = struct{}{}
+	if res := pi.daemon.WaveSendOnce(uint(id)); res < 0 {
+		pi.daemon.WaveDelete(uint(id))
+		delete(pi.waves, uint(id))
+		return 0, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to send wave")
+	}
+	return uint(id), nil
+}
+
+// SoftUARTByName returns the soft uart with the given name, so other components can use it.
+func (pi *piPigpio) SoftUARTByName(name string) (rpiutils.SoftUART, error) {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	uart, ok := pi.softUARTs[name]
+	if !ok {
+		return nil, errors.Errorf("can't find soft uart (%s)", name)
+	}
+	return uart, nil
+}
+
+// softUARTCommand handles the soft_uart_read and soft_uart_write DoCommands.
+func (pi *piPigpio) softUARTCommand(command string, cmd map[string]interface{}) (map[string]interface{}, error) {
+	name, err := rpiutils.SoftUARTFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	uart, err := pi.SoftUARTByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if command == rpiutils.SoftUARTWriteCommand {
+		data, err := rpiutils.DataFromCommand(cmd)
+		if err != nil {
+			return nil, err
+		}
+		n, err := uart.Write(data)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"bytes_written": n}, nil
+	}
+
+	buf := make([]byte, softUARTReadBufferSize)
+	n, err := uart.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return rpiutils.DataToResponse(cmd, buf[:n])
+}
+
+// closeSoftUARTs closes every soft uart of the board.
+func closeSoftUARTs(pi *piPigpio) error {
+	var err error
+	for _, uart := range pi.softUARTs {
+		err = multierr.Combine(err, uart.close())
+	}
+	pi.softUARTs = map[string]*softUART{}
+	return err
+}
@@ -0,0 +1,268 @@
+package rpi
+
+/*
+	supervisor.go: Watches the connection to the pigpio daemon. If the daemon restarts, the board's
+	connection and every callback registered on it are dead, so the supervisor reconnects and
+	restores the state of the pins that were configured through the board. While the daemon is up,
+	the supervisor also keeps the clock of its ticks in sync with the system time.
+*/
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"raspberry-pi/pigpio"
+	rpiutils "raspberry-pi/utils"
+)
+
+const (
+	// pigpiodHealthCheckInterval is how often the connection to the daemon is checked, and how soon
+	// the first reconnect is attempted once it is down.
+	pigpiodHealthCheckInterval = time.Second
+	// pigpiodMaxReconnectBackoff is the longest wait between two reconnects to a daemon that stays down.
+	pigpiodMaxReconnectBackoff = 30 * time.Second
+)
+
+// pigpiodHealth is the state of the connection to the daemon, as seen by the supervisor.
+// It is protected by the board mutex.
+type pigpiodHealth struct {
+	connected  bool
+	version    uint // version of the daemon at the last successful check
+	checks     uint64
+	failures   uint64
+	reconnects uint64
+	lastCheck  time.Time
+	lastError  error
+}
+
+// superviseConnection checks the connection to the daemon until the board is closed, reconnecting
+// whenever a check fails. Connecting can take a while if the daemon is on another machine, so it is
+// done without locking the board, and the attempts back off while the daemon stays down.
+func (pi *piPigpio) superviseConnection() {
+	ticker := time.NewTicker(pigpiodHealthCheckInterval)
+	defer ticker.Stop()
+	backoff := pigpiodHealthCheckInterval
+	var nextAttempt time.Time
+	for {
+		select {
+		case <-pi.cancelCtx.Done():
+			return
+		case <-ticker.C:
+		}
+		pi.mu.Lock()
+		connected := pi.isClosed || pi.checkConnection()
+		pi.mu.Unlock()
+		if connected {
+			backoff, nextAttempt = pigpiodHealthCheckInterval, time.Time{}
+			continue
+		}
+		if time.Now().Before(nextAttempt) {
+			continue
+		}
+
+		err := pi.reconnect()
+		if err == nil {
+			backoff, nextAttempt = pigpiodHealthCheckInterval, time.Time{}
+			continue
+		}
+		pi.mu.Lock()
+		pi.health.lastError = err
+		pi.mu.Unlock()
+		pi.logger.Debugw("failed to reconnect to pigpiod", "endpoint", pi.endpoint, "error", err, "retry_in", backoff)
+		nextAttempt = time.Now().Add(backoff)
+		backoff = min(2*backoff, pigpiodMaxReconnectBackoff)
+	}
+}
+
+// checkConnection asks the daemon for its version, and returns whether it answered. The version is
+// used rather than the current tick because a tick can take any 32 bit value, so a failed call
+// cannot be told apart from a successful one.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) checkConnection() bool {
+	pi.health.checks++
+	pi.health.lastCheck = time.Now()
+
+	version := int32(pi.daemon.PigpioVersion())
+	if version >= 0 {
+		pi.health.connected = true
+		pi.health.version = uint(version)
+		if time.Since(pi.lastTickSync) >= rpiutils.TickClockSyncInterval {
+			pi.syncTickClock()
+		}
+		return true
+	}
+
+	pi.health.failures++
+	pi.health.lastError = rpiutils.ConvertErrorCodeToMessage(int(version), "pigpiod health check failed")
+	if pi.health.connected {
+		pi.logger.Warnf("lost connection to pigpiod at %s, reconnecting: %v", pi.endpoint, pi.health.lastError)
+	}
+	pi.health.connected = false
+	return false
+}
+
+// syncTickClock maps the current tick of the daemon onto the system time. The tick is taken to be
+// read halfway through the call.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) syncTickClock() {
+	before := time.Now()
+	tick := pi.daemon.CurrentTick()
+	after := time.Now()
+	pi.tickClock.Sync(tick, before.Add(after.Sub(before)/2))
+	pi.lastTickSync = after
+}
+
+// reconnect connects to the daemon again, and replaces the dead connection of the board with the
+// new one. The board is only locked once the new connection is up.
+func (pi *piPigpio) reconnect() error {
+	daemon, err := initializePigpio(pi.endpoint)
+	if err != nil {
+		return err
+	}
+
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	if pi.isClosed {
+		//nolint:errcheck  // the board no longer needs the connection
+		daemon.Close()
+		return nil
+	}
+	if err := pi.replaceConnection(daemon); err != nil {
+		return err
+	}
+	pi.health.connected = true
+	pi.health.reconnects++
+	pi.logger.Infof("reconnected to pigpiod at %s", pi.endpoint)
+	return nil
+}
+
+// replaceConnection replaces the dead connection to the daemon with a new one and restores the state
+// of the board on it. Soft spi transfers don't lock the board, so every soft spi bus is locked while
+// the connection is swapped.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) replaceConnection(daemon pigpio.Pi) error {
+	for _, bus := range pi.softSPIs {
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+	}
+
+	// the callbacks are kept by the client rather than the daemon, so they have to be cancelled even
+	// though the daemon has forgotten them already
+	for bcom, interrupt := range pi.interrupts {
+		pi.cancelInterruptCallback(bcom, interrupt)
+	}
+	//nolint:errcheck  // the connection is already dead
+	pi.daemon.Close()
+
+	pi.daemon = daemon
+	// the daemon may have started over, and its ticks with it
+	pi.tickClock.Reset()
+	pi.syncTickClock()
+
+	for _, bus := range pi.softSPIs {
+		bus.chipSelects = map[uint]softSPIChipSelect{}
+	}
+	// the daemon lost the waves, while the handles of hardware i2c buses are opened again with the buses
+	pi.waves = map[uint]struct{}{}
+	pi.waveBuilding = false
+	return pi.restoreState()
+}
+
+// restoreState re-applies the modes, pulls, pwm, interrupts and peripherals of the board on a fresh
+// connection to the daemon. It keeps going after an error so as much as possible is restored.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) restoreState() error {
+	var err error
+	for _, pin := range pi.gpioPins {
+		err = multierr.Combine(err, pi.restoreGPIO(pin))
+	}
+
+	for bcom, interrupt := range pi.interrupts {
+		if res := pi.setupInterrupt(bcom, interrupt); res != 0 {
+			err = multierr.Combine(err, errors.Errorf("unable to set up interrupt on pin %s again: %s",
+				interrupt.interrupt.Name(), rpiutils.ConvertErrorCodeToMessage(res, "error")))
+		}
+	}
+
+	for bcom, pull := range pi.pulls {
+		var res int
+		switch rpiutils.Pull(pull) {
+		case rpiutils.PullNone:
+			res = pi.daemon.SetPullUpDown(uint(bcom), pigpio.PudOff)
+		case rpiutils.PullUp:
+			res = pi.daemon.SetPullUpDown(uint(bcom), pigpio.PudUp)
+		case rpiutils.PullDown:
+			res = pi.daemon.SetPullUpDown(uint(bcom), pigpio.PudDown)
+		case rpiutils.PullDefault:
+		}
+		if res != 0 {
+			err = multierr.Combine(err, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to set pull"))
+		}
+	}
+
+	err = multierr.Combine(err, pi.reconfigureAltFunctions(&rpiutils.Config{Pins: pi.pinConfigs}))
+	for bcom, freqHz := range pi.clocks {
+		err = multierr.Combine(err, pi.setClockFrequency(bcom, freqHz))
+	}
+
+	for _, uart := range pi.softUARTs {
+		err = multierr.Combine(err, uart.open())
+	}
+	for _, bus := range pi.i2cBuses {
+		err = multierr.Combine(err, bus.open())
+	}
+	return err
+}
+
+// restoreGPIO re-applies the mode, level or pwm of a single pin.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) restoreGPIO(pin *rpiGPIO) error {
+	if pin.pwmFreqHz != 0 && !pin.hardwarePWM {
+		if res := pi.daemon.SetPWMFrequency(pin.pin, pin.pwmFreqHz); res < 0 {
+			return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to restore pwm frequency")
+		}
+	}
+	var res int
+	switch {
+	case pin.pwmEnabled && pin.hardwarePWM:
+		res = pi.daemon.HardwarePWM(pin.pin, pin.hwPWMFreqHz, pin.hwPWMDutyPPM)
+	case pin.pwmEnabled:
+		res = pi.daemon.SetPWMDutycycle(pin.pin, pin.pwmDuty)
+	case pin.configuration == GPIOOutput:
+		if res = pi.daemon.SetMode(pin.pin, pigpio.Output); res == 0 {
+			var level uint
+			if pin.outputHigh {
+				level = 1
+			}
+			res = pi.daemon.Write(pin.pin, level)
+		}
+	case pin.configuration == GPIOInput:
+		res = pi.daemon.SetMode(pin.pin, pigpio.Input)
+	}
+	if res != 0 {
+		return rpiutils.ConvertErrorCodeToMessage(int(res), "failed to restore pin "+pin.name)
+	}
+	return nil
+}
+
+// pigpiodHealthCommand handles the pigpiod_health DoCommand.
+func (pi *piPigpio) pigpiodHealthCommand() (map[string]interface{}, error) {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	resp := map[string]interface{}{
+		"endpoint":              pi.endpoint.String(),
+		"connected":             pi.health.connected,
+		"pigpio_version":        int(pi.health.version),
+		"health_checks":         int(pi.health.checks),
+		"health_check_failures": int(pi.health.failures),
+		"reconnects":            int(pi.health.reconnects),
+	}
+	if !pi.health.lastCheck.IsZero() {
+		resp["last_check"] = pi.health.lastCheck.Format(time.RFC3339Nano)
+	}
+	if pi.health.lastError != nil {
+		resp["last_error"] = pi.health.lastError.Error()
+	}
+	return resp, nil
+}
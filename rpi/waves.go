@@ -0,0 +1,195 @@
+package rpi
+
+/*
+	waves.go: Exposes pigpio's waveforms through DoCommand for precisely timed pulse trains.
+	Details on waves can be found here -> https://abyz.me.uk/rpi/pigpio/pdif2.html#wave_add_generic
+*/
+
+import (
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"raspberry-pi/pigpio"
+	rpiutils "raspberry-pi/utils"
+)
+
+// waveCommand runs one of the wave DoCommands. Commands that start or stop a transmission wait for
+// any soft uart write to finish, since they would cut off its wave.
+func (pi *piPigpio) waveCommand(command string, cmd map[string]interface{}) (map[string]interface{}, error) {
+	switch command {
+	case rpiutils.WaveSendOnceCommand, rpiutils.WaveSendRepeatCommand, rpiutils.WaveChainCommand, rpiutils.WaveTxStopCommand:
+		pi.waveTxMu.Lock()
+		defer pi.waveTxMu.Unlock()
+	}
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+
+	switch command {
+	case rpiutils.WaveAddGenericCommand:
+		return pi.waveAddGeneric(cmd)
+	case rpiutils.WaveCreateCommand:
+		id := pi.daemon.WaveCreate()
+		if id < 0 {
+			return nil, rpiutils.ConvertErrorCodeToMessage(int(id), "failed to create wave")
+		}
+		pi.waves[uint(id)] = struct{}{}
+		pi.waveBuilding = false
+		return map[string]interface{}{"wave_id": int(id)}, nil
+	case rpiutils.WaveDeleteCommand:
+		id, err := pi.waveIDFromCommand(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if res := pi.daemon.WaveDelete(id); res != 0 {
+			return nil, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to delete wave")
+		}
+		delete(pi.waves, id)
+		return map[string]interface{}{}, nil
+	case rpiutils.WaveSendOnceCommand, rpiutils.WaveSendRepeatCommand:
+		id, err := pi.waveIDFromCommand(cmd)
+		if err != nil {
+			return nil, err
+		}
+		var res int
+		if command == rpiutils.WaveSendOnceCommand {
+			res = pi.daemon.WaveSendOnce(id)
+		} else {
+			res = pi.daemon.WaveSendRepeat(id)
+		}
+		if res < 0 {
+			return nil, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to send wave")
+		}
+		return map[string]interface{}{"dma_control_blocks": int(res)}, nil
+	case rpiutils.WaveChainCommand:
+		return pi.waveChain(cmd)
+	case rpiutils.WaveTxBusyCommand:
+		res := pi.daemon.WaveTxBusy()
+		if res < 0 {
+			return nil, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to check wave transmission")
+		}
+		return map[string]interface{}{"busy": res == 1}, nil
+	case rpiutils.WaveTxStopCommand:
+		if res := pi.daemon.WaveTxStop(); res != 0 {
+			return nil, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to stop wave")
+		}
+		return map[string]interface{}{}, nil
+	default:
+		return nil, errors.Errorf("unknown command %v", command)
+	}
+}
+
+// waveAddGeneric adds pulses to the wave that is being built. Every pin used by the pulses is set
+// to an output, since the wave only toggles the levels of pins that are outputs.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) waveAddGeneric(cmd map[string]interface{}) (map[string]interface{}, error) {
+	pulses, err := rpiutils.PulsesFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	daemonPulses := make([]pigpio.Pulse, len(pulses))
+	used := map[uint]struct{}{}
+	for idx, pulse := range pulses {
+		on, err := pi.waveMask(pulse.On, used)
+		if err != nil {
+			return nil, errors.Wrapf(err, "pulse %d", idx)
+		}
+		off, err := pi.waveMask(pulse.Off, used)
+		if err != nil {
+			return nil, errors.Wrapf(err, "pulse %d", idx)
+		}
+		if on&off != 0 {
+			return nil, errors.Errorf("pulse %d switches the same pin on and off", idx)
+		}
+		daemonPulses[idx] = pigpio.Pulse{On: on, Off: off, DelayUS: uint32(pulse.DelayUS)}
+	}
+
+	for bcom := range used {
+		if res := pi.daemon.SetMode(bcom, pigpio.Output); res != 0 {
+			return nil, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to set mode")
+		}
+	}
+	total := pi.daemon.WaveAddGeneric(daemonPulses)
+	if total < 0 {
+		return nil, rpiutils.ConvertErrorCodeToMessage(int(total), "failed to add pulses")
+	}
+	pi.waveBuilding = true
+	return map[string]interface{}{"pulses": int(total)}, nil
+}
+
+// waveMask converts pin names into a bit mask of broadcom pins. Waves can only drive pins that are
+// configured as `gpio` pins on this board.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) waveMask(names []string, used map[uint]struct{}) (uint32, error) {
+	var mask uint32
+	for _, name := range names {
+		bcom, ok := pi.configuredGPIO(name)
+		if !ok {
+			return 0, errors.Errorf("pin %s is not configured as a gpio pin on this board", name)
+		}
+		mask |= 1 << bcom
+		used[bcom] = struct{}{}
+	}
+	return mask, nil
+}
+
+// configuredGPIO resolves the name or label of a pin configured as `gpio` to its broadcom pin.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) configuredGPIO(name string) (uint, bool) {
+	for _, c := range pi.pinConfigs {
+		if c.Type != rpiutils.PinGPIO || (c.Name != name && c.Pin != name) {
+			continue
+		}
+		return rpiutils.BroadcomPinFromHardwareLabel(c.Pin)
+	}
+	return 0, false
+}
+
+// waveChain transmits a chain of waves created by this board.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) waveChain(cmd map[string]interface{}) (map[string]interface{}, error) {
+	buf, ids, err := rpiutils.EncodeWaveChain(cmd)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, ok := pi.waves[id]; !ok {
+			return nil, errors.Errorf("wave %d was not created by this board", id)
+		}
+	}
+	if res := pi.daemon.WaveChain(buf); res != 0 {
+		return nil, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to send wave chain")
+	}
+	return map[string]interface{}{}, nil
+}
+
+// waveIDFromCommand reads the "wave_id" argument and checks that the wave was created by this board.
+// The board mutex should be locked before calling this.
+func (pi *piPigpio) waveIDFromCommand(cmd map[string]interface{}) (uint, error) {
+	id, err := rpiutils.UintFromCommand(cmd, "wave_id")
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := pi.waves[id]; !ok {
+		return 0, errors.Errorf("wave %d was not created by this board", id)
+	}
+	return id, nil
+}
+
+// teardownWaves stops any wave being transmitted and deletes every wave this board created, so
+// the daemon does not run out of wave ids across restarts.
+func teardownWaves(pi *piPigpio) error {
+	if len(pi.waves) == 0 {
+		return nil
+	}
+	var err error
+	if res := pi.daemon.WaveTxStop(); res != 0 {
+		err = multierr.Combine(err, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to stop wave"))
+	}
+	for id := range pi.waves {
+		if res := pi.daemon.WaveDelete(id); res != 0 {
+			err = multierr.Combine(err, rpiutils.ConvertErrorCodeToMessage(int(res), "failed to delete wave"))
+		}
+	}
+	pi.waves = map[uint]struct{}{}
+	return err
+}
@@ -3,7 +3,6 @@ package rpi_test
 
 import (
 	"context"
-	"os"
 	"testing"
 	"time"
 
@@ -12,6 +11,7 @@ import (
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/test"
+	"raspberry-pi/pigpio/fakepigpiod"
 	"raspberry-pi/rpi"
 	rpiservo "raspberry-pi/rpi-servo"
 	rpiutils "raspberry-pi/utils"
@@ -24,11 +24,15 @@ func TestPiPigpio(t *testing.T) {
 
 	ctx := context.Background()
 	logger := logging.NewTestLogger(t)
+	endpoint := startFakeDaemon(t)
 
 	cfg := rpiutils.Config{
 		Pins: []rpiutils.PinConfig{
 			{Name: "i1", Pin: "11", Type: "interrupt"}, // bcom 17
 		},
+		PigpiodHost: endpoint.Host,
+		PigpiodPort: endpoint.Port,
+		Pigpiod:     rpiutils.PigpiodConfig{Mode: rpiutils.PigpiodModeExternal},
 	}
 
 	piInt, err := piReg.Constructor(
@@ -40,12 +44,6 @@ func TestPiPigpio(t *testing.T) {
 		},
 		logger,
 	)
-
-	if os.Getuid() != 0 || err != nil && err.Error() == "not running on a pi" {
-		t.Skip("not running as root on a pi")
-		return
-	}
-
 	test.That(t, err, test.ShouldBeNil)
 	p := piInt.(board.Board)
 
@@ -180,8 +178,12 @@ func TestPiPigpio(t *testing.T) {
 			ctx,
 			nil,
 			resource.Config{
-				Name:                "servo",
-				ConvertedAttributes: &rpiservo.ServoConfig{Pin: "22"},
+				Name: "servo",
+				ConvertedAttributes: &rpiservo.ServoConfig{
+					Pin:         "22",
+					PigpiodHost: endpoint.Host,
+					PigpiodPort: endpoint.Port,
+				},
 			},
 			logger,
 		)
@@ -215,3 +217,13 @@ func TestPiPigpio(t *testing.T) {
 		test.That(t, int(v), test.ShouldEqual, 120)
 	})
 }
+
+// startFakeDaemon starts a fake pigpio daemon for the duration of the test.
+func startFakeDaemon(t *testing.T) rpiutils.PigpiodEndpoint {
+	daemon, err := fakepigpiod.New()
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() {
+		test.That(t, daemon.Close(), test.ShouldBeNil)
+	})
+	return daemon.Endpoint()
+}
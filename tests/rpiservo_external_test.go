@@ -14,6 +14,7 @@ import (
 func TestPiServo(t *testing.T) {
 	ctx := context.Background()
 	logger := logging.NewTestLogger(t)
+	endpoint := startFakeDaemon(t)
 
 	t.Run("servo initialize with pin error", func(t *testing.T) {
 		servoReg, ok := resource.LookupRegistration(servo.API, rpiservo.Model)
@@ -40,8 +41,12 @@ func TestPiServo(t *testing.T) {
 			ctx,
 			nil,
 			resource.Config{
-				Name:                "servo",
-				ConvertedAttributes: &rpiservo.ServoConfig{Pin: "22"},
+				Name: "servo",
+				ConvertedAttributes: &rpiservo.ServoConfig{
+					Pin:         "22",
+					PigpiodHost: endpoint.Host,
+					PigpiodPort: endpoint.Port,
+				},
 			},
 			logger,
 		)
@@ -64,8 +69,13 @@ func TestPiServo(t *testing.T) {
 			ctx,
 			nil,
 			resource.Config{
-				Name:                "servo",
-				ConvertedAttributes: &rpiservo.ServoConfig{Pin: "22", StartPos: &initPos},
+				Name: "servo",
+				ConvertedAttributes: &rpiservo.ServoConfig{
+					Pin:         "22",
+					StartPos:    &initPos,
+					PigpiodHost: endpoint.Host,
+					PigpiodPort: endpoint.Port,
+				},
 			},
 			logger,
 		)
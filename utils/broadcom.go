@@ -7,6 +7,37 @@ import "fmt"
 // Original default from libpigpio.
 const DefaultPWMFreqHz = uint(800)
 
+// hardwarePWMChannels maps the broadcom pins that can be driven by the PWM peripheral on the Pi 0-4
+// to the PWM channel they use. Pins on the same channel always output the same signal.
+var hardwarePWMChannels = map[uint]int{
+	12: 0,
+	18: 0,
+	13: 1,
+	19: 1,
+}
+
+// HardwarePWMChannel returns the hardware PWM channel of a broadcom pin, if it has one.
+func HardwarePWMChannel(bcom uint) (int, bool) {
+	channel, ok := hardwarePWMChannels[bcom]
+	return channel, ok
+}
+
+// generalPurposeClocks maps the broadcom pins on the header that can output a general purpose clock
+// to the clock they use. Pins on the same clock always output the same frequency.
+var generalPurposeClocks = map[uint]int{
+	4:  0,
+	5:  1,
+	6:  2,
+	20: 0,
+	21: 1,
+}
+
+// GeneralPurposeClock returns the general purpose clock (GPCLK) of a broadcom pin, if it has one.
+func GeneralPurposeClock(bcom uint) (int, bool) {
+	clock, ok := generalPurposeClocks[bcom]
+	return clock, ok
+}
+
 // piHWPinToBroadcom maps the hardware inscribed pin number to
 // its Broadcom pin. For the sake of programming, a user typically
 // knows the hardware pin since they have the board on hand but does
@@ -0,0 +1,139 @@
+// Package rpiutils contains the DoCommand names and argument helpers shared by the boards.
+package rpiutils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// DoCommandKey is the key in a DoCommand request that selects which command to run.
+const DoCommandKey = "command"
+
+const (
+	// PinStateCommand reports the live hardware state of one or all pins, similar to `pinctrl get`.
+	PinStateCommand = "pin_state"
+	// SetClockFrequencyCommand changes the frequency of a clock pin at runtime.
+	SetClockFrequencyCommand = "set_clock_frequency"
+
+	// WaveAddGenericCommand adds pulses to the wave that is being built.
+	WaveAddGenericCommand = "wave_add_generic"
+	// WaveCreateCommand turns the pulses added so far into a wave and returns its id.
+	WaveCreateCommand = "wave_create"
+	// WaveDeleteCommand deletes a wave that is no longer needed.
+	WaveDeleteCommand = "wave_delete"
+	// WaveSendOnceCommand transmits a wave once.
+	WaveSendOnceCommand = "wave_send_once"
+	// WaveSendRepeatCommand transmits a wave repeatedly until it is stopped.
+	WaveSendRepeatCommand = "wave_send_repeat"
+	// WaveChainCommand transmits a sequence of waves, with optional loops and delays.
+	WaveChainCommand = "wave_chain"
+	// WaveTxBusyCommand reports whether a wave is being transmitted.
+	WaveTxBusyCommand = "wave_tx_busy"
+	// WaveTxStopCommand stops the wave that is being transmitted.
+	WaveTxStopCommand = "wave_tx_stop"
+
+	// SoftUARTReadCommand returns the data a soft uart received since the last read.
+	SoftUARTReadCommand = "soft_uart_read"
+	// SoftUARTWriteCommand transmits data on a soft uart.
+	SoftUARTWriteCommand = "soft_uart_write"
+
+	// I2CTransactionCommand writes to and then reads from a device on one of the board's i2c buses.
+	I2CTransactionCommand = "i2c_transaction"
+
+	// WatchdogTimeoutsCommand reports how often the watchdog of one or all interrupts fired.
+	WatchdogTimeoutsCommand = "watchdog_timeouts"
+	// MeasureCommand reports the frequency, period, high time and duty cycle of the signal on one or
+	// all interrupts.
+	MeasureCommand = "measure"
+	// ResetCounterCommand sets the value of one or all interrupts to zero or a preset.
+	ResetCounterCommand = "reset_counter"
+
+	// TickHistoryCommand returns the recent ticks of one or all interrupts.
+	TickHistoryCommand = "tick_history"
+	// TickQueueStatsCommand reports the queue of every StreamTicks client, and how many ticks it dropped.
+	TickQueueStatsCommand = "tick_queue_stats"
+
+	// PigpiodHealthCommand reports the health of the connection to the pigpio daemon and how often
+	// it had to be reestablished.
+	PigpiodHealthCommand = "pigpiod_health"
+)
+
+// PinFromCommand returns the optional "pin" argument of a DoCommand request. An empty string
+// means the command should apply to every pin.
+func PinFromCommand(cmd map[string]interface{}) (string, error) {
+	raw, ok := cmd["pin"]
+	if !ok {
+		return "", nil
+	}
+	pin, ok := raw.(string)
+	if !ok {
+		return "", fmt.Errorf("expected \"pin\" to be a string, got %T", raw)
+	}
+	return pin, nil
+}
+
+// UintFromCommand returns a required non-negative integer argument of a DoCommand request. Numbers
+// arrive as float64 once they have been through JSON, so both are accepted.
+func UintFromCommand(cmd map[string]interface{}, key string) (uint, error) {
+	raw, ok := cmd[key]
+	if !ok {
+		return 0, fmt.Errorf("missing %q", key)
+	}
+	var value float64
+	switch v := raw.(type) {
+	case float64:
+		value = v
+	case int:
+		value = float64(v)
+	default:
+		return 0, fmt.Errorf("expected %q to be a number, got %T", key, raw)
+	}
+	if value < 0 || value != float64(uint(value)) {
+		return 0, fmt.Errorf("expected %q to be a non-negative integer, got %v", key, value)
+	}
+	return uint(value), nil
+}
+
+// PresetFromCommand returns the optional "preset" argument of a reset_counter request, or 0 if it is
+// not given. The preset can be any integer, including a negative one.
+func PresetFromCommand(cmd map[string]interface{}) (int64, error) {
+	raw, ok := cmd["preset"]
+	if !ok {
+		return 0, nil
+	}
+	switch v := raw.(type) {
+	case int:
+		return int64(v), nil
+	case float64:
+		// -2^63 is the smallest int64 and 2^63 is one past the largest; both are exact as float64s
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= -math.MinInt64 {
+			return 0, fmt.Errorf("expected \"preset\" to be an integer, got %v", v)
+		}
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("expected \"preset\" to be a number, got %T", raw)
+	}
+}
+
+// SinceFromCommand returns the optional "since" argument of a tick_history request, a Unix time in
+// nanoseconds, or 0 if it is not given. Numbers lose precision on their way through JSON, so the
+// time can also be given as a string.
+func SinceFromCommand(cmd map[string]interface{}) (uint64, error) {
+	raw, ok := cmd["since"]
+	if !ok {
+		return 0, nil
+	}
+	if s, ok := raw.(string); ok {
+		since, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected \"since\" to be a Unix time in nanoseconds, got %q", s)
+		}
+		return since, nil
+	}
+	since, err := UintFromCommand(cmd, "since")
+	if err != nil {
+		return 0, err
+	}
+	return uint64(since), nil
+}
@@ -0,0 +1,85 @@
+package rpiutils
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestPinFromCommand(t *testing.T) {
+	pin, err := PinFromCommand(map[string]interface{}{"command": PinStateCommand})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pin, test.ShouldEqual, "")
+
+	pin, err = PinFromCommand(map[string]interface{}{"command": PinStateCommand, "pin": "11"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pin, test.ShouldEqual, "11")
+
+	_, err = PinFromCommand(map[string]interface{}{"command": PinStateCommand, "pin": 11})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestUintFromCommand(t *testing.T) {
+	value, err := UintFromCommand(map[string]interface{}{"frequency_hz": 50000.0}, "frequency_hz")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, value, test.ShouldEqual, uint(50000))
+
+	value, err = UintFromCommand(map[string]interface{}{"frequency_hz": 7}, "frequency_hz")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, value, test.ShouldEqual, uint(7))
+
+	_, err = UintFromCommand(map[string]interface{}{}, "frequency_hz")
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = UintFromCommand(map[string]interface{}{"frequency_hz": "fast"}, "frequency_hz")
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = UintFromCommand(map[string]interface{}{"frequency_hz": -1.0}, "frequency_hz")
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = UintFromCommand(map[string]interface{}{"frequency_hz": 1.5}, "frequency_hz")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestPresetFromCommand(t *testing.T) {
+	preset, err := PresetFromCommand(map[string]interface{}{})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, preset, test.ShouldEqual, 0)
+
+	preset, err = PresetFromCommand(map[string]interface{}{"preset": 1500.0})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, preset, test.ShouldEqual, 1500)
+
+	preset, err = PresetFromCommand(map[string]interface{}{"preset": -250.0})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, preset, test.ShouldEqual, -250)
+
+	preset, err = PresetFromCommand(map[string]interface{}{"preset": -7})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, preset, test.ShouldEqual, -7)
+
+	_, err = PresetFromCommand(map[string]interface{}{"preset": 1.5})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = PresetFromCommand(map[string]interface{}{"preset": 1e19})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = PresetFromCommand(map[string]interface{}{"preset": "100"})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestSinceFromCommand(t *testing.T) {
+	since, err := SinceFromCommand(map[string]interface{}{})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, since, test.ShouldEqual, 0)
+
+	since, err = SinceFromCommand(map[string]interface{}{"since": "1700000000123456789"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, since, test.ShouldEqual, uint64(1700000000123456789))
+
+	since, err = SinceFromCommand(map[string]interface{}{"since": 1000.0})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, since, test.ShouldEqual, 1000)
+
+	_, err = SinceFromCommand(map[string]interface{}{"since": "yesterday"})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = SinceFromCommand(map[string]interface{}{"since": -1.0})
+	test.That(t, err, test.ShouldNotBeNil)
+}
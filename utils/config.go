@@ -23,7 +23,24 @@ type BoardSettings struct {
 type Config struct {
 	AnalogReaders []mcp3008helper.MCP3008AnalogConfig `json:"analogs,omitempty"`
 	Pins          []PinConfig                         `json:"pins,omitempty"`
+	SoftUARTs     []SoftUARTConfig                    `json:"soft_uarts,omitempty"`
+	I2Cs          []I2CConfig                         `json:"i2cs,omitempty"`
+	SoftSPIs      []SoftSPIConfig                     `json:"soft_spis,omitempty"`
 	BoardSettings BoardSettings                       `json:"board_settings"`
+	// TickQueue is the default queue of the ticks streamed to each StreamTicks client.
+	TickQueue TickQueueConfig `json:"tick_queue,omitempty"`
+
+	// PigpiodHost and PigpiodPort select the pigpio daemon the Pi 0-4 boards talk to, which can run on
+	// a non-default port or on a different machine.
+	PigpiodHost string `json:"pigpiod_host,omitempty"`
+	PigpiodPort int    `json:"pigpiod_port,omitempty"`
+	// Pigpiod selects whether the module starts the daemon itself, and the options it runs with.
+	Pigpiod PigpiodConfig `json:"pigpiod,omitempty"`
+}
+
+// PigpiodEndpoint returns the configured pigpio daemon endpoint.
+func (conf *Config) PigpiodEndpoint() PigpiodEndpoint {
+	return PigpiodEndpoint{Host: conf.PigpiodHost, Port: conf.PigpiodPort}
 }
 
 // Validate ensures all parts of the config are valid.
@@ -34,10 +51,194 @@ func (conf *Config) Validate(path string) ([]string, []string, error) {
 		}
 	}
 
-	for _, c := range conf.Pins {
-		if err := c.Validate(path); err != nil {
-			return nil, nil, err
+	if err := validatePigpiodPort(conf.PigpiodPort); err != nil {
+		return nil, nil, resource.NewConfigValidationError(path+".pigpiod_port", err)
+	}
+	if err := conf.Pigpiod.Validate(path+".pigpiod", conf.PigpiodEndpoint()); err != nil {
+		return nil, nil, err
+	}
+
+	// the pins of a local daemon are checked against the pi the module runs on, and against every pi
+	// if it is unknown
+	var hw PiHardware
+	if conf.PigpiodEndpoint().IsLocal() {
+		hw, _ = DetectPiHardware()
+	}
+	if err := conf.validatePins(path, hw); err != nil {
+		return nil, nil, err
+	}
+	if err := conf.TickQueue.Validate(path + ".tick_queue"); err != nil {
+		return nil, nil, err
+	}
+
+	// pins that only set a pull can be shared with soft uarts and bit-banged buses
+	usedPins := map[uint]string{}
+	for idx, c := range conf.Pins {
+		if bcom, ok := BroadcomPinFromHardwareLabel(c.Pin); ok && c.Type != "" {
+			usedPins[bcom] = fmt.Sprintf("%s.pins.%d", path, idx)
 		}
 	}
+	if err := conf.validateSoftUARTs(path, usedPins); err != nil {
+		return nil, nil, err
+	}
+	if err := conf.validateI2Cs(path, usedPins); err != nil {
+		return nil, nil, err
+	}
+	if err := conf.validateSoftSPIs(path, usedPins); err != nil {
+		return nil, nil, err
+	}
 	return nil, nil, nil
 }
+
+// validateSoftUARTs validates each soft uart and checks that their names are unique and that they do
+// not use a pin that is already in use. usedPins maps broadcom pins to the config path using them.
+func (conf *Config) validateSoftUARTs(path string, usedPins map[uint]string) error {
+	names := map[string]int{}
+	for idx, c := range conf.SoftUARTs {
+		uartPath := fmt.Sprintf("%s.%s.%d", path, "soft_uarts", idx)
+		if err := c.Validate(uartPath); err != nil {
+			return err
+		}
+		if other, ok := names[c.Name]; ok {
+			return resource.NewConfigValidationError(uartPath+".name",
+				fmt.Errorf("name %q is already used by %s.soft_uarts.%d", c.Name, path, other))
+		}
+		names[c.Name] = idx
+
+		for _, pin := range c.pins() {
+			bcom, _ := BroadcomPinFromHardwareLabel(pin.label)
+			if other, ok := usedPins[bcom]; ok {
+				return resource.NewConfigValidationError(uartPath+"."+pin.field,
+					fmt.Errorf("pin %q is already used by %s", pin.label, other))
+			}
+			usedPins[bcom] = uartPath
+		}
+	}
+	return nil
+}
+
+// validateI2Cs validates each i2c bus and checks that their names are unique, that every hardware
+// bus is only configured once, and that bit-banged buses do not use a pin that is already in use.
+// usedPins maps broadcom pins to the config path using them.
+func (conf *Config) validateI2Cs(path string, usedPins map[uint]string) error {
+	names := map[string]int{}
+	hardwareBuses := map[int]int{}
+	for idx, c := range conf.I2Cs {
+		busPath := fmt.Sprintf("%s.%s.%d", path, "i2cs", idx)
+		if err := c.Validate(busPath); err != nil {
+			return err
+		}
+		if other, ok := names[c.Name]; ok {
+			return resource.NewConfigValidationError(busPath+".name",
+				fmt.Errorf("name %q is already used by %s.i2cs.%d", c.Name, path, other))
+		}
+		names[c.Name] = idx
+
+		if c.Bus != nil {
+			if other, ok := hardwareBuses[*c.Bus]; ok {
+				return resource.NewConfigValidationError(busPath+".bus",
+					fmt.Errorf("bus %d is already configured by %s.i2cs.%d", *c.Bus, path, other))
+			}
+			hardwareBuses[*c.Bus] = idx
+			continue
+		}
+		for _, pin := range []struct{ field, label string }{{"bb_sda", c.BBSDA}, {"bb_scl", c.BBSCL}} {
+			bcom, _ := BroadcomPinFromHardwareLabel(pin.label)
+			if other, ok := usedPins[bcom]; ok {
+				return resource.NewConfigValidationError(busPath+"."+pin.field,
+					fmt.Errorf("pin %q is already used by %s", pin.label, other))
+			}
+			usedPins[bcom] = busPath
+		}
+	}
+	return nil
+}
+
+// validatePins validates each pin and checks the pins against each other. Every pin has to exist in
+// the pin table, names have to be unique and cannot be the label of a different pin, a pin can only
+// be listed more than once if the entries do not configure it as different types, and only one pin
+// per hardware pwm channel can require hardware pwm and only one pin per general purpose clock can
+// be a clock. Hardware pwm cannot be required if pigpiod times its samples with the PWM peripheral.
+// Clocks need a frequency the pi supports, except on RP1 based pis such as the Pi 5, where the
+// firmware sets it.
+func (conf *Config) validatePins(path string, hw PiHardware) error {
+	names := map[string]int{}
+	pinTypes := map[uint]int{}
+	hardwarePWMChannels := map[int]int{}
+	clocks := map[int]int{}
+	for idx, c := range conf.Pins {
+		pinPath := fmt.Sprintf("%s.%s.%d", path, "pins", idx)
+		if err := c.Validate(pinPath); err != nil {
+			return err
+		}
+
+		bcom, ok := BroadcomPinFromHardwareLabel(c.Pin)
+		if !ok {
+			return resource.NewConfigValidationError(pinPath+".pin", fmt.Errorf("unknown pin %q", c.Pin))
+		}
+
+		name := c.Name
+		if name == "" {
+			name = c.Pin
+		}
+		if labelPin, isLabel := BroadcomPinFromHardwareLabel(name); isLabel && labelPin != bcom {
+			return resource.NewConfigValidationError(pinPath+".name",
+				fmt.Errorf("name %q is the label of a different pin", name))
+		}
+		if other, ok := names[name]; ok {
+			return resource.NewConfigValidationError(pinPath+".name",
+				fmt.Errorf("name %q is already used by %s.pins.%d", name, path, other))
+		}
+		names[name] = idx
+
+		if c.PWMMode == PWMModeHardware {
+			if conf.Pigpiod.UsesPWMClock() {
+				return resource.NewConfigValidationError(pinPath+".pwm_mode",
+					fmt.Errorf("pin %q cannot use hardware pwm, pigpiod uses the PWM peripheral as its clock", c.Pin))
+			}
+			channel, _ := HardwarePWMChannel(bcom)
+			if other, ok := hardwarePWMChannels[channel]; ok && sharesChannel(conf.Pins[other], bcom) {
+				return resource.NewConfigValidationError(pinPath+".pwm_mode",
+					fmt.Errorf("pin %q shares hardware pwm channel %d with %s.pins.%d", c.Pin, channel, path, other))
+			}
+			hardwarePWMChannels[channel] = idx
+		}
+
+		if c.Type == PinClock {
+			clock, _ := GeneralPurposeClock(bcom)
+			if other, ok := clocks[clock]; ok && sharesChannel(conf.Pins[other], bcom) {
+				return resource.NewConfigValidationError(pinPath+".pin",
+					fmt.Errorf("pin %q shares general purpose clock %d with %s.pins.%d", c.Pin, clock, path, other))
+			}
+			clocks[clock] = idx
+			// the clocks of the RP1 on the Pi 5 run at the frequency the firmware set, which the module
+			// can't change, neither from frequency_hz nor with set_clock_frequency
+			if c.FrequencyHz == 0 && !hw.UsesRP1() {
+				return resource.NewConfigValidationFieldRequiredError(pinPath, "frequency_hz")
+			}
+			if c.FrequencyHz != 0 {
+				if err := ValidateClockFrequency(c.FrequencyHz, hw); err != nil {
+					return resource.NewConfigValidationError(pinPath+".frequency_hz", err)
+				}
+			}
+		}
+
+		// entries without a type only set the pull, so they can share a pin with any type
+		if c.Type == "" {
+			continue
+		}
+		if other, ok := pinTypes[bcom]; ok && conf.Pins[other].Type != c.Type {
+			return resource.NewConfigValidationError(pinPath+".pin",
+				fmt.Errorf("pin %q is already configured as %v by %s.pins.%d", c.Pin, conf.Pins[other].Type, path, other))
+		}
+		pinTypes[bcom] = idx
+	}
+	return nil
+}
+
+// sharesChannel returns whether other is configured on a different pin than bcom. It is only called
+// for pins on the same hardware pwm channel or general purpose clock.
+func sharesChannel(other PinConfig, bcom uint) bool {
+	otherBcom, _ := BroadcomPinFromHardwareLabel(other.Pin)
+	return otherBcom != bcom
+}
@@ -0,0 +1,153 @@
+package rpiutils
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestConfigValidatePins(t *testing.T) {
+	validate := func(pins ...PinConfig) error {
+		conf := Config{Pins: pins}
+		_, _, err := conf.Validate("attributes")
+		return err
+	}
+
+	t.Run("valid pins", func(t *testing.T) {
+		err := validate(
+			PinConfig{Name: "led", Pin: "11", Type: PinGPIO},
+			PinConfig{Name: "button", Pin: "13", Type: PinInterrupt, DebounceMS: 5, PullState: PullUp},
+			PinConfig{Pin: "13", PullState: PullUp},
+			PinConfig{Name: "15", Pin: "15", Type: PinGPIO},
+			PinConfig{Name: "io22", Pin: "15", Type: PinGPIO},
+		)
+		test.That(t, err, test.ShouldBeNil)
+	})
+
+	t.Run("unknown pin", func(t *testing.T) {
+		err := validate(
+			PinConfig{Name: "led", Pin: "11", Type: PinGPIO},
+			PinConfig{Name: "ground", Pin: "6", Type: PinGPIO},
+		)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.1.pin")
+	})
+
+	t.Run("duplicate names", func(t *testing.T) {
+		err := validate(
+			PinConfig{Name: "led", Pin: "11", Type: PinGPIO},
+			PinConfig{Name: "led", Pin: "13", Type: PinGPIO},
+		)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.1.name")
+	})
+
+	t.Run("conflicting types on one pin", func(t *testing.T) {
+		err := validate(
+			PinConfig{Name: "led", Pin: "11", Type: PinGPIO},
+			PinConfig{Name: "counter", Pin: "io17", Type: PinInterrupt},
+		)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.1.pin")
+	})
+
+	t.Run("debounce on a gpio", func(t *testing.T) {
+		err := validate(PinConfig{Name: "led", Pin: "11", Type: PinGPIO, DebounceMS: 5})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.0.debounce_ms")
+	})
+
+	t.Run("name shadows a built in label", func(t *testing.T) {
+		err := validate(PinConfig{Name: "sda", Pin: "11", Type: PinGPIO})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.0.name")
+
+		test.That(t, validate(PinConfig{Name: "sda", Pin: "3", Type: PinGPIO}), test.ShouldBeNil)
+	})
+
+	t.Run("invalid type", func(t *testing.T) {
+		err := validate(PinConfig{Name: "led", Pin: "11", Type: "output"})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.0.type")
+	})
+
+	t.Run("pwm modes", func(t *testing.T) {
+		err := validate(
+			PinConfig{Name: "fan", Pin: "12", Type: PinGPIO, PWMMode: PWMModeHardware},
+			PinConfig{Name: "pump", Pin: "33", Type: PinGPIO, PWMMode: PWMModeHardware},
+			PinConfig{Name: "led", Pin: "32", Type: PinGPIO, PWMMode: PWMModeAuto},
+			PinConfig{Name: "buzzer", Pin: "11", Type: PinGPIO, PWMMode: PWMModeSoftware},
+		)
+		test.That(t, err, test.ShouldBeNil)
+
+		err = validate(PinConfig{Name: "led", Pin: "11", Type: PinGPIO, PWMMode: PWMModeHardware})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.0.pwm_mode")
+
+		err = validate(PinConfig{Name: "led", Pin: "12", Type: PinGPIO, PWMMode: "fast"})
+		test.That(t, err, test.ShouldNotBeNil)
+
+		err = validate(PinConfig{Name: "counter", Pin: "12", Type: PinInterrupt, PWMMode: PWMModeAuto})
+		test.That(t, err, test.ShouldNotBeNil)
+
+		// gpio 12 and gpio 18 share hardware pwm channel 0
+		err = validate(
+			PinConfig{Name: "fan", Pin: "32", Type: PinGPIO, PWMMode: PWMModeHardware},
+			PinConfig{Name: "pump", Pin: "12", Type: PinGPIO, PWMMode: PWMModeHardware},
+		)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.1.pwm_mode")
+	})
+
+	t.Run("clock pins", func(t *testing.T) {
+		err := validate(
+			PinConfig{Name: "clk0", Pin: "7", Type: PinClock, FrequencyHz: 1000000},
+			PinConfig{Name: "clk1", Pin: "40", Type: PinClock, FrequencyHz: 50000},
+		)
+		test.That(t, err, test.ShouldBeNil)
+
+		err = validate(PinConfig{Name: "clk", Pin: "11", Type: PinClock, FrequencyHz: 1000000})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.0.pin")
+
+		err = validate(PinConfig{Name: "gpclk", Pin: "7", Type: PinClock})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "frequency_hz")
+
+		err = validate(PinConfig{Name: "gpclk", Pin: "7", Type: PinClock, FrequencyHz: 100})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.0.frequency_hz")
+
+		err = validate(PinConfig{Name: "led", Pin: "7", Type: PinGPIO, FrequencyHz: 1000000})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.0.frequency_hz")
+
+		// the firmware sets the frequency of the clocks of the Pi 5
+		conf := Config{Pins: []PinConfig{{Name: "gpclk", Pin: "7", Type: PinClock}}}
+		test.That(t, conf.validatePins("attributes", PiHardware{ModelName: "rpi5"}), test.ShouldBeNil)
+		err = conf.validatePins("attributes", PiHardware{ModelName: "rpi4"})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "frequency_hz")
+
+		// the range of the clocks depends on the pi
+		conf = Config{Pins: []PinConfig{{Name: "gpclk", Pin: "7", Type: PinClock, FrequencyHz: 300000000}}}
+		test.That(t, conf.validatePins("attributes", PiHardware{ModelName: "rpi4"}), test.ShouldBeNil)
+		test.That(t, conf.validatePins("attributes", PiHardware{}), test.ShouldBeNil)
+		err = conf.validatePins("attributes", PiHardware{ModelName: "rpi3"})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.0.frequency_hz")
+		conf.Pins[0].FrequencyHz = 5000
+		test.That(t, conf.validatePins("attributes", PiHardware{ModelName: "rpi3"}), test.ShouldBeNil)
+		err = conf.validatePins("attributes", PiHardware{ModelName: "rpi4"})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "13184-375000000 Hz")
+
+		// gpio 4 and gpio 20 share GPCLK0
+		err = validate(
+			PinConfig{Name: "clk0", Pin: "7", Type: PinClock, FrequencyHz: 1000000},
+			PinConfig{Name: "clk1", Pin: "38", Type: PinClock, FrequencyHz: 1000000},
+		)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.1.pin")
+	})
+}
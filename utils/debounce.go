@@ -0,0 +1,186 @@
+package rpiutils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/resource"
+)
+
+// DebounceMode defines how the changes of an interrupt pin are debounced.
+type DebounceMode string
+
+const (
+	// DebounceModeSoftware reports a change right away and ignores the changes that follow it for
+	// debounce_ms. If the pin settled on a different level by then, that level is reported too.
+	DebounceModeSoftware DebounceMode = "software"
+	// DebounceModeGlitch only reports a level once the pin kept it for debounce_ms. It uses the
+	// glitch filter of pigpiod on the Pi 0-4.
+	DebounceModeGlitch DebounceMode = "glitch"
+	// DebounceModeNoise ignores changes until the pin kept a level for debounce_ms. It uses the noise
+	// filter of pigpiod on the Pi 0-4.
+	DebounceModeNoise DebounceMode = "noise"
+	// DebounceModeDefault is for if no debounce mode was set, and behaves like DebounceModeSoftware.
+	DebounceModeDefault DebounceMode = ""
+)
+
+// MaxDaemonDebounceMS is the longest debounce the filters of pigpiod support.
+const MaxDaemonDebounceMS = 300
+
+// Validate validates that the debounce mode is a valid mode.
+func (mode DebounceMode) Validate() error {
+	switch mode {
+	case DebounceModeDefault, DebounceModeSoftware, DebounceModeGlitch, DebounceModeNoise:
+	default:
+		return fmt.Errorf("invalid debounce mode %v, supported debounce modes are software, glitch, and noise", mode)
+	}
+	return nil
+}
+
+// validateDebounceMode checks the debounce mode of an interrupt, and that the filters of pigpiod
+// are given a debounce they support.
+func (config *PinConfig) validateDebounceMode(path string) error {
+	if err := config.DebounceMode.Validate(); err != nil {
+		return resource.NewConfigValidationError(path+".debounce_mode", err)
+	}
+	if config.DebounceMode == DebounceModeDefault {
+		return nil
+	}
+	if config.Type != PinInterrupt {
+		return resource.NewConfigValidationError(path+".debounce_mode",
+			fmt.Errorf("debounce_mode is only used with pins of type %v", PinInterrupt))
+	}
+	if config.DebounceMode == DebounceModeSoftware {
+		return nil
+	}
+	if config.DebounceMS == 0 {
+		return resource.NewConfigValidationFieldRequiredError(path, "debounce_ms")
+	}
+	if config.DebounceMS > MaxDaemonDebounceMS {
+		return resource.NewConfigValidationError(path+".debounce_ms",
+			fmt.Errorf("debounce_ms cannot be more than %d with debounce_mode %v", MaxDaemonDebounceMS, config.DebounceMode))
+	}
+	return nil
+}
+
+// levelChange is a change of the level of a pin, at a time in nanoseconds.
+type levelChange struct {
+	high        bool
+	nanoseconds uint64
+}
+
+// A Debouncer filters the level changes of an interrupt pin in software and reports the stable ones,
+// so the last level reported always matches the level the pin settled on. Changes are timestamped by
+// the caller, and reported with the time they happened. Which of them are ticked is up to Tick.
+type Debouncer struct {
+	mode   DebounceMode
+	period time.Duration
+	report func(high bool, nanoseconds uint64)
+
+	mu         sync.Mutex
+	stopped    bool
+	timer      *time.Timer
+	generation int         // only the timer of the current generation may resolve the pending change
+	known      bool        // the level of the pin is known
+	changed    bool        // a change was accepted, so the time of the level is known too
+	level      levelChange // the last stable level
+	pending    *levelChange
+}
+
+// NewDebouncer returns a Debouncer that calls report for every stable change. A period of zero
+// reports every change.
+func NewDebouncer(mode DebounceMode, period time.Duration, report func(high bool, nanoseconds uint64)) *Debouncer {
+	return &Debouncer{mode: mode, period: period, report: report}
+}
+
+// Init records the level the pin had when it started to be watched, without reporting it.
+func (d *Debouncer) Init(high bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.known = true
+	d.level = levelChange{high: high}
+}
+
+// Change records that the pin changed to the given level.
+func (d *Debouncer) Change(high bool, nanoseconds uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return
+	}
+	change := levelChange{high: high, nanoseconds: nanoseconds}
+	if d.period == 0 {
+		d.accept(change)
+		return
+	}
+	periodNs := uint64(d.period.Nanoseconds())
+
+	if d.mode == DebounceModeGlitch || d.mode == DebounceModeNoise {
+		// the pin kept the pending level for the whole period if it only changes again now
+		if d.pending != nil && nanoseconds-d.pending.nanoseconds >= periodNs {
+			d.accept(*d.pending)
+		}
+		d.pending = &change
+		d.schedule(d.period)
+		return
+	}
+
+	if d.pending != nil && nanoseconds-d.level.nanoseconds >= periodNs {
+		// the pin settled on the pending level when the period ended
+		d.accept(*d.pending)
+	}
+	if !d.changed || nanoseconds-d.level.nanoseconds >= periodNs {
+		d.accept(change)
+		return
+	}
+	d.pending = &change
+	d.schedule(time.Duration(d.level.nanoseconds + periodNs - nanoseconds))
+}
+
+// Level returns the last stable level of the pin, which is low until a change has been recorded.
+func (d *Debouncer) Level() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.level.high
+}
+
+// accept makes a change the stable level, and reports it.
+// The debouncer mutex should be locked before calling this.
+func (d *Debouncer) accept(change levelChange) {
+	d.pending = nil
+	if d.known && change.high == d.level.high {
+		return
+	}
+	d.known, d.changed = true, true
+	d.level = change
+	d.report(change.high, change.nanoseconds)
+}
+
+// schedule resolves the pending change once the duration has passed, unless the pin changes again.
+// The debouncer mutex should be locked before calling this.
+func (d *Debouncer) schedule(after time.Duration) {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.generation++
+	generation := d.generation
+	d.timer = time.AfterFunc(after, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.stopped || generation != d.generation || d.pending == nil {
+			return
+		}
+		d.accept(*d.pending)
+	})
+}
+
+// Stop stops the debouncer. A pending change is dropped, and later changes are ignored.
+func (d *Debouncer) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopped = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
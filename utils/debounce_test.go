@@ -0,0 +1,134 @@
+package rpiutils
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+type recordedChanges struct {
+	mu      sync.Mutex
+	changes []levelChange
+}
+
+func (r *recordedChanges) report(high bool, nanoseconds uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, levelChange{high: high, nanoseconds: nanoseconds})
+}
+
+func (r *recordedChanges) get() []levelChange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]levelChange{}, r.changes...)
+}
+
+func TestDebouncer(t *testing.T) {
+	const ms = uint64(time.Millisecond)
+
+	t.Run("no debounce reports every change", func(t *testing.T) {
+		r := &recordedChanges{}
+		d := NewDebouncer(DebounceModeSoftware, 0, r.report)
+		d.Change(true, 1)
+		d.Change(false, 2)
+		d.Change(false, 3)
+		d.Change(true, 4)
+		test.That(t, r.get(), test.ShouldResemble, []levelChange{{true, 1}, {false, 2}, {true, 4}})
+	})
+
+	t.Run("software reports the level the pin settled on", func(t *testing.T) {
+		r := &recordedChanges{}
+		d := NewDebouncer(DebounceModeSoftware, 10*time.Millisecond, r.report)
+		defer d.Stop()
+
+		// the first change is reported right away and the bounces after it are not
+		d.Change(true, 100*ms)
+		d.Change(false, 101*ms)
+		d.Change(true, 102*ms)
+		test.That(t, r.get(), test.ShouldResemble, []levelChange{{true, 100 * ms}})
+
+		// a quick press and release ends low, which is reported once the period ended
+		d.Change(false, 200*ms)
+		d.Change(true, 202*ms)
+		d.Change(false, 203*ms)
+		time.Sleep(30 * time.Millisecond)
+		test.That(t, r.get(), test.ShouldResemble, []levelChange{{true, 100 * ms}, {false, 200 * ms}})
+
+		d.Change(true, 300*ms)
+		d.Change(false, 305*ms)
+		time.Sleep(30 * time.Millisecond)
+		test.That(t, r.get(), test.ShouldResemble,
+			[]levelChange{{true, 100 * ms}, {false, 200 * ms}, {true, 300 * ms}, {false, 305 * ms}})
+	})
+
+	t.Run("glitch only reports stable levels", func(t *testing.T) {
+		r := &recordedChanges{}
+		d := NewDebouncer(DebounceModeGlitch, 10*time.Millisecond, r.report)
+		defer d.Stop()
+
+		d.Change(true, 100*ms)
+		d.Change(false, 101*ms)
+		d.Change(true, 102*ms)
+		test.That(t, len(r.get()), test.ShouldEqual, 0)
+
+		// the pin stayed high for the period before this change
+		d.Change(false, 150*ms)
+		test.That(t, r.get(), test.ShouldResemble, []levelChange{{true, 102 * ms}})
+
+		time.Sleep(30 * time.Millisecond)
+		test.That(t, r.get(), test.ShouldResemble, []levelChange{{true, 102 * ms}, {false, 150 * ms}})
+	})
+
+	t.Run("a glitch back to the stable level is not reported", func(t *testing.T) {
+		r := &recordedChanges{}
+		d := NewDebouncer(DebounceModeNoise, 10*time.Millisecond, r.report)
+		defer d.Stop()
+		d.Init(true)
+
+		d.Change(false, 100*ms)
+		d.Change(true, 150*ms)
+		d.Change(false, 151*ms)
+		time.Sleep(30 * time.Millisecond)
+		test.That(t, r.get(), test.ShouldResemble, []levelChange{{false, 100 * ms}})
+	})
+
+	t.Run("stop drops the pending change", func(t *testing.T) {
+		r := &recordedChanges{}
+		d := NewDebouncer(DebounceModeGlitch, 10*time.Millisecond, r.report)
+		d.Change(true, 100*ms)
+		d.Stop()
+		d.Change(false, 200*ms)
+		time.Sleep(30 * time.Millisecond)
+		test.That(t, len(r.get()), test.ShouldEqual, 0)
+	})
+}
+
+func TestValidateDebounceMode(t *testing.T) {
+	config := PinConfig{Name: "button", Pin: "13", Type: PinInterrupt, DebounceMode: DebounceModeGlitch, DebounceMS: 5}
+	test.That(t, config.Validate("path"), test.ShouldBeNil)
+
+	config.DebounceMS = 0
+	err := config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "debounce_ms")
+
+	config.DebounceMS = MaxDaemonDebounceMS + 1
+	err = config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.debounce_ms")
+
+	config = PinConfig{Name: "button", Pin: "13", Type: PinInterrupt, DebounceMode: DebounceModeSoftware}
+	test.That(t, config.Validate("path"), test.ShouldBeNil)
+
+	config.DebounceMode = "hardware"
+	err = config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.debounce_mode")
+
+	config = PinConfig{Name: "led", Pin: "13", Type: PinGPIO, DebounceMode: DebounceModeNoise}
+	err = config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.debounce_mode")
+}
@@ -4,8 +4,10 @@ package rpiutils
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.viam.com/rdk/components/board"
@@ -14,13 +16,32 @@ import (
 
 // PinConfig describes the configuration of a pin for the board.
 type PinConfig struct {
-	Name       string  `json:"name"`
-	Pin        string  `json:"pin"`
-	Type       PinType `json:"type,omitempty"`        // e.g. gpio, interrupt
-	DebounceMS int     `json:"debounce_ms,omitempty"` // only used with interrupts
-	PullState  Pull    `json:"pull,omitempty"`
+	Name            string       `json:"name"`
+	Pin             string       `json:"pin"`
+	Type            PinType      `json:"type,omitempty"`              // e.g. gpio, interrupt
+	DebounceMS      int          `json:"debounce_ms,omitempty"`       // only used with interrupts
+	DebounceMode    DebounceMode `json:"debounce_mode,omitempty"`     // only used with interrupts
+	Edge            Edge         `json:"edge,omitempty"`              // only used with interrupts
+	CountMode       CountMode    `json:"count_mode,omitempty"`        // only used with interrupts
+	WatchdogMS      int          `json:"watchdog_ms,omitempty"`       // only used with interrupts
+	MeasureWindowMS int          `json:"measure_window_ms,omitempty"` // only used with interrupts
+	TickHistorySize int          `json:"tick_history_size,omitempty"` // only used with interrupts
+	Persist         bool         `json:"persist,omitempty"`           // only used with interrupts
+	HighRate        bool         `json:"high_rate,omitempty"`         // only used with interrupts
+	PullState       Pull         `json:"pull,omitempty"`
+	Function        string       `json:"function,omitempty"`     // only used with alt pins, e.g. alt0 or a3 on the Pi 5
+	PWMMode         PWMMode      `json:"pwm_mode,omitempty"`     // only used with gpio pins
+	FrequencyHz     uint         `json:"frequency_hz,omitempty"` // only used with clock pins
 }
 
+const (
+	// MaxWatchdogMS is the longest watchdog_ms an interrupt supports, which is the limit of pigpiod.
+	MaxWatchdogMS = 60000
+	// WatchdogTickSuffix is appended to the name of an interrupt in the ticks that report its
+	// watchdog fired.
+	WatchdogTickSuffix = ":watchdog"
+)
+
 // PinType defines the pin types we support.
 type PinType string
 
@@ -29,6 +50,19 @@ const (
 	PinGPIO PinType = "gpio"
 	// PinInterrupt represents interrupt pins.
 	PinInterrupt PinType = "interrupt"
+	// PinAlt represents pins muxed to one of their alternate functions.
+	PinAlt PinType = "alt"
+	// PinClock represents pins driven by one of the general purpose clocks.
+	PinClock PinType = "clock"
+)
+
+// The frequencies a general purpose clock can output, see hardware_clock in pigpio. The BCM2711 of
+// the Pi 4 runs its clocks from a faster source, which raises both limits.
+const (
+	MinClockFrequencyHz        = 4689
+	MaxClockFrequencyHz        = 250000000
+	MinBCM2711ClockFrequencyHz = 13184
+	MaxBCM2711ClockFrequencyHz = 375000000
 )
 
 // Pull defines the pins pull state(pull up vs pull down).
@@ -55,14 +89,264 @@ func (pull Pull) Validate() error {
 	return nil
 }
 
+// Edge defines which changes of an interrupt pin are reported.
+type Edge string
+
+const (
+	// EdgeRising reports the pin going high.
+	EdgeRising Edge = "rising"
+	// EdgeFalling reports the pin going low.
+	EdgeFalling Edge = "falling"
+	// EdgeBoth reports every change of the pin.
+	EdgeBoth Edge = "both"
+	// EdgeDefault is for if no edge was set, and behaves like EdgeBoth.
+	EdgeDefault Edge = ""
+)
+
+// Validate validates that the edge is a valid edge.
+func (edge Edge) Validate() error {
+	switch edge {
+	case EdgeDefault, EdgeRising, EdgeFalling, EdgeBoth:
+	default:
+		return fmt.Errorf("invalid edge %v, supported edges are rising, falling, and both", edge)
+	}
+	return nil
+}
+
+// reports returns whether a change of the pin to the level is on the edge.
+func (edge Edge) reports(high bool) bool {
+	switch edge {
+	case EdgeRising:
+		return high
+	case EdgeFalling:
+		return !high
+	case EdgeBoth, EdgeDefault:
+	}
+	return true
+}
+
+// CountMode defines which changes of an interrupt pin are counted in its value.
+type CountMode string
+
+const (
+	// CountModeRising counts the pin going high.
+	CountModeRising CountMode = "rising"
+	// CountModeFalling counts the pin going low.
+	CountModeFalling CountMode = "falling"
+	// CountModeBoth counts every change of the pin.
+	CountModeBoth CountMode = "both"
+	// CountModeDefault is for if no count mode was set. It behaves like CountModeFalling on
+	// interrupts on the falling edge, and like CountModeRising on every other interrupt.
+	CountModeDefault CountMode = ""
+)
+
+// Validate validates that the count mode is a valid mode.
+func (mode CountMode) Validate() error {
+	switch mode {
+	case CountModeDefault, CountModeRising, CountModeFalling, CountModeBoth:
+	default:
+		return fmt.Errorf("invalid count mode %v, supported count modes are rising, falling, and both", mode)
+	}
+	return nil
+}
+
+// counts returns whether a change of the pin to the level is counted by an interrupt on the edge.
+func (mode CountMode) counts(high bool, edge Edge) bool {
+	if mode == CountModeDefault {
+		mode = CountModeRising
+		if edge == EdgeFalling {
+			mode = CountModeFalling
+		}
+	}
+	switch mode {
+	case CountModeRising:
+		return high
+	case CountModeFalling:
+		return !high
+	case CountModeBoth, CountModeDefault:
+	}
+	return true
+}
+
+// PWMMode defines how PWM is generated on a gpio pin.
+type PWMMode string
+
+const (
+	// PWMModeSoftware generates PWM with DMA timed software PWM, which works on every pin.
+	PWMModeSoftware PWMMode = "software"
+	// PWMModeHardware uses the PWM peripheral, which is only available on GPIO 12, 13, 18 and 19.
+	PWMModeHardware PWMMode = "hardware"
+	// PWMModeAuto uses hardware PWM when the pin supports it and its channel is free, and software
+	// PWM otherwise.
+	PWMModeAuto PWMMode = "auto"
+	// PWMModeDefault is for if no pwm mode was set, and behaves like PWMModeSoftware.
+	PWMModeDefault PWMMode = ""
+)
+
+// Validate validates that the pwm mode is a valid mode.
+func (mode PWMMode) Validate() error {
+	switch mode {
+	case PWMModeDefault, PWMModeSoftware, PWMModeHardware, PWMModeAuto:
+	default:
+		return fmt.Errorf("invalid pwm mode %v, supported pwm modes are software, hardware, and auto", mode)
+	}
+	return nil
+}
+
+// ParseAltFunction parses an alternate function name into its function number. Pins on the Pi 0-4
+// use alt0-alt5, while the Pi 5's RP1 uses a0-a8; isRP1 reports which naming was used.
+func ParseAltFunction(function string) (int, bool, error) {
+	num, highest, isRP1 := -1, 0, false
+	switch {
+	case strings.HasPrefix(function, "alt"):
+		num, highest = parseAltNumber(strings.TrimPrefix(function, "alt")), 5
+	case strings.HasPrefix(function, "a"):
+		num, highest, isRP1 = parseAltNumber(strings.TrimPrefix(function, "a")), 8, true
+	}
+	if num < 0 || num > highest {
+		return -1, false, fmt.Errorf("invalid alternate function %q, supported functions are alt0-alt5, or a0-a8 on the Pi 5", function)
+	}
+	return num, isRP1, nil
+}
+
+// parseAltNumber parses the single digit after an alternate function prefix, returning -1 if it is not one.
+func parseAltNumber(digit string) int {
+	if len(digit) != 1 || digit[0] < '0' || digit[0] > '9' {
+		return -1
+	}
+	return int(digit[0] - '0')
+}
+
 // Validate ensures all parts of the config are valid.
 func (config *PinConfig) Validate(path string) error {
 	if config.Pin == "" {
 		return resource.NewConfigValidationFieldRequiredError(path, "pin")
 	}
 	if err := config.PullState.Validate(); err != nil {
+		return resource.NewConfigValidationError(path+".pull", err)
+	}
+	switch config.Type {
+	case "", PinGPIO, PinInterrupt, PinAlt, PinClock:
+	default:
+		return resource.NewConfigValidationError(path+".type",
+			fmt.Errorf("invalid pin type %v, supported pin types are %v, %v, %v, and %v",
+				config.Type, PinGPIO, PinInterrupt, PinAlt, PinClock))
+	}
+	if config.DebounceMS < 0 {
+		return resource.NewConfigValidationError(path+".debounce_ms", errors.New("debounce_ms cannot be negative"))
+	}
+	if config.DebounceMS != 0 && config.Type != PinInterrupt {
+		return resource.NewConfigValidationError(path+".debounce_ms",
+			fmt.Errorf("debounce_ms is only used with pins of type %v", PinInterrupt))
+	}
+	if err := config.validateDebounceMode(path); err != nil {
 		return err
 	}
+	if err := config.Edge.Validate(); err != nil {
+		return resource.NewConfigValidationError(path+".edge", err)
+	}
+	if config.Edge != EdgeDefault && config.Type != PinInterrupt {
+		return resource.NewConfigValidationError(path+".edge",
+			fmt.Errorf("edge is only used with pins of type %v", PinInterrupt))
+	}
+	if err := config.CountMode.Validate(); err != nil {
+		return resource.NewConfigValidationError(path+".count_mode", err)
+	}
+	if config.CountMode != CountModeDefault && config.Type != PinInterrupt {
+		return resource.NewConfigValidationError(path+".count_mode",
+			fmt.Errorf("count_mode is only used with pins of type %v", PinInterrupt))
+	}
+	if config.WatchdogMS < 0 || config.WatchdogMS > MaxWatchdogMS {
+		return resource.NewConfigValidationError(path+".watchdog_ms",
+			fmt.Errorf("watchdog_ms must be between 0 and %d", MaxWatchdogMS))
+	}
+	if config.WatchdogMS != 0 && config.Type != PinInterrupt {
+		return resource.NewConfigValidationError(path+".watchdog_ms",
+			fmt.Errorf("watchdog_ms is only used with pins of type %v", PinInterrupt))
+	}
+	if config.MeasureWindowMS < 0 || config.MeasureWindowMS > MaxMeasureWindowMS {
+		return resource.NewConfigValidationError(path+".measure_window_ms",
+			fmt.Errorf("measure_window_ms must be between 0 and %d", MaxMeasureWindowMS))
+	}
+	if config.MeasureWindowMS != 0 && config.Type != PinInterrupt {
+		return resource.NewConfigValidationError(path+".measure_window_ms",
+			fmt.Errorf("measure_window_ms is only used with pins of type %v", PinInterrupt))
+	}
+	if config.TickHistorySize < 0 || config.TickHistorySize > MaxTickHistorySize {
+		return resource.NewConfigValidationError(path+".tick_history_size",
+			fmt.Errorf("tick_history_size must be between 0 and %d", MaxTickHistorySize))
+	}
+	if config.TickHistorySize != 0 && config.Type != PinInterrupt {
+		return resource.NewConfigValidationError(path+".tick_history_size",
+			fmt.Errorf("tick_history_size is only used with pins of type %v", PinInterrupt))
+	}
+	if config.Persist && config.Type != PinInterrupt {
+		return resource.NewConfigValidationError(path+".persist",
+			fmt.Errorf("persist is only used with pins of type %v", PinInterrupt))
+	}
+	if config.HighRate && config.Type != PinInterrupt {
+		return resource.NewConfigValidationError(path+".high_rate",
+			fmt.Errorf("high_rate is only used with pins of type %v", PinInterrupt))
+	}
+	if err := config.PWMMode.Validate(); err != nil {
+		return resource.NewConfigValidationError(path+".pwm_mode", err)
+	}
+	if config.PWMMode != PWMModeDefault && config.Type != PinGPIO {
+		return resource.NewConfigValidationError(path+".pwm_mode",
+			fmt.Errorf("pwm_mode is only used with pins of type %v", PinGPIO))
+	}
+	if config.PWMMode == PWMModeHardware {
+		if bcom, ok := BroadcomPinFromHardwareLabel(config.Pin); ok {
+			if _, ok := HardwarePWMChannel(bcom); !ok {
+				return resource.NewConfigValidationError(path+".pwm_mode",
+					fmt.Errorf("pin %v does not support hardware pwm, use pins 12, 32, 33 or 35", config.Pin))
+			}
+		}
+	}
+	if config.Type == PinAlt {
+		if config.Function == "" {
+			return resource.NewConfigValidationFieldRequiredError(path, "function")
+		}
+		if _, _, err := ParseAltFunction(config.Function); err != nil {
+			return resource.NewConfigValidationError(path+".function", err)
+		}
+	} else if config.Function != "" {
+		return resource.NewConfigValidationError(path+".function",
+			fmt.Errorf("function %q is only used with pins of type %v", config.Function, PinAlt))
+	}
+	if config.Type == PinClock {
+		if bcom, ok := BroadcomPinFromHardwareLabel(config.Pin); ok {
+			if _, ok := GeneralPurposeClock(bcom); !ok {
+				return resource.NewConfigValidationError(path+".pin",
+					fmt.Errorf("pin %v does not have a general purpose clock, use pins 7, 29, 31, 38 or 40", config.Pin))
+			}
+		}
+	} else if config.FrequencyHz != 0 {
+		return resource.NewConfigValidationError(path+".frequency_hz",
+			fmt.Errorf("frequency_hz is only used with pins of type %v", PinClock))
+	}
+	return nil
+}
+
+// ClockFrequencyRange returns the frequencies the general purpose clocks of a pi can output. The
+// range of an unknown pi covers every pi.
+func ClockFrequencyRange(hw PiHardware) (minHz, maxHz uint) {
+	switch hw.ModelName {
+	case "rpi4":
+		return MinBCM2711ClockFrequencyHz, MaxBCM2711ClockFrequencyHz
+	case "":
+		return MinClockFrequencyHz, MaxBCM2711ClockFrequencyHz
+	}
+	return MinClockFrequencyHz, MaxClockFrequencyHz
+}
+
+// ValidateClockFrequency checks that a frequency is within the range the general purpose clocks of a
+// pi can output.
+func ValidateClockFrequency(freqHz uint, hw PiHardware) error {
+	minHz, maxHz := ClockFrequencyRange(hw)
+	if freqHz < minHz || freqHz > maxHz {
+		return fmt.Errorf("clock frequency %d Hz is outside of the supported range %d-%d Hz", freqHz, minHz, maxHz)
+	}
 	return nil
 }
 
@@ -97,61 +381,170 @@ func CreateDigitalInterrupt(cfg PinConfig) (ReconfigurableDigitalInterrupt, erro
 // A BasicDigitalInterrupt records how many ticks/interrupts happen and can
 // report when they happen to interested callbacks.
 type BasicDigitalInterrupt struct {
-	count int64
+	count    int64
+	timeouts int64
 
-	callbacks []chan board.Tick
+	subscribers []*TickSubscriber
+	signal      signalHistory
+	ticks       tickHistory
 
 	mu  sync.RWMutex
 	cfg PinConfig
 }
 
-// Value returns the amount of ticks that have occurred.
+// Value returns the amount of changes of the pin that were counted, following the count mode of the
+// interrupt, since it was created or its counter was reset. If extra names a "measurement", such as
+// "frequency_mhz", that measurement of the signal on the pin is returned instead.
 func (i *BasicDigitalInterrupt) Value(ctx context.Context, extra map[string]interface{}) (int64, error) {
+	if raw, ok := extra["measurement"]; ok {
+		name, ok := raw.(string)
+		if !ok {
+			return 0, fmt.Errorf("expected \"measurement\" to be a string, got %T", raw)
+		}
+		return i.Measure().Value(name)
+	}
 	i.mu.RLock()
 	defer i.mu.RUnlock()
 	count := atomic.LoadInt64(&i.count)
 	return count, nil
 }
 
-// Tick records an interrupt and notifies any interested callbacks. See comment on
-// the DigitalInterrupt interface for caveats.
+// Measure measures the signal on the pin of the interrupt over the window that ends now.
+func (i *BasicDigitalInterrupt) Measure() Measurement {
+	return i.signal.measure(uint64(time.Now().UnixNano()), i.measureWindow())
+}
+
+// measureWindow returns the window the signal of the interrupt is measured over.
+func (i *BasicDigitalInterrupt) measureWindow() time.Duration {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.cfg.measureWindow()
+}
+
+// ResetCounter sets the value of the interrupt to the preset, and returns the value it had.
+func (i *BasicDigitalInterrupt) ResetCounter(preset int64) int64 {
+	return atomic.SwapInt64(&i.count, preset)
+}
+
+// Tick records a change of the pin of an interrupt. The change is counted if it matches the count
+// mode, and queued for every subscriber if it is on the edge of the interrupt. Only subscribers with
+// the block overflow policy can make it wait, until they have room or the context is cancelled.
 func Tick(ctx context.Context, i *BasicDigitalInterrupt, high bool, nanoseconds uint64) error {
-	if high {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	i.signal.add(levelChange{high: high, nanoseconds: nanoseconds}, i.cfg.measureWindow())
+	if i.cfg.CountMode.counts(high, i.cfg.Edge) {
 		atomic.AddInt64(&i.count, 1)
 	}
+	if !i.cfg.Edge.reports(high) {
+		return nil
+	}
+	return i.publish(ctx, board.Tick{Name: i.cfg.Name, High: high, TimestampNanosec: nanoseconds})
+}
 
-	i.mu.RLock()
-	defer i.mu.RUnlock()
-	for _, c := range i.callbacks {
-		select {
-		case <-ctx.Done():
-			return errors.New("context cancelled")
-		case c <- board.Tick{Name: i.cfg.Name, High: high, TimestampNanosec: nanoseconds}:
+// publish records a tick in the history of the interrupt and queues it for every subscriber.
+// The interrupt mutex should be locked before calling this.
+func (i *BasicDigitalInterrupt) publish(ctx context.Context, tick board.Tick) error {
+	i.ticks.add(tick, i.cfg.tickHistorySize())
+	for _, s := range i.subscribers {
+		if err := s.push(ctx, tick); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-// AddCallback adds a listener for interrupts.
-func AddCallback(i *BasicDigitalInterrupt, c chan board.Tick) {
+// WatchdogTimeouts returns the amount of times the watchdog of the interrupt fired.
+func (i *BasicDigitalInterrupt) WatchdogTimeouts() int64 {
+	return atomic.LoadInt64(&i.timeouts)
+}
+
+// WatchdogTimeout records that the pin of an interrupt did not change for its watchdog_ms, and
+// queues a tick named after the interrupt and WatchdogTickSuffix for every subscriber. The tick has
+// the level the pin stayed at, and doesn't change the count of the interrupt.
+func WatchdogTimeout(ctx context.Context, i *BasicDigitalInterrupt, high bool, nanoseconds uint64) error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	atomic.AddInt64(&i.timeouts, 1)
+	return i.publish(ctx, board.Tick{Name: i.cfg.Name + WatchdogTickSuffix, High: high, TimestampNanosec: nanoseconds})
+}
+
+// WatchdogTimeoutsResult returns the result of the watchdog_timeouts DoCommand for the interrupts: how
+// often the watchdog of each of them fired, by name.
+func WatchdogTimeoutsResult(interrupts []*BasicDigitalInterrupt) map[string]interface{} {
+	timeouts := map[string]interface{}{}
+	for _, i := range interrupts {
+		timeouts[i.Name()] = i.WatchdogTimeouts()
+	}
+	return map[string]interface{}{"timeouts": timeouts}
+}
+
+// MeasurementsResult returns the result of the measure DoCommand for the interrupts: the measurement
+// of each of them, by name.
+func MeasurementsResult(interrupts []*BasicDigitalInterrupt) map[string]interface{} {
+	measurements := map[string]interface{}{}
+	for _, i := range interrupts {
+		measurements[i.Name()] = i.Measure().result(i.measureWindow())
+	}
+	return map[string]interface{}{"measurements": measurements}
+}
+
+// ResetCounterResult returns the result of the reset_counter DoCommand for the interrupts: the values
+// they had before they were reset, by name.
+func ResetCounterResult(interrupts []*BasicDigitalInterrupt, preset int64) map[string]interface{} {
+	previous := map[string]interface{}{}
+	for _, i := range interrupts {
+		previous[i.Name()] = i.ResetCounter(preset)
+	}
+	return map[string]interface{}{"previous": previous}
+}
+
+// AddSubscriber adds a subscriber to the ticks of an interrupt. A subscriber can be added to several
+// interrupts.
+func AddSubscriber(i *BasicDigitalInterrupt, s *TickSubscriber) {
 	i.mu.Lock()
 	defer i.mu.Unlock()
-	i.callbacks = append(i.callbacks, c)
+	i.subscribers = append(i.subscribers, s)
 }
 
-// RemoveCallback removes a listener for interrupts.
-func RemoveCallback(i *BasicDigitalInterrupt, c chan board.Tick) {
+// RemoveSubscriber removes a subscriber from the ticks of an interrupt. It does not close the
+// subscriber, since it may still be added to other interrupts.
+func RemoveSubscriber(i *BasicDigitalInterrupt, s *TickSubscriber) {
 	i.mu.Lock()
 	defer i.mu.Unlock()
-	for id := range i.callbacks {
-		if i.callbacks[id] == c {
+	i.removeSubscriber(func(other *TickSubscriber) bool { return other == s })
+}
+
+// removeSubscriber removes the first subscriber that matches, and returns it.
+// The interrupt mutex should be locked before calling this.
+func (i *BasicDigitalInterrupt) removeSubscriber(matches func(*TickSubscriber) bool) *TickSubscriber {
+	for id, s := range i.subscribers {
+		if matches(s) {
 			// To remove this item, we replace it with the last item in the list, then truncate the
 			// list by 1.
-			i.callbacks[id] = i.callbacks[len(i.callbacks)-1]
-			i.callbacks = i.callbacks[:len(i.callbacks)-1]
-			break
+			i.subscribers[id] = i.subscribers[len(i.subscribers)-1]
+			i.subscribers = i.subscribers[:len(i.subscribers)-1]
+			return s
 		}
 	}
+	return nil
+}
+
+// AddCallback adds a listener for interrupts, with a queue of the default size and overflow policy.
+func AddCallback(i *BasicDigitalInterrupt, c chan board.Tick) {
+	s := NewTickSubscriber(c, TickQueueConfig{}, []string{i.Name()})
+	s.standalone = true
+	AddSubscriber(i, s)
+}
+
+// RemoveCallback removes a listener for interrupts.
+func RemoveCallback(i *BasicDigitalInterrupt, c chan board.Tick) {
+	i.mu.Lock()
+	s := i.removeSubscriber(func(s *TickSubscriber) bool { return s.standalone && s.ch == c })
+	i.mu.Unlock()
+	if s != nil {
+		s.Close()
+	}
 }
 
 // Name returns the name of the interrupt.
@@ -154,3 +154,175 @@ func TestRemoveCallbackDigitalInterrupt(t *testing.T) {
 	test.That(t, err, test.ShouldBeNil)
 	test.That(t, intVal, test.ShouldEqual, int64(3))
 }
+
+func TestParseAltFunction(t *testing.T) {
+	num, isRP1, err := ParseAltFunction("alt0")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, num, test.ShouldEqual, 0)
+	test.That(t, isRP1, test.ShouldBeFalse)
+
+	num, isRP1, err = ParseAltFunction("a8")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, num, test.ShouldEqual, 8)
+	test.That(t, isRP1, test.ShouldBeTrue)
+
+	for _, bad := range []string{"", "alt6", "a9", "alt", "gpclk0", "alt10"} {
+		_, _, err = ParseAltFunction(bad)
+		test.That(t, err, test.ShouldNotBeNil)
+	}
+}
+
+func TestValidateAltPin(t *testing.T) {
+	config := PinConfig{Name: "gpclk", Pin: "7", Type: PinAlt, Function: "alt0"}
+	test.That(t, config.Validate("path"), test.ShouldBeNil)
+
+	config.Function = ""
+	test.That(t, config.Validate("path"), test.ShouldNotBeNil)
+
+	config.Function = "alt7"
+	test.That(t, config.Validate("path"), test.ShouldNotBeNil)
+
+	config = PinConfig{Name: "led", Pin: "7", Type: PinGPIO, Function: "alt0"}
+	test.That(t, config.Validate("path"), test.ShouldNotBeNil)
+}
+
+func TestFallingEdgeDigitalInterrupt(t *testing.T) {
+	i, err := CreateDigitalInterrupt(PinConfig{Name: "i1", Type: PinInterrupt, Edge: EdgeFalling})
+	test.That(t, err, test.ShouldBeNil)
+	basicInterrupt := i.(*BasicDigitalInterrupt)
+
+	test.That(t, Tick(context.Background(), basicInterrupt, false, nowNanosecondsTest()), test.ShouldBeNil)
+	test.That(t, Tick(context.Background(), basicInterrupt, false, nowNanosecondsTest()), test.ShouldBeNil)
+	intVal, err := i.Value(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, intVal, test.ShouldEqual, int64(2))
+}
+
+func TestValidateEdge(t *testing.T) {
+	for _, edge := range []Edge{EdgeDefault, EdgeRising, EdgeFalling, EdgeBoth} {
+		config := PinConfig{Name: "button", Pin: "13", Type: PinInterrupt, Edge: edge}
+		test.That(t, config.Validate("path"), test.ShouldBeNil)
+	}
+
+	config := PinConfig{Name: "button", Pin: "13", Type: PinInterrupt, Edge: "up"}
+	err := config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.edge")
+
+	config = PinConfig{Name: "led", Pin: "13", Type: PinGPIO, Edge: EdgeRising}
+	err = config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.edge")
+}
+
+func TestValidateHighRate(t *testing.T) {
+	config := PinConfig{Name: "encoder", Pin: "13", Type: PinInterrupt, HighRate: true}
+	test.That(t, config.Validate("path"), test.ShouldBeNil)
+
+	config = PinConfig{Name: "led", Pin: "13", Type: PinGPIO, HighRate: true}
+	err := config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.high_rate")
+}
+
+func TestWatchdogTimeout(t *testing.T) {
+	config := PinConfig{Name: "flow", Pin: "13", Type: PinInterrupt, WatchdogMS: 500}
+	test.That(t, config.Validate("path"), test.ShouldBeNil)
+	i, err := CreateDigitalInterrupt(config)
+	test.That(t, err, test.ShouldBeNil)
+	di := i.(*BasicDigitalInterrupt)
+
+	ticks := make(chan board.Tick, 1)
+	AddCallback(di, ticks)
+	test.That(t, WatchdogTimeout(context.Background(), di, true, 12), test.ShouldBeNil)
+	test.That(t, <-ticks, test.ShouldResemble, board.Tick{Name: "flow" + WatchdogTickSuffix, High: true, TimestampNanosec: 12})
+	test.That(t, di.WatchdogTimeouts(), test.ShouldEqual, 1)
+
+	// a timeout is not an edge
+	count, err := di.Value(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 0)
+
+	test.That(t, WatchdogTimeoutsResult([]*BasicDigitalInterrupt{di}), test.ShouldResemble,
+		map[string]interface{}{"timeouts": map[string]interface{}{"flow": int64(1)}})
+
+	config.WatchdogMS = MaxWatchdogMS + 1
+	err = config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.watchdog_ms")
+
+	config = PinConfig{Name: "led", Pin: "13", Type: PinGPIO, WatchdogMS: 500}
+	err = config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.watchdog_ms")
+}
+
+func TestCountMode(t *testing.T) {
+	ctx := context.Background()
+	changes := []bool{true, false, true, false, true}
+	for _, tc := range []struct {
+		edge      Edge
+		countMode CountMode
+		count     int64
+		ticks     int
+	}{
+		{EdgeDefault, CountModeDefault, 3, 5},
+		{EdgeFalling, CountModeDefault, 2, 2},
+		{EdgeRising, CountModeBoth, 5, 3},
+		{EdgeBoth, CountModeFalling, 2, 5},
+		{EdgeFalling, CountModeRising, 3, 2},
+	} {
+		config := PinConfig{Name: "flow", Pin: "13", Type: PinInterrupt, Edge: tc.edge, CountMode: tc.countMode}
+		test.That(t, config.Validate("path"), test.ShouldBeNil)
+		i, err := CreateDigitalInterrupt(config)
+		test.That(t, err, test.ShouldBeNil)
+		di := i.(*BasicDigitalInterrupt)
+		ticks := make(chan board.Tick, len(changes))
+		AddCallback(di, ticks)
+
+		for n, high := range changes {
+			test.That(t, Tick(ctx, di, high, uint64(n)), test.ShouldBeNil)
+		}
+		count, err := di.Value(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, count, test.ShouldEqual, tc.count)
+		for range tc.ticks {
+			tick := <-ticks
+			test.That(t, tc.edge.reports(tick.High), test.ShouldBeTrue)
+		}
+		RemoveCallback(di, ticks)
+		test.That(t, len(ticks), test.ShouldEqual, 0)
+	}
+
+	config := PinConfig{Name: "flow", Pin: "13", Type: PinInterrupt, CountMode: "down"}
+	err := config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.count_mode")
+
+	config = PinConfig{Name: "led", Pin: "13", Type: PinGPIO, CountMode: CountModeBoth}
+	err = config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.count_mode")
+}
+
+func TestResetCounter(t *testing.T) {
+	ctx := context.Background()
+	i, err := CreateDigitalInterrupt(PinConfig{Name: "flow", Type: PinInterrupt})
+	test.That(t, err, test.ShouldBeNil)
+	di := i.(*BasicDigitalInterrupt)
+	for range 3 {
+		test.That(t, Tick(ctx, di, true, nowNanosecondsTest()), test.ShouldBeNil)
+	}
+
+	test.That(t, ResetCounterResult([]*BasicDigitalInterrupt{di}, 100), test.ShouldResemble,
+		map[string]interface{}{"previous": map[string]interface{}{"flow": int64(3)}})
+	test.That(t, Tick(ctx, di, true, nowNanosecondsTest()), test.ShouldBeNil)
+	count, err := di.Value(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 101)
+
+	test.That(t, di.ResetCounter(0), test.ShouldEqual, 101)
+	count, err = di.Value(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 0)
+}
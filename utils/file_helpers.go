@@ -8,15 +8,47 @@ import (
 	"regexp"
 	"strings"
 
+	"go.uber.org/multierr"
 	"go.viam.com/rdk/logging"
 )
 
+// WriteFileAtomic replaces the content of a file, so a crash or power loss leaves either the old or
+// the new content behind. The data is written to a temp file next to the file and synced to disk, and
+// the temp file is then renamed over the file.
+func WriteFileAtomic(filePath string, data []byte, mode os.FileMode) error {
+	filePath = filepath.Clean(filePath)
+	tempFile := filePath + ".tmp"
+	f, err := os.OpenFile(tempFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("failed to write temp file %s: %w", tempFile, err)
+	}
+	_, err = f.Write(data)
+	if err = multierr.Combine(err, f.Sync(), f.Close()); err != nil {
+		//nolint:errcheck  // best attempt to clean up the temp file
+		_ = os.Remove(tempFile)
+		return fmt.Errorf("failed to write temp file %s: %w", tempFile, err)
+	}
+	if err := os.Rename(tempFile, filePath); err != nil {
+		//nolint:errcheck  // best attempt to clean up the temp file
+		_ = os.Remove(tempFile)
+		return fmt.Errorf("failed to replace %s: %w", filePath, err)
+	}
+	// the rename is only durable once the directory is synced too
+	if dir, err := os.Open(filepath.Dir(filePath)); err == nil {
+		//nolint:errcheck  // the file was replaced, at worst the rename is lost in a power loss
+		dir.Sync()
+		//nolint:errcheck
+		dir.Close()
+	}
+	return nil
+}
+
 // UpdateConfigFile atomically updates a configuration file parameter using regexp.
 // - Replaces existing uncommented param lines with the desired value
 // - Leaves commented lines intact
 // - Appends only if the (uncommented) line exists
 // - Preserves file permissions (uses os.Stat + os.WriteFile with original mode)
-// - Atomic via temp file + rename.
+// - Atomic via WriteFileAtomic.
 func UpdateConfigFile(filePath, paramPrefix, desiredValue string, logger logging.Logger) (bool, error) {
 	filePath = filepath.Clean(filePath)
 	fileInfo, err := os.Stat(filePath)
@@ -72,15 +104,8 @@ func UpdateConfigFile(filePath, paramPrefix, desiredValue string, logger logging
 	}
 
 	newContent := strings.Join(lines, "\n")
-	tempFile := filePath + ".tmp"
-
-	if err := os.WriteFile(tempFile, []byte(newContent), fileInfo.Mode()); err != nil {
-		return false, fmt.Errorf("failed to write temp config file %s: %w", tempFile, err)
-	}
-	if err := os.Rename(tempFile, filePath); err != nil {
-		//nolint:errcheck  // best attempt to clean up the temp file
-		_ = os.Remove(tempFile)
-		return false, fmt.Errorf("failed to replace config file %s: %w", filePath, err)
+	if err := WriteFileAtomic(filePath, []byte(newContent), fileInfo.Mode()); err != nil {
+		return false, err
 	}
 
 	logger.Debugf("Updated %s in %s", paramPrefix, filePath)
@@ -131,17 +156,8 @@ func UpdateModuleFile(filePath, moduleName string, enable bool, logger logging.L
 
 	if configChanged {
 		newContent := strings.Join(lines, "\n")
-
-		tempFile := filePath + ".tmp"
-		if err := os.WriteFile(tempFile, []byte(newContent), fileInfo.Mode()); err != nil {
-			return false, fmt.Errorf("failed to write temp modules file %s: %w", tempFile, err)
-		}
-
-		if err := os.Rename(tempFile, filePath); err != nil {
-			if removeErr := os.Remove(tempFile); removeErr != nil {
-				logger.Warnf("Failed to clean up temp file %s: %v", tempFile, removeErr)
-			}
-			return false, fmt.Errorf("failed to replace modules file %s: %w", filePath, err)
+		if err := WriteFileAtomic(filePath, []byte(newContent), fileInfo.Mode()); err != nil {
+			return false, err
 		}
 
 		action := "Added"
@@ -199,15 +215,8 @@ func RemoveLineMatching(filePath string, lineRegex *regexp.Regexp, logger loggin
 	}
 
 	newContent := strings.Join(filtered, "\n")
-	tempFile := filePath + ".tmp"
-	if err := os.WriteFile(tempFile, []byte(newContent), fileInfo.Mode()); err != nil {
-		return false, fmt.Errorf("failed to write temp config file %s: %w", tempFile, err)
-	}
-
-	if err := os.Rename(tempFile, filePath); err != nil {
-		//nolint:errcheck  // best attempt to clean up the temp file
-		_ = os.Remove(tempFile)
-		return false, fmt.Errorf("failed to replace config file %s: %w", filePath, err)
+	if err := WriteFileAtomic(filePath, []byte(newContent), fileInfo.Mode()); err != nil {
+		return false, err
 	}
 
 	logger.Debugf("Removed uncommented line matching %q in %s", lineRegex.String(), filePath)
@@ -470,3 +470,24 @@ func TestDetectConfigParam(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	test.That(t, WriteFileAtomic(path, []byte("first"), 0o600), test.ShouldBeNil)
+	test.That(t, WriteFileAtomic(path, []byte("second"), 0o600), test.ShouldBeNil)
+	content, err := os.ReadFile(path)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(content), test.ShouldEqual, "second")
+	info, err := os.Stat(path)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, info.Mode().Perm(), test.ShouldEqual, os.FileMode(0o600))
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(entries), test.ShouldEqual, 1)
+
+	test.That(t, WriteFileAtomic(filepath.Join(dir, "missing", "state.json"), []byte("x"), 0o600), test.ShouldNotBeNil)
+}
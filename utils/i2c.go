@@ -0,0 +1,229 @@
+// Package rpiutils contains the configuration and helpers of the i2c buses provided by the boards.
+package rpiutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.viam.com/rdk/components/board/genericlinux/buses"
+	"go.viam.com/rdk/resource"
+)
+
+const (
+	// MinBitBangI2CBaud is the slowest baud rate pigpio can bit-bang an i2c bus at.
+	MinBitBangI2CBaud = 50
+	// MaxBitBangI2CBaud is the fastest baud rate pigpio can bit-bang an i2c bus at.
+	MaxBitBangI2CBaud = 500000
+	// DefaultBitBangI2CBaud is used if no baud rate is configured for a bit-banged bus.
+	DefaultBitBangI2CBaud = 100000
+	// maxI2CZipLength is the most bytes a single read or write of an i2c zip command can transfer.
+	maxI2CZipLength = 65535
+)
+
+// I2CConfig describes an i2c bus provided by the board: either a hardware bus, or a bus bit-banged
+// on arbitrary GPIOs.
+type I2CConfig struct {
+	Name     string `json:"name"`
+	Bus      *int   `json:"bus,omitempty"`       // hardware bus number, e.g. 1 for /dev/i2c-1
+	BBSDA    string `json:"bb_sda,omitempty"`    // data pin of a bit-banged bus
+	BBSCL    string `json:"bb_scl,omitempty"`    // clock pin of a bit-banged bus
+	BaudRate int    `json:"baud_rate,omitempty"` // only used with bit-banged buses
+}
+
+// Validate ensures all parts of the config are valid.
+func (config *I2CConfig) Validate(path string) error {
+	if config.Name == "" {
+		return resource.NewConfigValidationFieldRequiredError(path, "name")
+	}
+	if config.Bus != nil {
+		if config.BBSDA != "" || config.BBSCL != "" {
+			return resource.NewConfigValidationError(path, errors.New("use either bus or bb_sda and bb_scl, not both"))
+		}
+		if *config.Bus < 0 {
+			return resource.NewConfigValidationError(path+".bus", fmt.Errorf("invalid bus %d", *config.Bus))
+		}
+		if config.BaudRate != 0 {
+			return resource.NewConfigValidationError(path+".baud_rate",
+				errors.New("baud_rate is only used with bit-banged buses, set the speed of a hardware bus in the firmware instead"))
+		}
+		return nil
+	}
+
+	if config.BBSDA == "" {
+		return resource.NewConfigValidationFieldRequiredError(path, "bb_sda")
+	}
+	if config.BBSCL == "" {
+		return resource.NewConfigValidationFieldRequiredError(path, "bb_scl")
+	}
+	sda, ok := BroadcomPinFromHardwareLabel(config.BBSDA)
+	if !ok {
+		return resource.NewConfigValidationError(path+".bb_sda", fmt.Errorf("unknown pin %q", config.BBSDA))
+	}
+	scl, ok := BroadcomPinFromHardwareLabel(config.BBSCL)
+	if !ok {
+		return resource.NewConfigValidationError(path+".bb_scl", fmt.Errorf("unknown pin %q", config.BBSCL))
+	}
+	if sda == scl {
+		return resource.NewConfigValidationError(path+".bb_scl", errors.New("bb_sda and bb_scl must be different pins"))
+	}
+	if config.BaudRate != 0 && (config.BaudRate < MinBitBangI2CBaud || config.BaudRate > MaxBitBangI2CBaud) {
+		return resource.NewConfigValidationError(path+".baud_rate",
+			fmt.Errorf("baud_rate must be between %d and %d, got %d", MinBitBangI2CBaud, MaxBitBangI2CBaud, config.BaudRate))
+	}
+	return nil
+}
+
+// BaudRateOrDefault returns the configured baud rate of a bit-banged bus, or the default if none was
+// configured.
+func (config *I2CConfig) BaudRateOrDefault() int {
+	if config.BaudRate == 0 {
+		return DefaultBitBangI2CBaud
+	}
+	return config.BaudRate
+}
+
+// Equals returns whether two configs describe the same bus.
+func (config I2CConfig) Equals(other I2CConfig) bool {
+	if (config.Bus == nil) != (other.Bus == nil) || (config.Bus != nil && *config.Bus != *other.Bus) {
+		return false
+	}
+	return config.Name == other.Name && config.BBSDA == other.BBSDA && config.BBSCL == other.BBSCL &&
+		config.BaudRate == other.BaudRate
+}
+
+// An I2CBoard is a board that provides i2c buses. Other components can look up the board in their
+// dependencies and type assert it to this interface to borrow a bus instead of opening the device
+// themselves. Opening a handle on a bus locks it until the handle is closed, which serializes the
+// transactions of everything sharing the bus.
+type I2CBoard interface {
+	I2CByName(name string) (buses.I2C, error)
+}
+
+// RunI2CTransaction runs the i2c_transaction DoCommand on a bus. The request has an "address", an
+// optional "write" list of bytes, and an optional "read" count of bytes to read afterwards. If the
+// write is a single register byte, the read follows it with a repeated start.
+func RunI2CTransaction(
+	ctx context.Context,
+	bus buses.I2C,
+	cmd map[string]interface{},
+) (resp map[string]interface{}, err error) {
+	address, err := UintFromCommand(cmd, "address")
+	if err != nil {
+		return nil, err
+	}
+	if address > 0x7f {
+		return nil, fmt.Errorf("invalid i2c address %#x", address)
+	}
+	var write []byte
+	if _, ok := cmd["write"]; ok {
+		if write, err = BytesFromCommand(cmd, "write"); err != nil {
+			return nil, err
+		}
+	}
+	var readLen uint
+	if _, ok := cmd["read"]; ok {
+		if readLen, err = UintFromCommand(cmd, "read"); err != nil {
+			return nil, err
+		}
+	}
+	if len(write) == 0 && readLen == 0 {
+		return nil, errors.New("expected \"write\" or \"read\"")
+	}
+	if readLen > maxI2CZipLength {
+		return nil, fmt.Errorf("cannot read more than %d bytes at once", maxI2CZipLength)
+	}
+
+	handle, err := bus.OpenHandle(byte(address))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := handle.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	var read []byte
+	switch {
+	case len(write) == 1 && readLen > 0 && readLen <= 255:
+		read, err = handle.ReadBlockData(ctx, write[0], uint8(readLen))
+	default:
+		if len(write) > 0 {
+			if err = handle.Write(ctx, write); err != nil {
+				return nil, err
+			}
+		}
+		if readLen > 0 {
+			read, err = handle.Read(ctx, int(readLen))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]interface{}, 0, len(read))
+	for _, b := range read {
+		data = append(data, int(b))
+	}
+	return map[string]interface{}{"data": data}, nil
+}
+
+// BytesFromCommand decodes a DoCommand argument that is a list of byte values.
+func BytesFromCommand(cmd map[string]interface{}, key string) ([]byte, error) {
+	raw, ok := cmd[key].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected %q to be a list of bytes", key)
+	}
+	data := make([]byte, 0, len(raw))
+	for idx, rawByte := range raw {
+		value, err := UintFromCommand(map[string]interface{}{key: rawByte}, key)
+		if err != nil || value > 255 {
+			return nil, fmt.Errorf("expected %q entry %d to be a byte, got %v", key, idx, rawByte)
+		}
+		data = append(data, byte(value))
+	}
+	return data, nil
+}
+
+// EncodeI2CZip encodes a write followed by a read into pigpio's i2c zip command format. Bit-banged
+// buses address the device and generate the start and stop conditions from the commands, while
+// hardware handles are already bound to an address and use the combined flag instead, so the read
+// follows the write with a repeated start in both cases.
+func EncodeI2CZip(addr byte, write []byte, readLen int, bitBang bool) ([]byte, error) {
+	if len(write) > maxI2CZipLength || readLen > maxI2CZipLength || readLen < 0 {
+		return nil, fmt.Errorf("i2c transfers are limited to %d bytes", maxI2CZipLength)
+	}
+	const (
+		zipEnd     = 0
+		zipEscape  = 1
+		zipStart   = 2 // start condition, or combined flag on for hardware handles
+		zipStop    = 3 // stop condition, or combined flag off for hardware handles
+		zipAddress = 4
+		zipRead    = 6
+		zipWrite   = 7
+	)
+	segment := func(command byte, n int) []byte {
+		if n > 255 {
+			return []byte{zipEscape, command, byte(n), byte(n >> 8)}
+		}
+		return []byte{command, byte(n)}
+	}
+
+	buf := []byte{}
+	if bitBang {
+		buf = append(buf, zipAddress, addr)
+	}
+	buf = append(buf, zipStart)
+	if len(write) > 0 {
+		buf = append(buf, segment(zipWrite, len(write))...)
+		buf = append(buf, write...)
+	}
+	if readLen > 0 {
+		if bitBang && len(write) > 0 {
+			buf = append(buf, zipStart)
+		}
+		buf = append(buf, segment(zipRead, readLen)...)
+	}
+	return append(buf, zipStop, zipEnd), nil
+}
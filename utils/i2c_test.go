@@ -0,0 +1,160 @@
+package rpiutils
+
+import (
+	"context"
+	"testing"
+
+	"go.viam.com/rdk/components/board/genericlinux/buses"
+	"go.viam.com/test"
+)
+
+func TestConfigValidateI2Cs(t *testing.T) {
+	validate := func(conf Config) error {
+		_, _, err := conf.Validate("attributes")
+		return err
+	}
+	bus := func(n int) *int { return &n }
+
+	t.Run("valid buses", func(t *testing.T) {
+		err := validate(Config{I2Cs: []I2CConfig{
+			{Name: "main", Bus: bus(1)},
+			{Name: "sensors", BBSDA: "16", BBSCL: "18", BaudRate: 50000},
+			{Name: "display", BBSDA: "22", BBSCL: "29"},
+		}})
+		test.That(t, err, test.ShouldBeNil)
+	})
+
+	t.Run("invalid buses", func(t *testing.T) {
+		err := validate(Config{I2Cs: []I2CConfig{{Name: "main"}}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "bb_sda")
+
+		err = validate(Config{I2Cs: []I2CConfig{{Name: "main", Bus: bus(1), BBSDA: "16", BBSCL: "18"}}})
+		test.That(t, err, test.ShouldNotBeNil)
+
+		err = validate(Config{I2Cs: []I2CConfig{{Name: "main", Bus: bus(1), BaudRate: 400000}}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.i2cs.0.baud_rate")
+
+		err = validate(Config{I2Cs: []I2CConfig{{Name: "sensors", BBSDA: "16", BBSCL: "16"}}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.i2cs.0.bb_scl")
+
+		err = validate(Config{I2Cs: []I2CConfig{{Name: "sensors", BBSDA: "16", BBSCL: "18", BaudRate: 1000000}}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.i2cs.0.baud_rate")
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		err := validate(Config{I2Cs: []I2CConfig{{Name: "main", Bus: bus(1)}, {Name: "other", Bus: bus(1)}}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.i2cs.1.bus")
+
+		err = validate(Config{I2Cs: []I2CConfig{{Name: "main", Bus: bus(1)}, {Name: "main", Bus: bus(3)}}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.i2cs.1.name")
+
+		err = validate(Config{
+			SoftUARTs: []SoftUARTConfig{{Name: "gps", RXPin: "16", BaudRate: 9600}},
+			I2Cs:      []I2CConfig{{Name: "sensors", BBSDA: "16", BBSCL: "18"}},
+		})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.i2cs.0.bb_sda")
+	})
+}
+
+func TestEncodeI2CZip(t *testing.T) {
+	buf, err := EncodeI2CZip(0x48, []byte{0x01}, 2, true)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, buf, test.ShouldResemble, []byte{4, 0x48, 2, 7, 1, 0x01, 2, 6, 2, 3, 0})
+
+	buf, err = EncodeI2CZip(0x48, []byte{0x01}, 2, false)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, buf, test.ShouldResemble, []byte{2, 7, 1, 0x01, 6, 2, 3, 0})
+
+	buf, err = EncodeI2CZip(0x48, nil, 300, true)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, buf, test.ShouldResemble, []byte{4, 0x48, 2, 1, 6, 0x2c, 0x01, 3, 0})
+
+	_, err = EncodeI2CZip(0x48, nil, 70000, true)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+// fakeI2C records the transactions run on it and answers reads with incrementing bytes.
+type fakeI2C struct {
+	addr   byte
+	writes [][]byte
+	reads  []int
+	closed bool
+}
+
+func (f *fakeI2C) OpenHandle(addr byte) (buses.I2CHandle, error) {
+	f.addr = addr
+	return f, nil
+}
+
+func (f *fakeI2C) Write(ctx context.Context, tx []byte) error {
+	f.writes = append(f.writes, tx)
+	return nil
+}
+
+func (f *fakeI2C) Read(ctx context.Context, count int) ([]byte, error) {
+	f.reads = append(f.reads, count)
+	buf := make([]byte, count)
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+	return buf, nil
+}
+
+func (f *fakeI2C) ReadByteData(ctx context.Context, register byte) (byte, error) {
+	data, err := f.ReadBlockData(ctx, register, 1)
+	return data[0], err
+}
+
+func (f *fakeI2C) WriteByteData(ctx context.Context, register, data byte) error {
+	return f.Write(ctx, []byte{register, data})
+}
+
+func (f *fakeI2C) ReadBlockData(ctx context.Context, register byte, numBytes uint8) ([]byte, error) {
+	f.writes = append(f.writes, []byte{register})
+	return f.Read(ctx, int(numBytes))
+}
+
+func (f *fakeI2C) WriteBlockData(ctx context.Context, register byte, data []byte) error {
+	return f.Write(ctx, append([]byte{register}, data...))
+}
+
+func (f *fakeI2C) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestRunI2CTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	bus := &fakeI2C{}
+	resp, err := RunI2CTransaction(ctx, bus, map[string]interface{}{
+		"address": 72.0, "write": []interface{}{1.0}, "read": 2.0,
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["data"], test.ShouldResemble, []interface{}{0, 1})
+	test.That(t, bus.addr, test.ShouldEqual, byte(72))
+	test.That(t, bus.writes, test.ShouldResemble, [][]byte{{1}})
+	test.That(t, bus.closed, test.ShouldBeTrue)
+
+	bus = &fakeI2C{}
+	resp, err = RunI2CTransaction(ctx, bus, map[string]interface{}{
+		"address": 60.0, "write": []interface{}{0.0, 175.0},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["data"], test.ShouldResemble, []interface{}{})
+	test.That(t, bus.writes, test.ShouldResemble, [][]byte{{0, 175}})
+
+	_, err = RunI2CTransaction(ctx, &fakeI2C{}, map[string]interface{}{"address": 200.0, "read": 1.0})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = RunI2CTransaction(ctx, &fakeI2C{}, map[string]interface{}{"address": 60.0})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = RunI2CTransaction(ctx, &fakeI2C{}, map[string]interface{}{"address": 60.0, "write": []interface{}{256.0}})
+	test.That(t, err, test.ShouldNotBeNil)
+}
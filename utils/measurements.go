@@ -0,0 +1,149 @@
+package rpiutils
+
+/*
+	measurements.go: Measures the signal on an interrupt pin. Every stable change of the pin is
+	kept for the measure window of the interrupt, and the frequency, period, high time and duty
+	cycle of the signal are computed from their timestamps when they are asked for, which saves
+	clients from streaming every tick to compute them. Periods are measured from rising edge to
+	rising edge.
+*/
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMeasureWindowMS is the window an interrupt is measured over if none was configured.
+	DefaultMeasureWindowMS = 1000
+	// MaxMeasureWindowMS is the longest window an interrupt can be measured over.
+	MaxMeasureWindowMS = 60000
+	// maxMeasuredChanges bounds the changes kept for a measurement. A faster signal is measured over
+	// the most recent changes, which span less than the window.
+	maxMeasuredChanges = 1 << 16
+)
+
+// measureWindow returns the window the signal of an interrupt is measured over.
+func (config *PinConfig) measureWindow() time.Duration {
+	if config.MeasureWindowMS == 0 {
+		return DefaultMeasureWindowMS * time.Millisecond
+	}
+	return time.Duration(config.MeasureWindowMS) * time.Millisecond
+}
+
+// A Measurement describes the signal on an interrupt pin over its measure window.
+type Measurement struct {
+	// FrequencyHz is the number of periods per second, 0 if the window did not hold a full period.
+	FrequencyHz float64
+	// PeriodNs is the time between the last two rising edges.
+	PeriodNs uint64
+	// HighTimeNs is the time the pin was high during its last pulse.
+	HighTimeNs uint64
+	// DutyCycle is the part of the periods in the window the pin was high, between 0 and 1.
+	DutyCycle float64
+	// Periods is the number of full periods in the window.
+	Periods int
+}
+
+// Value returns the measurement named by the "measurement" key of the extra of Value, in the integer
+// units Value returns.
+func (m Measurement) Value(name string) (int64, error) {
+	switch name {
+	case "frequency_mhz":
+		return int64(math.Round(m.FrequencyHz * 1000)), nil
+	case "period_ns":
+		return int64(m.PeriodNs), nil
+	case "high_time_ns":
+		return int64(m.HighTimeNs), nil
+	case "duty_cycle_ppm":
+		return int64(math.Round(m.DutyCycle * 1e6)), nil
+	default:
+		return 0, fmt.Errorf("invalid measurement %q, supported measurements are frequency_mhz, period_ns, high_time_ns, and duty_cycle_ppm",
+			name)
+	}
+}
+
+// result returns the measurement as reported by the measure DoCommand.
+func (m Measurement) result(window time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"frequency_hz": m.FrequencyHz,
+		"period_ns":    m.PeriodNs,
+		"high_time_ns": m.HighTimeNs,
+		"duty_cycle":   m.DutyCycle,
+		"periods":      m.Periods,
+		"window_ms":    window.Milliseconds(),
+	}
+}
+
+// signalHistory keeps the recent changes of a pin, oldest first.
+type signalHistory struct {
+	mu      sync.Mutex
+	changes []levelChange
+	head    int // changes before head have left the window
+}
+
+// add records a change, and forgets the changes that left the window.
+func (h *signalHistory) add(change levelChange, window time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.changes = append(h.changes, change)
+	start := windowStart(change.nanoseconds, window)
+	for h.head < len(h.changes) && h.changes[h.head].nanoseconds < start {
+		h.head++
+	}
+	if len(h.changes)-h.head > maxMeasuredChanges {
+		h.head = len(h.changes) - maxMeasuredChanges
+	}
+	if h.head > len(h.changes)/2 {
+		h.changes = append(h.changes[:0], h.changes[h.head:]...)
+		h.head = 0
+	}
+}
+
+// measure measures the changes in the window that ends at now.
+func (h *signalHistory) measure(now uint64, window time.Duration) Measurement {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	start := windowStart(now, window)
+
+	var m Measurement
+	var rise, fall uint64 // the last rising edge, and the falling edge after it
+	var rose, fell bool
+	var periodsNs, highNs uint64
+	for _, change := range h.changes[h.head:] {
+		if change.nanoseconds < start || change.nanoseconds > now {
+			continue
+		}
+		if !change.high {
+			if rose && !fell {
+				fall, fell = change.nanoseconds, true
+				m.HighTimeNs = fall - rise
+			}
+			continue
+		}
+		if rose {
+			m.PeriodNs = change.nanoseconds - rise
+			m.Periods++
+			periodsNs += m.PeriodNs
+			if fell {
+				highNs += fall - rise
+			}
+		}
+		rise, rose, fell = change.nanoseconds, true, false
+	}
+	if periodsNs != 0 {
+		m.FrequencyHz = float64(m.Periods) / (float64(periodsNs) / float64(time.Second))
+		m.DutyCycle = float64(highNs) / float64(periodsNs)
+	}
+	return m
+}
+
+// windowStart returns the time the window that ends at end starts at.
+func windowStart(end uint64, window time.Duration) uint64 {
+	if end < uint64(window) {
+		return 0
+	}
+	return end - uint64(window)
+}
@@ -0,0 +1,102 @@
+package rpiutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestSignalHistory(t *testing.T) {
+	const ms = uint64(time.Millisecond)
+	window := time.Second
+
+	t.Run("a square wave", func(t *testing.T) {
+		var h signalHistory
+		// 100 Hz, high for 2.5 of every 10 ms
+		for period := range uint64(50) {
+			h.add(levelChange{high: true, nanoseconds: period * 10 * ms}, window)
+			h.add(levelChange{high: false, nanoseconds: period*10*ms + 5*ms/2}, window)
+		}
+		m := h.measure(500*ms, window)
+		test.That(t, m.FrequencyHz, test.ShouldAlmostEqual, 100)
+		test.That(t, m.PeriodNs, test.ShouldEqual, 10*ms)
+		test.That(t, m.HighTimeNs, test.ShouldEqual, 5*ms/2)
+		test.That(t, m.DutyCycle, test.ShouldAlmostEqual, 0.25)
+		test.That(t, m.Periods, test.ShouldEqual, 49)
+
+		// only the periods in the window are measured
+		m = h.measure(1400*ms, window)
+		test.That(t, m.Periods, test.ShouldEqual, 9)
+		test.That(t, m.FrequencyHz, test.ShouldAlmostEqual, 100)
+
+		// a signal that stopped measures 0
+		m = h.measure(2*uint64(time.Second), window)
+		test.That(t, m, test.ShouldResemble, Measurement{})
+	})
+
+	t.Run("a single pulse has no period", func(t *testing.T) {
+		var h signalHistory
+		h.add(levelChange{high: true, nanoseconds: 100 * ms}, window)
+		h.add(levelChange{high: false, nanoseconds: 130 * ms}, window)
+		m := h.measure(200*ms, window)
+		test.That(t, m.HighTimeNs, test.ShouldEqual, 30*ms)
+		test.That(t, m.FrequencyHz, test.ShouldEqual, 0)
+		test.That(t, m.Periods, test.ShouldEqual, 0)
+	})
+
+	t.Run("changes that left the window are forgotten", func(t *testing.T) {
+		var h signalHistory
+		for n := range uint64(1000) {
+			h.add(levelChange{high: n%2 == 0, nanoseconds: n * 10 * ms}, window)
+		}
+		test.That(t, len(h.changes)-h.head, test.ShouldEqual, 101)
+		test.That(t, len(h.changes), test.ShouldBeLessThanOrEqualTo, 202)
+	})
+}
+
+func TestMeasurement(t *testing.T) {
+	m := Measurement{FrequencyHz: 12.3456, PeriodNs: 81000000, HighTimeNs: 20000000, DutyCycle: 0.2469}
+	for name, value := range map[string]int64{
+		"frequency_mhz":  12346,
+		"period_ns":      81000000,
+		"high_time_ns":   20000000,
+		"duty_cycle_ppm": 246900,
+	} {
+		v, err := m.Value(name)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, v, test.ShouldEqual, value)
+	}
+	_, err := m.Value("rpm")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	i, err := CreateDigitalInterrupt(PinConfig{Name: "fan", Type: PinInterrupt, MeasureWindowMS: 200})
+	test.That(t, err, test.ShouldBeNil)
+	di := i.(*BasicDigitalInterrupt)
+	now := uint64(time.Now().UnixNano())
+	for n := range uint64(10) {
+		test.That(t, Tick(context.Background(), di, n%2 == 0, now-uint64(10-n)*uint64(5*time.Millisecond)), test.ShouldBeNil)
+	}
+	v, err := di.Value(context.Background(), map[string]interface{}{"measurement": "period_ns"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, v, test.ShouldEqual, 10*time.Millisecond)
+	v, err = di.Value(context.Background(), map[string]interface{}{"measurement": "duty_cycle_ppm"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, v, test.ShouldEqual, 500000)
+	_, err = di.Value(context.Background(), map[string]interface{}{"measurement": 1})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	result := MeasurementsResult([]*BasicDigitalInterrupt{di})["measurements"].(map[string]interface{})["fan"]
+	test.That(t, result.(map[string]interface{})["window_ms"], test.ShouldEqual, 200)
+	test.That(t, result.(map[string]interface{})["periods"], test.ShouldEqual, 4)
+
+	config := PinConfig{Name: "fan", Pin: "13", Type: PinInterrupt, MeasureWindowMS: MaxMeasureWindowMS + 1}
+	err = config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.measure_window_ms")
+	config = PinConfig{Name: "led", Pin: "13", Type: PinGPIO, MeasureWindowMS: 100}
+	err = config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.measure_window_ms")
+}
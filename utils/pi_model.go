@@ -0,0 +1,80 @@
+// Package rpiutils contains helpers for detecting which Raspberry Pi we are running on.
+package rpiutils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DeviceTreeModelPath is where the firmware publishes the board's model string.
+const DeviceTreeModelPath = "/proc/device-tree/model"
+
+// PiHardware describes the Raspberry Pi the module is running on.
+type PiHardware struct {
+	// Description is the model string from the device tree, e.g. "Raspberry Pi 4 Model B Rev 1.4".
+	Description string
+	// ModelName is the name of the board model matching this hardware, e.g. "rpi4". Keyboard and
+	// compute module variants map to the model of the Pi they are based on.
+	ModelName     string
+	ComputeModule bool
+}
+
+// UsesRP1 returns whether the GPIOs are provided by the RP1 chip (Pi 5, Pi 500 and CM5), which pigpio
+// does not support.
+func (hw PiHardware) UsesRP1() bool {
+	return hw.ModelName == "rpi5"
+}
+
+// DetectPiHardware reads the device tree to determine which Raspberry Pi we are running on.
+func DetectPiHardware() (PiHardware, error) {
+	model, err := os.ReadFile(DeviceTreeModelPath)
+	if err != nil {
+		return PiHardware{}, err
+	}
+	return ParsePiModel(string(model))
+}
+
+// ParsePiModel parses a device tree model string such as "Raspberry Pi 5 Model B Rev 1.0",
+// "Raspberry Pi 400 Rev 1.0", "Raspberry Pi Zero 2 W Rev 1.0" or "Raspberry Pi Compute Module 4 Rev 1.0".
+func ParsePiModel(model string) (PiHardware, error) {
+	description := strings.TrimSpace(strings.TrimRight(model, "\x00"))
+	hw := PiHardware{Description: description}
+
+	rest, ok := strings.CutPrefix(description, "Raspberry Pi ")
+	if !ok {
+		return hw, fmt.Errorf("%q is not a Raspberry Pi", description)
+	}
+	if cm, ok := strings.CutPrefix(rest, "Compute Module"); ok {
+		hw.ComputeModule = true
+		rest = strings.TrimSpace(cm)
+		// the original compute module has no generation number
+		if rest == "" || !isDigit(rest[0]) {
+			rest = "1"
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(rest, "Zero 2"):
+		hw.ModelName = "rpi0_2"
+	case strings.HasPrefix(rest, "Zero"):
+		hw.ModelName = "rpi0"
+	case strings.HasPrefix(rest, "Model"):
+		// the original Pi 1 models are named e.g. "Raspberry Pi Model B Plus Rev 1.2"
+		hw.ModelName = "rpi1"
+	case rest != "" && isDigit(rest[0]):
+		// keyboard variants such as the 400 and 500 share the generation of their first digit
+		switch rest[0] {
+		case '1', '2', '3', '4', '5':
+			hw.ModelName = "rpi" + rest[:1]
+		}
+	}
+	if hw.ModelName == "" {
+		return hw, fmt.Errorf("unsupported Raspberry Pi model %q", description)
+	}
+	return hw, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,47 @@
+package rpiutils
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestParsePiModel(t *testing.T) {
+	cases := []struct {
+		model         string
+		modelName     string
+		computeModule bool
+	}{
+		{"Raspberry Pi Model B Rev 2\x00", "rpi1", false},
+		{"Raspberry Pi Model B Plus Rev 1.2\x00", "rpi1", false},
+		{"Raspberry Pi Compute Module Rev 1.0\x00", "rpi1", true},
+		{"Raspberry Pi Zero W Rev 1.1\x00", "rpi0", false},
+		{"Raspberry Pi Zero 2 W Rev 1.0\x00", "rpi0_2", false},
+		{"Raspberry Pi 2 Model B Rev 1.1\x00", "rpi2", false},
+		{"Raspberry Pi 3 Model B Plus Rev 1.3\x00", "rpi3", false},
+		{"Raspberry Pi Compute Module 3 Plus Rev 1.0\x00", "rpi3", true},
+		{"Raspberry Pi 4 Model B Rev 1.4\x00", "rpi4", false},
+		{"Raspberry Pi 400 Rev 1.0\x00", "rpi4", false},
+		{"Raspberry Pi Compute Module 4 Rev 1.0\x00", "rpi4", true},
+		{"Raspberry Pi Compute Module 4S Rev 1.0\x00", "rpi4", true},
+		{"Raspberry Pi 5 Model B Rev 1.0\x00", "rpi5", false},
+		{"Raspberry Pi 500 Rev 1.0\x00", "rpi5", false},
+		{"Raspberry Pi Compute Module 5 Rev 1.0\x00", "rpi5", true},
+	}
+	for _, c := range cases {
+		hw, err := ParsePiModel(c.model)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, hw.ModelName, test.ShouldEqual, c.modelName)
+		test.That(t, hw.ComputeModule, test.ShouldEqual, c.computeModule)
+		test.That(t, hw.UsesRP1(), test.ShouldEqual, c.modelName == "rpi5")
+	}
+
+	hw, err := ParsePiModel("Raspberry Pi 4 Model B Rev 1.4\x00")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, hw.Description, test.ShouldEqual, "Raspberry Pi 4 Model B Rev 1.4")
+
+	_, err = ParsePiModel("NVIDIA Jetson Orin Nano Developer Kit")
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = ParsePiModel("Raspberry Pi 9 Model Z")
+	test.That(t, err, test.ShouldNotBeNil)
+}
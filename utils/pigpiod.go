@@ -0,0 +1,230 @@
+// Package rpiutils contains helpers for connecting to the pigpio daemon.
+package rpiutils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+
+	"go.viam.com/rdk/resource"
+)
+
+const (
+	// DefaultPigpiodHost is the host pigpiod is reached on if none is configured.
+	DefaultPigpiodHost = "localhost"
+	// DefaultPigpiodPort is the port pigpiod listens on by default.
+	DefaultPigpiodPort = 8888
+)
+
+// PigpiodEndpoint is the address of the pigpio daemon a board or servo talks to. An empty host or a
+// zero port falls back to the PIGPIO_ADDR and PIGPIO_PORT environment variables, like pigpio itself
+// does, and then to localhost:8888.
+type PigpiodEndpoint struct {
+	Host string
+	Port int
+}
+
+// Resolved returns the endpoint with the environment and defaults applied.
+func (e PigpiodEndpoint) Resolved() PigpiodEndpoint {
+	if e.Host == "" {
+		e.Host = os.Getenv("PIGPIO_ADDR")
+	}
+	if e.Host == "" {
+		e.Host = DefaultPigpiodHost
+	}
+	if e.Port == 0 {
+		if port, err := strconv.Atoi(os.Getenv("PIGPIO_PORT")); err == nil && port > 0 {
+			e.Port = port
+		}
+	}
+	if e.Port == 0 {
+		e.Port = DefaultPigpiodPort
+	}
+	return e
+}
+
+// IsLocal returns whether the daemon runs on this machine, in which case failing to reach it is
+// usually caused by the local setup rather than the network.
+func (e PigpiodEndpoint) IsLocal() bool {
+	host := e.Resolved().Host
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// String returns the resolved endpoint as host:port.
+func (e PigpiodEndpoint) String() string {
+	resolved := e.Resolved()
+	return net.JoinHostPort(resolved.Host, strconv.Itoa(resolved.Port))
+}
+
+// PigpiodMode selects whether the module runs its own pigpio daemon.
+type PigpiodMode string
+
+const (
+	// PigpiodModeDefault is the mode used if none is configured, which is PigpiodModeAuto.
+	PigpiodModeDefault PigpiodMode = ""
+	// PigpiodModeAuto connects to a daemon that is already running, and starts one otherwise.
+	PigpiodModeAuto PigpiodMode = "auto"
+	// PigpiodModeManaged always starts and supervises a daemon for the board.
+	PigpiodModeManaged PigpiodMode = "managed"
+	// PigpiodModeExternal only connects to a daemon that is managed outside of the module.
+	PigpiodModeExternal PigpiodMode = "external"
+)
+
+// ClockPeripheral is the peripheral pigpiod uses to time its samples.
+type ClockPeripheral string
+
+const (
+	// ClockPeripheralDefault is the peripheral used if none is configured, which is ClockPeripheralPCM.
+	ClockPeripheralDefault ClockPeripheral = ""
+	// ClockPeripheralPCM times samples with the PCM peripheral, leaving the PWM peripheral free for
+	// hardware pwm.
+	ClockPeripheralPCM ClockPeripheral = "pcm"
+	// ClockPeripheralPWM times samples with the PWM peripheral, leaving the PCM peripheral free for
+	// audio. Hardware pwm is not available then.
+	ClockPeripheralPWM ClockPeripheral = "pwm"
+)
+
+const (
+	// DefaultPigpiodSampleRateUS is the sample rate pigpiod uses if none is configured.
+	DefaultPigpiodSampleRateUS = 5
+	// MaxPigpiodDMAChannel is the highest DMA channel pigpiod can use.
+	MaxPigpiodDMAChannel = 14
+)
+
+// pigpiodSampleRatesUS are the sample rates pigpiod can run at, in microseconds.
+var pigpiodSampleRatesUS = []int{1, 2, 4, 5, 8, 10}
+
+// pwmRangeDivisors are what pigpio divides the sample frequency by to get the software pwm
+// frequencies it supports, see set_PWM_frequency.
+var pwmRangeDivisors = []int{25, 50, 100, 125, 200, 250, 400, 500, 625, 800, 1000, 1250, 2000, 2500, 4000, 5000, 10000, 20000}
+
+// PigpiodConfig holds the options of the pigpio daemon. When the module starts the daemon, they are
+// passed to it. When the daemon is managed elsewhere, sample_rate_us and clock_peripheral should
+// describe how it was started, so the board knows which pwm frequencies and peripherals it has.
+type PigpiodConfig struct {
+	Mode            PigpiodMode     `json:"mode,omitempty"`
+	SampleRateUS    int             `json:"sample_rate_us,omitempty"`   // 1, 2, 4, 5, 8 or 10, default 5
+	ClockPeripheral ClockPeripheral `json:"clock_peripheral,omitempty"` // pcm or pwm, default pcm
+	DMAChannel      *int            `json:"dma_channel,omitempty"`      // only used when the module starts the daemon
+	Path            string          `json:"path,omitempty"`             // pigpiod binary to start, default the bundled one
+}
+
+// Validate ensures all parts of the config are valid. The daemon is only started by the module if
+// it runs on this machine.
+func (config *PigpiodConfig) Validate(path string, endpoint PigpiodEndpoint) error {
+	switch config.Mode {
+	case PigpiodModeDefault, PigpiodModeAuto, PigpiodModeExternal:
+	case PigpiodModeManaged:
+		if !endpoint.IsLocal() {
+			return resource.NewConfigValidationError(path+".mode",
+				fmt.Errorf("cannot start pigpiod on %s, the module can only start a daemon on this machine", endpoint))
+		}
+	default:
+		return resource.NewConfigValidationError(path+".mode",
+			fmt.Errorf("unknown mode %q, expected auto, managed or external", config.Mode))
+	}
+	if config.SampleRateUS != 0 && !slices.Contains(pigpiodSampleRatesUS, config.SampleRateUS) {
+		return resource.NewConfigValidationError(path+".sample_rate_us",
+			fmt.Errorf("sample_rate_us must be one of %v, got %d", pigpiodSampleRatesUS, config.SampleRateUS))
+	}
+	switch config.ClockPeripheral {
+	case ClockPeripheralDefault, ClockPeripheralPCM, ClockPeripheralPWM:
+	default:
+		return resource.NewConfigValidationError(path+".clock_peripheral",
+			fmt.Errorf("unknown clock peripheral %q, expected pcm or pwm", config.ClockPeripheral))
+	}
+	if config.DMAChannel != nil {
+		if config.Mode == PigpiodModeExternal {
+			return resource.NewConfigValidationError(path+".dma_channel",
+				errors.New("dma_channel is only used when the module starts pigpiod"))
+		}
+		if *config.DMAChannel < 0 || *config.DMAChannel > MaxPigpiodDMAChannel {
+			return resource.NewConfigValidationError(path+".dma_channel",
+				fmt.Errorf("dma_channel must be between 0 and %d, got %d", MaxPigpiodDMAChannel, *config.DMAChannel))
+		}
+	}
+	if config.Path != "" && config.Mode == PigpiodModeExternal {
+		return resource.NewConfigValidationError(path+".path", errors.New("path is only used when the module starts pigpiod"))
+	}
+	return nil
+}
+
+// Equals returns whether two configs describe the same daemon.
+func (config PigpiodConfig) Equals(other PigpiodConfig) bool {
+	if (config.DMAChannel == nil) != (other.DMAChannel == nil) ||
+		(config.DMAChannel != nil && *config.DMAChannel != *other.DMAChannel) {
+		return false
+	}
+	return config.ModeOrDefault() == other.ModeOrDefault() && config.SampleRateOrDefault() == other.SampleRateOrDefault() &&
+		config.UsesPWMClock() == other.UsesPWMClock() && config.Path == other.Path
+}
+
+// ModeOrDefault returns the configured mode, or the default if none was configured.
+func (config PigpiodConfig) ModeOrDefault() PigpiodMode {
+	if config.Mode == PigpiodModeDefault {
+		return PigpiodModeAuto
+	}
+	return config.Mode
+}
+
+// SampleRateOrDefault returns the configured sample rate in microseconds, or the default if none was
+// configured.
+func (config PigpiodConfig) SampleRateOrDefault() int {
+	if config.SampleRateUS == 0 {
+		return DefaultPigpiodSampleRateUS
+	}
+	return config.SampleRateUS
+}
+
+// UsesPWMClock returns whether the daemon times its samples with the PWM peripheral, which makes
+// hardware pwm unavailable.
+func (config PigpiodConfig) UsesPWMClock() bool {
+	return config.ClockPeripheral == ClockPeripheralPWM
+}
+
+// Args returns the command line arguments to start the daemon with. The daemon runs in the
+// foreground so it can be supervised, and only accepts local connections on the given port.
+func (config PigpiodConfig) Args(port int) []string {
+	args := []string{"-g", "-l", "-p", strconv.Itoa(port), "-s", strconv.Itoa(config.SampleRateOrDefault())}
+	if config.UsesPWMClock() {
+		args = append(args, "-t", "0")
+	} else {
+		args = append(args, "-t", "1")
+	}
+	if config.DMAChannel != nil {
+		args = append(args, "-d", strconv.Itoa(*config.DMAChannel))
+	}
+	return args
+}
+
+// PWMFrequencies returns the software pwm frequencies pigpiod supports at the given sample rate,
+// from highest to lowest. Any other frequency is rounded to the closest of these.
+func PWMFrequencies(sampleRateUS int) []uint {
+	freqs := make([]uint, 0, len(pwmRangeDivisors))
+	for _, divisor := range pwmRangeDivisors {
+		freqs = append(freqs, uint(math.Round(1e6/float64(sampleRateUS*divisor))))
+	}
+	return freqs
+}
+
+// validatePigpiodPort checks that a configured pigpiod port is a valid tcp port.
+func validatePigpiodPort(port int) error {
+	if port < 0 || port > 65535 {
+		return fmt.Errorf("invalid port %d", port)
+	}
+	return nil
+}
+
+// A PigpiodBoard is a board that talks to a pigpio daemon. Servos driven by the same daemon use it
+// to inherit the board's endpoint.
+type PigpiodBoard interface {
+	PigpiodEndpoint() PigpiodEndpoint
+}
@@ -0,0 +1,110 @@
+package rpiutils
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestPigpiodEndpoint(t *testing.T) {
+	t.Setenv("PIGPIO_ADDR", "")
+	t.Setenv("PIGPIO_PORT", "")
+
+	test.That(t, PigpiodEndpoint{}.String(), test.ShouldEqual, "localhost:8888")
+	test.That(t, PigpiodEndpoint{}.IsLocal(), test.ShouldBeTrue)
+	test.That(t, PigpiodEndpoint{Port: 8889}.String(), test.ShouldEqual, "localhost:8889")
+
+	bench := PigpiodEndpoint{Host: "192.168.1.20", Port: 9000}
+	test.That(t, bench.String(), test.ShouldEqual, "192.168.1.20:9000")
+	test.That(t, bench.IsLocal(), test.ShouldBeFalse)
+	test.That(t, PigpiodEndpoint{Host: "127.0.0.1"}.IsLocal(), test.ShouldBeTrue)
+	test.That(t, PigpiodEndpoint{Host: "::1"}.String(), test.ShouldEqual, "[::1]:8888")
+
+	// pigpio's environment variables are used if nothing is configured
+	t.Setenv("PIGPIO_ADDR", "benchpi.local")
+	t.Setenv("PIGPIO_PORT", "7777")
+	test.That(t, PigpiodEndpoint{}.String(), test.ShouldEqual, "benchpi.local:7777")
+	test.That(t, bench.String(), test.ShouldEqual, "192.168.1.20:9000")
+}
+
+func TestConfigValidatePigpiodPort(t *testing.T) {
+	conf := Config{PigpiodHost: "benchpi.local", PigpiodPort: 8889}
+	_, _, err := conf.Validate("attributes")
+	test.That(t, err, test.ShouldBeNil)
+
+	conf.PigpiodPort = 70000
+	_, _, err = conf.Validate("attributes")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pigpiod_port")
+}
+
+func TestConfigValidatePigpiod(t *testing.T) {
+	validate := func(conf Config) error {
+		_, _, err := conf.Validate("attributes")
+		return err
+	}
+	dma := 5
+	err := validate(Config{Pigpiod: PigpiodConfig{
+		Mode:            PigpiodModeManaged,
+		SampleRateUS:    2,
+		ClockPeripheral: ClockPeripheralPWM,
+		DMAChannel:      &dma,
+	}})
+	test.That(t, err, test.ShouldBeNil)
+
+	err = validate(Config{PigpiodHost: "benchpi.local", Pigpiod: PigpiodConfig{Mode: PigpiodModeManaged}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pigpiod.mode")
+
+	err = validate(Config{Pigpiod: PigpiodConfig{Mode: "sometimes"}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pigpiod.mode")
+
+	err = validate(Config{Pigpiod: PigpiodConfig{SampleRateUS: 3}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pigpiod.sample_rate_us")
+
+	err = validate(Config{Pigpiod: PigpiodConfig{ClockPeripheral: "i2s"}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pigpiod.clock_peripheral")
+
+	err = validate(Config{Pigpiod: PigpiodConfig{Mode: PigpiodModeExternal, DMAChannel: &dma}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pigpiod.dma_channel")
+
+	dma = 15
+	err = validate(Config{Pigpiod: PigpiodConfig{DMAChannel: &dma}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pigpiod.dma_channel")
+
+	// hardware pwm needs the PWM peripheral, so it cannot be the daemon's clock
+	err = validate(Config{
+		Pins:    []PinConfig{{Name: "fan", Pin: "12", Type: PinGPIO, PWMMode: PWMModeHardware}},
+		Pigpiod: PigpiodConfig{ClockPeripheral: ClockPeripheralPWM},
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.pins.0.pwm_mode")
+}
+
+func TestPigpiodConfigArgs(t *testing.T) {
+	test.That(t, PigpiodConfig{}.Args(8888), test.ShouldResemble,
+		[]string{"-g", "-l", "-p", "8888", "-s", "5", "-t", "1"})
+
+	dma := 10
+	conf := PigpiodConfig{SampleRateUS: 1, ClockPeripheral: ClockPeripheralPWM, DMAChannel: &dma}
+	test.That(t, conf.Args(8889), test.ShouldResemble,
+		[]string{"-g", "-l", "-p", "8889", "-s", "1", "-t", "0", "-d", "10"})
+
+	test.That(t, PigpiodConfig{}.Equals(PigpiodConfig{Mode: PigpiodModeAuto, SampleRateUS: 5}), test.ShouldBeTrue)
+	test.That(t, PigpiodConfig{}.Equals(conf), test.ShouldBeFalse)
+}
+
+func TestPWMFrequencies(t *testing.T) {
+	// the table in the pigpio documentation of set_PWM_frequency
+	test.That(t, PWMFrequencies(5), test.ShouldResemble,
+		[]uint{8000, 4000, 2000, 1600, 1000, 800, 500, 400, 320, 250, 200, 160, 100, 80, 50, 40, 20, 10})
+	test.That(t, PWMFrequencies(1), test.ShouldResemble,
+		[]uint{40000, 20000, 10000, 8000, 5000, 4000, 2500, 2000, 1600, 1250, 1000, 800, 500, 400, 250, 200, 100, 50})
+	test.That(t, PWMFrequencies(4), test.ShouldResemble,
+		[]uint{10000, 5000, 2500, 2000, 1250, 1000, 625, 500, 400, 313, 250, 200, 125, 100, 63, 50, 25, 13})
+}
@@ -0,0 +1,82 @@
+// Package rpiutils contains helpers for reporting the live state of pins.
+package rpiutils
+
+import (
+	"sort"
+)
+
+// PinState describes the live hardware state of a single GPIO as read back from the board.
+type PinState struct {
+	GPIO     uint
+	Name     string
+	Function string // input, output, alt0-alt5 (a0-a8 on the Pi 5), or none
+	Level    bool
+	Pull     Pull // PullDefault if the backend cannot read the pull back
+
+	// Pad settings are only reported by boards that can read them back (the Pi 5).
+	Pad *PadState
+}
+
+// PadState describes the electrical configuration of a pin's pad.
+type PadState struct {
+	DriveMA        int
+	SlewFast       bool
+	Schmitt        bool
+	InputEnabled   bool
+	OutputDisabled bool
+}
+
+// ToMap converts the pin state into a DoCommand response entry.
+func (s PinState) ToMap() map[string]interface{} {
+	level := 0
+	if s.Level {
+		level = 1
+	}
+	pull := string(s.Pull)
+	if s.Pull == PullDefault {
+		pull = "unknown"
+	}
+	state := map[string]interface{}{
+		"gpio":     s.GPIO,
+		"function": s.Function,
+		"level":    level,
+		"pull":     pull,
+	}
+	if s.Name != "" {
+		state["name"] = s.Name
+	}
+	if s.Pad != nil {
+		state["pad"] = map[string]interface{}{
+			"drive_ma":        s.Pad.DriveMA,
+			"slew_fast":       s.Pad.SlewFast,
+			"schmitt":         s.Pad.Schmitt,
+			"input_enabled":   s.Pad.InputEnabled,
+			"output_disabled": s.Pad.OutputDisabled,
+		}
+	}
+	return state
+}
+
+// PinStatesToResponse wraps a list of pin states into a DoCommand response.
+func PinStatesToResponse(states []PinState) map[string]interface{} {
+	pins := make([]interface{}, 0, len(states))
+	for _, s := range states {
+		pins = append(pins, s.ToMap())
+	}
+	return map[string]interface{}{"pins": pins}
+}
+
+// HeaderBroadcomPins returns the sorted broadcom numbers of every GPIO broken out on the 40 pin header.
+func HeaderBroadcomPins() []uint {
+	seen := map[uint]struct{}{}
+	pins := []uint{}
+	for _, bcom := range piHWPinToBroadcom {
+		if _, ok := seen[bcom]; ok {
+			continue
+		}
+		seen[bcom] = struct{}{}
+		pins = append(pins, bcom)
+	}
+	sort.Slice(pins, func(i, j int) bool { return pins[i] < pins[j] })
+	return pins
+}
@@ -0,0 +1,34 @@
+package rpiutils
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestPinStateToMap(t *testing.T) {
+	state := PinState{GPIO: 17, Name: "led", Function: "output", Level: true}
+	m := state.ToMap()
+	test.That(t, m["gpio"], test.ShouldEqual, uint(17))
+	test.That(t, m["name"], test.ShouldEqual, "led")
+	test.That(t, m["function"], test.ShouldEqual, "output")
+	test.That(t, m["level"], test.ShouldEqual, 1)
+	test.That(t, m["pull"], test.ShouldEqual, "unknown")
+	_, hasPad := m["pad"]
+	test.That(t, hasPad, test.ShouldBeFalse)
+
+	state = PinState{GPIO: 4, Function: "a0", Pull: PullDown, Pad: &PadState{DriveMA: 8, InputEnabled: true}}
+	m = state.ToMap()
+	test.That(t, m["level"], test.ShouldEqual, 0)
+	test.That(t, m["pull"], test.ShouldEqual, "down")
+	pad := m["pad"].(map[string]interface{})
+	test.That(t, pad["drive_ma"], test.ShouldEqual, 8)
+	test.That(t, pad["input_enabled"], test.ShouldBeTrue)
+}
+
+func TestHeaderBroadcomPins(t *testing.T) {
+	pins := HeaderBroadcomPins()
+	test.That(t, len(pins), test.ShouldEqual, 28)
+	test.That(t, pins[0], test.ShouldEqual, uint(0))
+	test.That(t, pins[27], test.ShouldEqual, uint(27))
+}
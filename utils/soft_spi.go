@@ -0,0 +1,140 @@
+// Package rpiutils contains the configuration of bit-banged spi buses.
+package rpiutils
+
+import (
+	"errors"
+	"fmt"
+
+	"go.viam.com/rdk/resource"
+)
+
+const (
+	// MinSoftSPIBaud is the slowest baud rate pigpio can bit-bang an spi bus at.
+	MinSoftSPIBaud = 50
+	// MaxSoftSPIBaud is the fastest baud rate pigpio can bit-bang an spi bus at.
+	MaxSoftSPIBaud = 250000
+	// DefaultSoftSPIBaud is used if no baud rate is configured.
+	DefaultSoftSPIBaud = MaxSoftSPIBaud
+)
+
+// SoftSPIConfig describes an spi bus that is bit-banged on arbitrary GPIOs. The chip select pins are
+// chosen by the devices on the bus, so any number of devices can share it.
+type SoftSPIConfig struct {
+	Name     string `json:"name"`
+	MISO     string `json:"miso"`
+	MOSI     string `json:"mosi"`
+	SCLK     string `json:"sclk"`
+	BaudRate int    `json:"baud_rate,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (config *SoftSPIConfig) Validate(path string) error {
+	if config.Name == "" {
+		return resource.NewConfigValidationFieldRequiredError(path, "name")
+	}
+	seen := map[uint]string{}
+	for _, pin := range config.pins() {
+		if pin.label == "" {
+			return resource.NewConfigValidationFieldRequiredError(path, pin.field)
+		}
+		bcom, ok := BroadcomPinFromHardwareLabel(pin.label)
+		if !ok {
+			return resource.NewConfigValidationError(path+"."+pin.field, fmt.Errorf("unknown pin %q", pin.label))
+		}
+		if other, ok := seen[bcom]; ok {
+			return resource.NewConfigValidationError(path+"."+pin.field, fmt.Errorf("pin %q is already used as %s", pin.label, other))
+		}
+		seen[bcom] = pin.field
+	}
+	if config.BaudRate != 0 && (config.BaudRate < MinSoftSPIBaud || config.BaudRate > MaxSoftSPIBaud) {
+		return resource.NewConfigValidationError(path+".baud_rate",
+			fmt.Errorf("baud_rate must be between %d and %d, got %d", MinSoftSPIBaud, MaxSoftSPIBaud, config.BaudRate))
+	}
+	return nil
+}
+
+// softSPIPin is one of the pins of a soft spi bus and the config field it came from.
+type softSPIPin struct {
+	field string
+	label string
+}
+
+// pins returns the data and clock pins of the soft spi bus.
+func (config *SoftSPIConfig) pins() []softSPIPin {
+	return []softSPIPin{{"miso", config.MISO}, {"mosi", config.MOSI}, {"sclk", config.SCLK}}
+}
+
+// BaudRateOrDefault returns the configured baud rate, or the default if none was configured.
+func (config *SoftSPIConfig) BaudRateOrDefault() int {
+	if config.BaudRate == 0 {
+		return DefaultSoftSPIBaud
+	}
+	return config.BaudRate
+}
+
+// SoftSPIByName returns the config of the soft spi bus with the given name, if there is one.
+func (conf *Config) SoftSPIByName(name string) (SoftSPIConfig, bool) {
+	for _, c := range conf.SoftSPIs {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return SoftSPIConfig{}, false
+}
+
+// validateSoftSPIs validates each soft spi bus and the chip selects of the analogs on them. Bus names
+// have to be unique, bus pins cannot already be in use, and chip selects cannot be used by anything
+// but devices on the same bus. usedPins maps broadcom pins to the config path using them.
+func (conf *Config) validateSoftSPIs(path string, usedPins map[uint]string) error {
+	names := map[string]int{}
+	for idx, c := range conf.SoftSPIs {
+		busPath := fmt.Sprintf("%s.%s.%d", path, "soft_spis", idx)
+		if err := c.Validate(busPath); err != nil {
+			return err
+		}
+		if other, ok := names[c.Name]; ok {
+			return resource.NewConfigValidationError(busPath+".name",
+				fmt.Errorf("name %q is already used by %s.soft_spis.%d", c.Name, path, other))
+		}
+		names[c.Name] = idx
+
+		for _, pin := range c.pins() {
+			bcom, _ := BroadcomPinFromHardwareLabel(pin.label)
+			if other, ok := usedPins[bcom]; ok {
+				return resource.NewConfigValidationError(busPath+"."+pin.field,
+					fmt.Errorf("pin %q is already used by %s", pin.label, other))
+			}
+			usedPins[bcom] = busPath
+		}
+	}
+
+	chipSelects := map[uint]string{}
+	for idx, c := range conf.AnalogReaders {
+		if _, ok := names[c.SPIBus]; !ok {
+			continue
+		}
+		analogPath := fmt.Sprintf("%s.%s.%d", path, "analogs", idx)
+		if c.ChipSelect == "" {
+			return resource.NewConfigValidationFieldRequiredError(analogPath, "chip_select")
+		}
+		bcom, ok := BroadcomPinFromHardwareLabel(c.ChipSelect)
+		if !ok {
+			return resource.NewConfigValidationError(analogPath+".chip_select", fmt.Errorf("unknown pin %q", c.ChipSelect))
+		}
+		// several channels of one adc share its chip select
+		if bus, ok := chipSelects[bcom]; ok {
+			if bus != c.SPIBus {
+				return resource.NewConfigValidationError(analogPath+".chip_select",
+					errors.New("chip select is already used on a different spi bus"))
+			}
+			continue
+		}
+		if other, ok := usedPins[bcom]; ok {
+			return resource.NewConfigValidationError(analogPath+".chip_select",
+				fmt.Errorf("pin %q is already used by %s", c.ChipSelect, other))
+		}
+		usedPins[bcom] = analogPath
+		chipSelects[bcom] = c.SPIBus
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package rpiutils
+
+import (
+	"testing"
+
+	"go.viam.com/rdk/components/board/mcp3008helper"
+	"go.viam.com/test"
+)
+
+func TestConfigValidateSoftSPIs(t *testing.T) {
+	validate := func(conf Config) error {
+		_, _, err := conf.Validate("attributes")
+		return err
+	}
+	bus := SoftSPIConfig{Name: "adcs", MISO: "29", MOSI: "31", SCLK: "33"}
+
+	t.Run("valid buses", func(t *testing.T) {
+		err := validate(Config{
+			SoftSPIs: []SoftSPIConfig{bus},
+			AnalogReaders: []mcp3008helper.MCP3008AnalogConfig{
+				{Name: "a0", Channel: "0", SPIBus: "adcs", ChipSelect: "36"},
+				{Name: "a1", Channel: "1", SPIBus: "adcs", ChipSelect: "36"},
+				{Name: "b0", Channel: "0", SPIBus: "adcs", ChipSelect: "37"},
+				{Name: "hw", Channel: "0", SPIBus: "0", ChipSelect: "24"},
+			},
+		})
+		test.That(t, err, test.ShouldBeNil)
+	})
+
+	t.Run("invalid buses", func(t *testing.T) {
+		err := validate(Config{SoftSPIs: []SoftSPIConfig{{Name: "adcs", MISO: "29", MOSI: "31"}}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "sclk")
+
+		err = validate(Config{SoftSPIs: []SoftSPIConfig{{Name: "adcs", MISO: "29", MOSI: "29", SCLK: "33"}}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.soft_spis.0.mosi")
+
+		err = validate(Config{SoftSPIs: []SoftSPIConfig{{Name: "adcs", MISO: "29", MOSI: "31", SCLK: "33", BaudRate: 1000000}}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.soft_spis.0.baud_rate")
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		err := validate(Config{SoftSPIs: []SoftSPIConfig{bus, bus}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.soft_spis.1.name")
+
+		err = validate(Config{
+			Pins:     []PinConfig{{Name: "led", Pin: "33", Type: PinGPIO}},
+			SoftSPIs: []SoftSPIConfig{bus},
+		})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.soft_spis.0.sclk")
+
+		err = validate(Config{
+			SoftSPIs: []SoftSPIConfig{bus},
+			AnalogReaders: []mcp3008helper.MCP3008AnalogConfig{
+				{Name: "a0", Channel: "0", SPIBus: "adcs", ChipSelect: "31"},
+			},
+		})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.analogs.0.chip_select")
+
+		err = validate(Config{
+			SoftSPIs: []SoftSPIConfig{bus, {Name: "more", MISO: "11", MOSI: "13", SCLK: "15"}},
+			AnalogReaders: []mcp3008helper.MCP3008AnalogConfig{
+				{Name: "a0", Channel: "0", SPIBus: "adcs", ChipSelect: "36"},
+				{Name: "b0", Channel: "0", SPIBus: "more", ChipSelect: "36"},
+			},
+		})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.analogs.1.chip_select")
+	})
+}
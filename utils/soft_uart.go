@@ -0,0 +1,140 @@
+// Package rpiutils contains the configuration and interfaces of bit-banged serial ports.
+package rpiutils
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"go.viam.com/rdk/resource"
+)
+
+const (
+	// MinSoftUARTBaud is the slowest baud rate pigpio can bit-bang.
+	MinSoftUARTBaud = 50
+	// MaxSoftUARTBaud is the fastest baud rate pigpio can receive when bit-banging.
+	MaxSoftUARTBaud = 250000
+	// DefaultSoftUARTDataBits is used if no data bits are configured.
+	DefaultSoftUARTDataBits = 8
+)
+
+// SoftUARTConfig describes a serial port that is bit-banged on arbitrary GPIOs. A port can be
+// receive only, transmit only, or both.
+type SoftUARTConfig struct {
+	Name     string `json:"name"`
+	RXPin    string `json:"rx_pin,omitempty"`
+	TXPin    string `json:"tx_pin,omitempty"`
+	BaudRate int    `json:"baud_rate"`
+	DataBits int    `json:"data_bits,omitempty"` // 1-32, default 8
+}
+
+// Validate ensures all parts of the config are valid.
+func (config *SoftUARTConfig) Validate(path string) error {
+	if config.Name == "" {
+		return resource.NewConfigValidationFieldRequiredError(path, "name")
+	}
+	if config.RXPin == "" && config.TXPin == "" {
+		return resource.NewConfigValidationError(path, errors.New("at least one of rx_pin and tx_pin is required"))
+	}
+	for _, pin := range config.pins() {
+		if _, ok := BroadcomPinFromHardwareLabel(pin.label); !ok {
+			return resource.NewConfigValidationError(path+"."+pin.field, fmt.Errorf("unknown pin %q", pin.label))
+		}
+	}
+	if config.RXPin != "" && config.RXPin == config.TXPin {
+		return resource.NewConfigValidationError(path+".tx_pin", errors.New("rx_pin and tx_pin must be different pins"))
+	}
+	if config.BaudRate < MinSoftUARTBaud || config.BaudRate > MaxSoftUARTBaud {
+		return resource.NewConfigValidationError(path+".baud_rate",
+			fmt.Errorf("baud_rate must be between %d and %d, got %d", MinSoftUARTBaud, MaxSoftUARTBaud, config.BaudRate))
+	}
+	if config.DataBits < 0 || config.DataBits > 32 {
+		return resource.NewConfigValidationError(path+".data_bits",
+			fmt.Errorf("data_bits must be between 1 and 32, got %d", config.DataBits))
+	}
+	return nil
+}
+
+// softUARTPin is one of the pins of a soft uart and the config field it came from.
+type softUARTPin struct {
+	field string
+	label string
+}
+
+// pins returns the configured pins of the soft uart.
+func (config *SoftUARTConfig) pins() []softUARTPin {
+	pins := []softUARTPin{}
+	if config.RXPin != "" {
+		pins = append(pins, softUARTPin{"rx_pin", config.RXPin})
+	}
+	if config.TXPin != "" {
+		pins = append(pins, softUARTPin{"tx_pin", config.TXPin})
+	}
+	return pins
+}
+
+// DataBitsOrDefault returns the configured data bits, or the default if none were configured.
+func (config *SoftUARTConfig) DataBitsOrDefault() int {
+	if config.DataBits == 0 {
+		return DefaultSoftUARTDataBits
+	}
+	return config.DataBits
+}
+
+// A SoftUART is a bit-banged serial port. Read returns whatever has been received since the last
+// read without blocking, so it returns 0 bytes if nothing arrived. Characters of more than 8 data
+// bits take 2 bytes, and characters of more than 16 data bits take 4 bytes, least significant byte
+// first. Write blocks until the data has been transmitted.
+type SoftUART interface {
+	Read(p []byte) (int, error)
+	Write(p []byte) (int, error)
+}
+
+// A SoftUARTBoard is a board that provides bit-banged serial ports. Other components can look up the
+// board in their dependencies and type assert it to this interface to use the ports.
+type SoftUARTBoard interface {
+	SoftUARTByName(name string) (SoftUART, error)
+}
+
+// SoftUARTFromCommand returns the required "name" argument of a soft serial DoCommand request.
+func SoftUARTFromCommand(cmd map[string]interface{}) (string, error) {
+	name, ok := cmd["name"].(string)
+	if !ok || name == "" {
+		return "", errors.New("expected \"name\" to be the name of a soft uart")
+	}
+	return name, nil
+}
+
+// DataFromCommand decodes the "data" argument of a soft serial write. The data is sent as is
+// unless "encoding" is "base64".
+func DataFromCommand(cmd map[string]interface{}) ([]byte, error) {
+	data, ok := cmd["data"].(string)
+	if !ok {
+		return nil, errors.New("expected \"data\" to be a string")
+	}
+	switch cmd["encoding"] {
+	case nil, "text":
+		return []byte(data), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(data)
+	default:
+		return nil, fmt.Errorf("unknown encoding %v, supported encodings are text and base64", cmd["encoding"])
+	}
+}
+
+// DataToResponse encodes data received by a soft serial read, using the same "encoding" argument as
+// DataFromCommand. Binary data cannot be returned as text, so it has to be read as base64.
+func DataToResponse(cmd map[string]interface{}, data []byte) (map[string]interface{}, error) {
+	switch cmd["encoding"] {
+	case nil, "text":
+		if !utf8.Valid(data) {
+			return nil, errors.New("received data is not valid text, read it with \"encoding\": \"base64\" instead")
+		}
+		return map[string]interface{}{"data": string(data)}, nil
+	case "base64":
+		return map[string]interface{}{"data": base64.StdEncoding.EncodeToString(data)}, nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %v, supported encodings are text and base64", cmd["encoding"])
+	}
+}
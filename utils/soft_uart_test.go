@@ -0,0 +1,102 @@
+package rpiutils
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestConfigValidateSoftUARTs(t *testing.T) {
+	validate := func(conf Config) error {
+		_, _, err := conf.Validate("attributes")
+		return err
+	}
+
+	t.Run("valid soft uarts", func(t *testing.T) {
+		err := validate(Config{
+			Pins: []PinConfig{{Name: "led", Pin: "11", Type: PinGPIO}},
+			SoftUARTs: []SoftUARTConfig{
+				{Name: "gps", RXPin: "13", TXPin: "15", BaudRate: 9600},
+				{Name: "rfid", RXPin: "16", BaudRate: 115200, DataBits: 9},
+			},
+		})
+		test.That(t, err, test.ShouldBeNil)
+	})
+
+	t.Run("invalid soft uarts", func(t *testing.T) {
+		err := validate(Config{SoftUARTs: []SoftUARTConfig{{RXPin: "13", BaudRate: 9600}}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.soft_uarts.0")
+
+		err = validate(Config{SoftUARTs: []SoftUARTConfig{{Name: "gps", BaudRate: 9600}}})
+		test.That(t, err, test.ShouldNotBeNil)
+
+		err = validate(Config{SoftUARTs: []SoftUARTConfig{{Name: "gps", TXPin: "6", BaudRate: 9600}}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.soft_uarts.0.tx_pin")
+
+		err = validate(Config{SoftUARTs: []SoftUARTConfig{{Name: "gps", RXPin: "13", BaudRate: 10}}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.soft_uarts.0.baud_rate")
+
+		err = validate(Config{SoftUARTs: []SoftUARTConfig{{Name: "gps", RXPin: "13", BaudRate: 9600, DataBits: 33}}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.soft_uarts.0.data_bits")
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		err := validate(Config{SoftUARTs: []SoftUARTConfig{
+			{Name: "gps", RXPin: "13", BaudRate: 9600},
+			{Name: "gps", RXPin: "15", BaudRate: 9600},
+		}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.soft_uarts.1.name")
+
+		err = validate(Config{SoftUARTs: []SoftUARTConfig{
+			{Name: "gps", RXPin: "13", BaudRate: 9600},
+			{Name: "rfid", TXPin: "io27", BaudRate: 9600},
+		}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.soft_uarts.1.tx_pin")
+
+		err = validate(Config{
+			Pins:      []PinConfig{{Name: "led", Pin: "13", Type: PinGPIO}},
+			SoftUARTs: []SoftUARTConfig{{Name: "gps", RXPin: "13", BaudRate: 9600}},
+		})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "attributes.soft_uarts.0.rx_pin")
+
+		// pins that only set a pull can be shared
+		err = validate(Config{
+			Pins:      []PinConfig{{Pin: "13", PullState: PullUp}},
+			SoftUARTs: []SoftUARTConfig{{Name: "gps", RXPin: "13", BaudRate: 9600}},
+		})
+		test.That(t, err, test.ShouldBeNil)
+	})
+}
+
+func TestSoftUARTData(t *testing.T) {
+	data, err := DataFromCommand(map[string]interface{}{"data": "$PMTK220,1000*1F\r\n"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, data, test.ShouldResemble, []byte("$PMTK220,1000*1F\r\n"))
+
+	data, err = DataFromCommand(map[string]interface{}{"data": "AP8=", "encoding": "base64"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, data, test.ShouldResemble, []byte{0x00, 0xff})
+
+	_, err = DataFromCommand(map[string]interface{}{"data": 5.0})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = DataFromCommand(map[string]interface{}{"data": "abc", "encoding": "hex"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	resp, err := DataToResponse(map[string]interface{}{}, []byte("$GPGGA"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["data"], test.ShouldEqual, "$GPGGA")
+
+	_, err = DataToResponse(map[string]interface{}{}, []byte{0x00, 0xff})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	resp, err = DataToResponse(map[string]interface{}{"encoding": "base64"}, []byte{0x00, 0xff})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["data"], test.ShouldEqual, "AP8=")
+}
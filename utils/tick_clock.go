@@ -0,0 +1,82 @@
+package rpiutils
+
+/*
+	tick_clock.go: Converts the ticks of pigpiod to Unix time. The daemon timestamps changes with a
+	32 bit count of microseconds since it started, which wraps around every ~72 minutes and has no
+	relation to the time of day. A TickClock extends the ticks to 64 bits and maps them onto the
+	system time of a sync, a pair of a tick and the time it was read at. Syncing again now and then
+	corrects the drift between the clock of the daemon and the system clock, and follows changes of
+	the system time, so ticks of the Pi 0-4 can be merged with those of the Pi 5.
+*/
+
+import (
+	"sync"
+	"time"
+)
+
+// TickClockSyncInterval is how often the boards sync the clock of their ticks with the system time.
+const TickClockSyncInterval = time.Minute
+
+// A TickClock converts the 32 bit microsecond ticks of a pigpio daemon to Unix nanoseconds. Ticks
+// have to reach it less than ~36 minutes apart, which the periodic syncs ensure.
+type TickClock struct {
+	mu     sync.Mutex
+	synced bool
+	// lastTick is the newest tick seen, and extended the same tick extended to 64 bits
+	lastTick uint32
+	extended int64
+	// syncTick is the extended tick of the last sync, and syncNs the Unix time it was read at
+	syncTick int64
+	syncNs   int64
+}
+
+// Sync maps a tick onto the Unix time it was read at. Ticks are converted relative to the last sync.
+func (c *TickClock) Sync(tick uint32, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sync(tick, now)
+}
+
+// sync maps a tick onto the Unix time it was read at.
+// The clock mutex should be locked before calling this.
+func (c *TickClock) sync(tick uint32, now time.Time) {
+	if !c.synced {
+		c.synced = true
+		c.lastTick = tick
+		c.extended = int64(tick)
+	}
+	c.syncTick = c.extend(tick)
+	c.syncNs = now.UnixNano()
+}
+
+// Reset forgets the ticks seen so far, for a daemon that started over. The next tick is synced with
+// the time it was converted at, unless Sync is called first.
+func (c *TickClock) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.synced = false
+}
+
+// extend returns the tick extended to 64 bits. Ticks are compared to the newest tick seen as signed
+// 32 bit differences, so ticks from before a wrap around, such as those of a callback that raced a
+// sync, are extended correctly.
+// The clock mutex should be locked before calling this.
+func (c *TickClock) extend(tick uint32) int64 {
+	delta := int64(int32(tick - c.lastTick))
+	extended := c.extended + delta
+	if delta > 0 {
+		c.lastTick = tick
+		c.extended = extended
+	}
+	return extended
+}
+
+// Nanoseconds returns the Unix time of a tick in nanoseconds.
+func (c *TickClock) Nanoseconds(tick uint32) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.synced {
+		c.sync(tick, time.Now())
+	}
+	return uint64(c.syncNs + (c.extend(tick)-c.syncTick)*int64(time.Microsecond))
+}
@@ -0,0 +1,67 @@
+package rpiutils
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestTickClock(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	const us = uint64(time.Microsecond)
+
+	t.Run("ticks are mapped onto the time of the sync", func(t *testing.T) {
+		var c TickClock
+		c.Sync(1000, start)
+		test.That(t, c.Nanoseconds(1000), test.ShouldEqual, uint64(start.UnixNano()))
+		test.That(t, c.Nanoseconds(1500), test.ShouldEqual, uint64(start.UnixNano())+500*us)
+		// a tick from before the sync
+		test.That(t, c.Nanoseconds(900), test.ShouldEqual, uint64(start.UnixNano())-100*us)
+	})
+
+	t.Run("wrap around", func(t *testing.T) {
+		var c TickClock
+		c.Sync(math.MaxUint32-10, start)
+		test.That(t, c.Nanoseconds(math.MaxUint32), test.ShouldEqual, uint64(start.UnixNano())+10*us)
+		// the tick after MaxUint32 is 0, a full 2^32 microseconds after the tick before the wrap
+		test.That(t, c.Nanoseconds(0), test.ShouldEqual, uint64(start.UnixNano())+11*us)
+		test.That(t, c.Nanoseconds(5), test.ShouldEqual, uint64(start.UnixNano())+16*us)
+		// a late tick from before the wrap
+		test.That(t, c.Nanoseconds(math.MaxUint32-1), test.ShouldEqual, uint64(start.UnixNano())+9*us)
+
+		// several wraps, with ticks less than half a wrap apart
+		var tick uint32 = 5
+		for range 8 {
+			tick += 1 << 30
+			c.Nanoseconds(tick)
+		}
+		test.That(t, c.Nanoseconds(tick), test.ShouldEqual, uint64(start.UnixNano())+(16+2*(1<<32))*us)
+	})
+
+	t.Run("sync corrects drift", func(t *testing.T) {
+		var c TickClock
+		c.Sync(0, start)
+		test.That(t, c.Nanoseconds(60_000_000), test.ShouldEqual, uint64(start.Add(time.Minute).UnixNano()))
+		// the daemon's clock ran 1 ms slow over the minute
+		c.Sync(59_999_000, start.Add(time.Minute))
+		test.That(t, c.Nanoseconds(60_000_000), test.ShouldEqual, uint64(start.Add(time.Minute+time.Millisecond).UnixNano()))
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		var c TickClock
+		c.Sync(math.MaxUint32, start)
+		c.Reset()
+		c.Sync(10, start.Add(time.Hour))
+		test.That(t, c.Nanoseconds(20), test.ShouldEqual, uint64(start.Add(time.Hour).UnixNano())+10*us)
+	})
+
+	t.Run("unsynced clocks sync on the first tick", func(t *testing.T) {
+		var c TickClock
+		before := uint64(time.Now().UnixNano())
+		ns := c.Nanoseconds(1234)
+		test.That(t, ns, test.ShouldBeBetweenOrEqual, before, uint64(time.Now().UnixNano()))
+		test.That(t, c.Nanoseconds(1334), test.ShouldEqual, ns+100*us)
+	})
+}
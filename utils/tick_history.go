@@ -0,0 +1,105 @@
+package rpiutils
+
+/*
+	tick_history.go: Keeps the recent ticks of every interrupt, so the last edges of a misbehaving
+	sensor can be looked at with the tick_history DoCommand without a stream set up beforehand.
+	The ticks are kept in a ring buffer of tick_history_size, which holds the same ticks a
+	StreamTicks client would have received, watchdog ticks included.
+*/
+
+import (
+	"sync"
+
+	"go.viam.com/rdk/components/board"
+)
+
+const (
+	// DefaultTickHistorySize is the number of ticks an interrupt keeps if no size was configured.
+	DefaultTickHistorySize = 256
+	// MaxTickHistorySize is the largest number of ticks an interrupt can keep.
+	MaxTickHistorySize = 65536
+)
+
+// tickHistorySize returns the number of ticks an interrupt keeps.
+func (config *PinConfig) tickHistorySize() int {
+	if config.TickHistorySize == 0 {
+		return DefaultTickHistorySize
+	}
+	return config.TickHistorySize
+}
+
+// tickHistory is a ring buffer of the most recent ticks of an interrupt.
+type tickHistory struct {
+	mu    sync.Mutex
+	ticks []board.Tick // a ring buffer of n ticks starting at head
+	head  int
+	n     int
+}
+
+// add records a tick, dropping the oldest tick if the history holds size ticks already. The history
+// is resized, keeping the most recent ticks, if the size changed.
+func (h *tickHistory) add(tick board.Tick, size int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.ticks) != size {
+		ticks := h.ordered()
+		if len(ticks) > size {
+			ticks = ticks[len(ticks)-size:]
+		}
+		h.ticks = make([]board.Tick, size)
+		h.head, h.n = 0, copy(h.ticks, ticks)
+	}
+	if h.n == len(h.ticks) {
+		h.head = (h.head + 1) % len(h.ticks)
+		h.n--
+	}
+	h.ticks[(h.head+h.n)%len(h.ticks)] = tick
+	h.n++
+}
+
+// ordered returns the ticks oldest first.
+// The history mutex should be locked before calling this.
+func (h *tickHistory) ordered() []board.Tick {
+	ticks := make([]board.Tick, 0, h.n)
+	for k := range h.n {
+		ticks = append(ticks, h.ticks[(h.head+k)%len(h.ticks)])
+	}
+	return ticks
+}
+
+// since returns the ticks after the given time, oldest first.
+func (h *tickHistory) since(nanoseconds uint64) []board.Tick {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var ticks []board.Tick
+	for _, tick := range h.ordered() {
+		if tick.TimestampNanosec > nanoseconds {
+			ticks = append(ticks, tick)
+		}
+	}
+	return ticks
+}
+
+// TickHistory returns the ticks the interrupt kept that happened after the given Unix time in
+// nanoseconds, oldest first.
+func (i *BasicDigitalInterrupt) TickHistory(since uint64) []board.Tick {
+	return i.ticks.since(since)
+}
+
+// TickHistoryResult returns the result of the tick_history DoCommand for the interrupts: the ticks of
+// each of them after since, by name.
+func TickHistoryResult(interrupts []*BasicDigitalInterrupt, since uint64) map[string]interface{} {
+	history := map[string]interface{}{}
+	for _, i := range interrupts {
+		ticks := []interface{}{}
+		for _, tick := range i.TickHistory(since) {
+			ticks = append(ticks, map[string]interface{}{
+				"name":         tick.Name,
+				"high":         tick.High,
+				"timestamp_ns": tick.TimestampNanosec,
+			})
+		}
+		history[i.Name()] = ticks
+	}
+	return map[string]interface{}{"ticks": history}
+}
@@ -0,0 +1,57 @@
+package rpiutils
+
+import (
+	"context"
+	"testing"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/test"
+)
+
+func timestamps(ticks []board.Tick) []uint64 {
+	result := []uint64{}
+	for _, tick := range ticks {
+		result = append(result, tick.TimestampNanosec)
+	}
+	return result
+}
+
+func TestTickHistory(t *testing.T) {
+	var h tickHistory
+	for ns := uint64(1); ns <= 5; ns++ {
+		h.add(board.Tick{TimestampNanosec: ns}, 3)
+	}
+	test.That(t, timestamps(h.since(0)), test.ShouldResemble, []uint64{3, 4, 5})
+	test.That(t, timestamps(h.since(4)), test.ShouldResemble, []uint64{5})
+	test.That(t, timestamps(h.since(5)), test.ShouldResemble, []uint64{})
+
+	// resizing keeps the most recent ticks
+	h.add(board.Tick{TimestampNanosec: 6}, 2)
+	test.That(t, timestamps(h.since(0)), test.ShouldResemble, []uint64{5, 6})
+	h.add(board.Tick{TimestampNanosec: 7}, 4)
+	h.add(board.Tick{TimestampNanosec: 8}, 4)
+	test.That(t, timestamps(h.since(0)), test.ShouldResemble, []uint64{5, 6, 7, 8})
+
+	config := PinConfig{Name: "flow", Pin: "13", Type: PinInterrupt, TickHistorySize: 2}
+	test.That(t, config.Validate("path"), test.ShouldBeNil)
+	i, err := CreateDigitalInterrupt(config)
+	test.That(t, err, test.ShouldBeNil)
+	di := i.(*BasicDigitalInterrupt)
+	test.That(t, Tick(context.Background(), di, true, 10), test.ShouldBeNil)
+	test.That(t, WatchdogTimeout(context.Background(), di, true, 20), test.ShouldBeNil)
+	test.That(t, TickHistoryResult([]*BasicDigitalInterrupt{di}, 0), test.ShouldResemble, map[string]interface{}{
+		"ticks": map[string]interface{}{"flow": []interface{}{
+			map[string]interface{}{"name": "flow", "high": true, "timestamp_ns": uint64(10)},
+			map[string]interface{}{"name": "flow" + WatchdogTickSuffix, "high": true, "timestamp_ns": uint64(20)},
+		}},
+	})
+
+	config.TickHistorySize = MaxTickHistorySize + 1
+	err = config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.tick_history_size")
+	config = PinConfig{Name: "led", Pin: "13", Type: PinGPIO, TickHistorySize: 10}
+	err = config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.tick_history_size")
+}
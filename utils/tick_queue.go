@@ -0,0 +1,282 @@
+package rpiutils
+
+/*
+	tick_queue.go: Queues the ticks of a StreamTicks call. Interrupts only add their ticks to the
+	bounded queue of every subscriber, and a goroutine per subscriber sends them on its channel, so a
+	slow client does not hold up the callbacks of the board, and with them every other pin. What
+	happens to ticks that don't fit in a full queue depends on its overflow policy. The block policy
+	waits with the interrupt locked, so its wait is bounded: a client that stops reading can't keep
+	the interrupt from being reconfigured or closed for longer than TickBlockTimeout.
+*/
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/resource"
+)
+
+const (
+	// DefaultTickQueueSize is the number of ticks a subscriber queues if no size was configured.
+	DefaultTickQueueSize = 1024
+	// MaxTickQueueSize is the largest number of ticks a subscriber can queue.
+	MaxTickQueueSize = 65536
+	// TickBlockTimeout is how long the block overflow policy waits for room, before it drops the tick.
+	TickBlockTimeout = time.Second
+)
+
+// TickOverflow defines what happens to a tick that does not fit in the queue of a subscriber.
+type TickOverflow string
+
+const (
+	// TickOverflowDropOldest drops the oldest queued tick to make room for the new one.
+	TickOverflowDropOldest TickOverflow = "drop_oldest"
+	// TickOverflowDropNewest drops the new tick.
+	TickOverflowDropNewest TickOverflow = "drop_newest"
+	// TickOverflowBlock waits for the subscriber to make room, which holds up the interrupt, and drops
+	// the tick if there is no room after TickBlockTimeout.
+	TickOverflowBlock TickOverflow = "block"
+	// TickOverflowDefault is for if no overflow policy was set, and behaves like TickOverflowDropOldest.
+	TickOverflowDefault TickOverflow = ""
+)
+
+// Validate validates that the overflow policy is a valid policy.
+func (overflow TickOverflow) Validate() error {
+	switch overflow {
+	case TickOverflowDefault, TickOverflowDropOldest, TickOverflowDropNewest, TickOverflowBlock:
+	default:
+		return fmt.Errorf("invalid tick overflow %v, supported overflow policies are %v, %v, and %v",
+			overflow, TickOverflowDropOldest, TickOverflowDropNewest, TickOverflowBlock)
+	}
+	return nil
+}
+
+// TickQueueConfig describes the queue of the ticks streamed to a StreamTicks client.
+type TickQueueConfig struct {
+	Size     int          `json:"size,omitempty"`
+	Overflow TickOverflow `json:"overflow,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid.
+func (config *TickQueueConfig) Validate(path string) error {
+	if config.Size < 0 || config.Size > MaxTickQueueSize {
+		return resource.NewConfigValidationError(path+".size", fmt.Errorf("size must be between 0 and %d", MaxTickQueueSize))
+	}
+	if err := config.Overflow.Validate(); err != nil {
+		return resource.NewConfigValidationError(path+".overflow", err)
+	}
+	return nil
+}
+
+// withDefaults returns the config with the defaults filled in.
+func (config TickQueueConfig) withDefaults() TickQueueConfig {
+	if config.Size == 0 {
+		config.Size = DefaultTickQueueSize
+	}
+	if config.Overflow == TickOverflowDefault {
+		config.Overflow = TickOverflowDropOldest
+	}
+	return config
+}
+
+// TickQueueConfigFromExtra returns the queue config of a StreamTicks call: the config of the board,
+// with the size and overflow policy overridden by the optional "tick_queue_size" and "tick_overflow"
+// keys of its extra.
+func TickQueueConfigFromExtra(config TickQueueConfig, extra map[string]interface{}) (TickQueueConfig, error) {
+	if _, ok := extra["tick_queue_size"]; ok {
+		size, err := UintFromCommand(extra, "tick_queue_size")
+		if err != nil {
+			return TickQueueConfig{}, err
+		}
+		if size > MaxTickQueueSize {
+			return TickQueueConfig{}, fmt.Errorf("tick_queue_size must be at most %d, got %d", MaxTickQueueSize, size)
+		}
+		config.Size = int(size)
+	}
+	if raw, ok := extra["tick_overflow"]; ok {
+		overflow, ok := raw.(string)
+		if !ok {
+			return TickQueueConfig{}, fmt.Errorf("expected \"tick_overflow\" to be a string, got %T", raw)
+		}
+		config.Overflow = TickOverflow(overflow)
+		if err := config.Overflow.Validate(); err != nil {
+			return TickQueueConfig{}, err
+		}
+	}
+	return config, nil
+}
+
+// lastSubscriberID numbers the subscribers, so they can be told apart in the queue stats.
+var lastSubscriberID atomic.Uint64
+
+// A TickSubscriber queues the ticks of the interrupts it was added to, and sends them on its channel
+// in the order they happened.
+type TickSubscriber struct {
+	id         uint64
+	interrupts []string
+	ch         chan board.Tick
+	cfg        TickQueueConfig
+	dropped    atomic.Uint64
+	standalone bool // created by AddCallback, and closed by RemoveCallback
+	// blockTimeout is how long the block overflow policy waits for room
+	blockTimeout time.Duration
+
+	mu    sync.Mutex
+	queue []board.Tick // a ring buffer of n ticks starting at head
+	head  int
+	n     int
+
+	wake      chan struct{} // signalled when a tick was queued
+	space     chan struct{} // signalled when a tick was taken from the queue
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewTickSubscriber returns a subscriber that sends the ticks of the named interrupts on ch, and
+// starts sending. It has to be closed once it is no longer used.
+func NewTickSubscriber(ch chan board.Tick, cfg TickQueueConfig, interrupts []string) *TickSubscriber {
+	cfg = cfg.withDefaults()
+	s := &TickSubscriber{
+		id:           lastSubscriberID.Add(1),
+		interrupts:   interrupts,
+		ch:           ch,
+		cfg:          cfg,
+		blockTimeout: TickBlockTimeout,
+		queue:        make([]board.Tick, cfg.Size),
+		wake:         make(chan struct{}, 1),
+		space:        make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.send()
+	return s
+}
+
+// push queues a tick, following the overflow policy if the queue is full. Only the block policy
+// waits, until there is room, the subscriber is closed, or the context is cancelled. If there is no
+// room after the block timeout, the tick is dropped.
+func (s *TickSubscriber) push(ctx context.Context, tick board.Tick) error {
+	var timeout <-chan time.Time
+	for {
+		s.mu.Lock()
+		full := s.n == len(s.queue)
+		switch {
+		case !full:
+		case s.cfg.Overflow == TickOverflowDropNewest:
+			s.mu.Unlock()
+			s.dropped.Add(1)
+			return nil
+		case s.cfg.Overflow == TickOverflowDropOldest:
+			s.head = (s.head + 1) % len(s.queue)
+			s.n--
+			s.dropped.Add(1)
+		default:
+			s.mu.Unlock()
+			if timeout == nil {
+				timeout = time.After(s.blockTimeout)
+			}
+			select {
+			case <-ctx.Done():
+				return errors.New("context cancelled")
+			case <-s.done:
+				return nil
+			case <-timeout:
+				s.dropped.Add(1)
+				return nil
+			case <-s.space:
+			}
+			continue
+		}
+		s.queue[(s.head+s.n)%len(s.queue)] = tick
+		s.n++
+		s.mu.Unlock()
+		signal(s.wake)
+		return nil
+	}
+}
+
+// send sends the queued ticks on the channel of the subscriber until it is closed.
+func (s *TickSubscriber) send() {
+	defer s.wg.Done()
+	for {
+		s.mu.Lock()
+		if s.n == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.done:
+				return
+			case <-s.wake:
+			}
+			continue
+		}
+		tick := s.queue[s.head]
+		s.head = (s.head + 1) % len(s.queue)
+		s.n--
+		s.mu.Unlock()
+		signal(s.space)
+
+		select {
+		case <-s.done:
+			return
+		case s.ch <- tick:
+		}
+	}
+}
+
+// signal wakes up whoever waits on c, if they are not already woken up.
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// Dropped returns the amount of ticks that were dropped because the queue was full.
+func (s *TickSubscriber) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Stats returns the state of the queue, as reported by the tick_queue_stats DoCommand.
+func (s *TickSubscriber) Stats() map[string]interface{} {
+	s.mu.Lock()
+	queued := s.n
+	s.mu.Unlock()
+	interrupts := make([]interface{}, 0, len(s.interrupts))
+	for _, name := range s.interrupts {
+		interrupts = append(interrupts, name)
+	}
+	return map[string]interface{}{
+		"id":         s.id,
+		"interrupts": interrupts,
+		"size":       s.cfg.Size,
+		"overflow":   string(s.cfg.Overflow),
+		"queued":     queued,
+		"dropped":    s.Dropped(),
+	}
+}
+
+// Close stops sending ticks. Queued ticks are dropped.
+func (s *TickSubscriber) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+	s.wg.Wait()
+}
+
+// TickQueueStatsResult returns the result of the tick_queue_stats DoCommand for the subscribers of a
+// board.
+func TickQueueStatsResult(subscribers []*TickSubscriber) map[string]interface{} {
+	subscribers = slices.Clone(subscribers)
+	slices.SortFunc(subscribers, func(a, b *TickSubscriber) int { return cmp.Compare(a.id, b.id) })
+	stats := make([]interface{}, 0, len(subscribers))
+	for _, s := range subscribers {
+		stats = append(stats, s.Stats())
+	}
+	return map[string]interface{}{"subscribers": stats}
+}
@@ -0,0 +1,152 @@
+package rpiutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/test"
+)
+
+// stalledSubscriber returns a subscriber whose channel is not read yet. The first tick is pushed and
+// taken by the sender, which waits to send it, so every other tick stays queued.
+func stalledSubscriber(t *testing.T, cfg TickQueueConfig) (*TickSubscriber, chan board.Tick) {
+	t.Helper()
+	ch := make(chan board.Tick)
+	s := NewTickSubscriber(ch, cfg, []string{"i1"})
+	test.That(t, s.push(context.Background(), board.Tick{Name: "i1", TimestampNanosec: 0}), test.ShouldBeNil)
+	for s.Stats()["queued"] != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	return s, ch
+}
+
+func receiveTimestamps(ch chan board.Tick, n int) []uint64 {
+	timestamps := []uint64{}
+	for range n {
+		timestamps = append(timestamps, (<-ch).TimestampNanosec)
+	}
+	return timestamps
+}
+
+func TestTickSubscriber(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("drop oldest", func(t *testing.T) {
+		s, ch := stalledSubscriber(t, TickQueueConfig{Size: 2})
+		defer s.Close()
+		for ns := uint64(1); ns <= 4; ns++ {
+			test.That(t, s.push(ctx, board.Tick{TimestampNanosec: ns}), test.ShouldBeNil)
+		}
+		test.That(t, s.Dropped(), test.ShouldEqual, 2)
+		test.That(t, receiveTimestamps(ch, 3), test.ShouldResemble, []uint64{0, 3, 4})
+	})
+
+	t.Run("drop newest", func(t *testing.T) {
+		s, ch := stalledSubscriber(t, TickQueueConfig{Size: 2, Overflow: TickOverflowDropNewest})
+		defer s.Close()
+		for ns := uint64(1); ns <= 4; ns++ {
+			test.That(t, s.push(ctx, board.Tick{TimestampNanosec: ns}), test.ShouldBeNil)
+		}
+		test.That(t, s.Dropped(), test.ShouldEqual, 2)
+		test.That(t, receiveTimestamps(ch, 3), test.ShouldResemble, []uint64{0, 1, 2})
+	})
+
+	t.Run("block", func(t *testing.T) {
+		s, ch := stalledSubscriber(t, TickQueueConfig{Size: 1, Overflow: TickOverflowBlock})
+		defer s.Close()
+		test.That(t, s.push(ctx, board.Tick{TimestampNanosec: 1}), test.ShouldBeNil)
+
+		pushed := make(chan error, 1)
+		go func() { pushed <- s.push(ctx, board.Tick{TimestampNanosec: 2}) }()
+		select {
+		case <-pushed:
+			t.Fatal("push did not wait for room in the queue")
+		case <-time.After(20 * time.Millisecond):
+		}
+		test.That(t, receiveTimestamps(ch, 3), test.ShouldResemble, []uint64{0, 1, 2})
+		test.That(t, <-pushed, test.ShouldBeNil)
+		test.That(t, s.Dropped(), test.ShouldEqual, 0)
+
+		// a cancelled context lets go of a full queue
+		test.That(t, s.push(ctx, board.Tick{TimestampNanosec: 3}), test.ShouldBeNil)
+		for s.Stats()["queued"] != 0 {
+			time.Sleep(time.Millisecond)
+		}
+		test.That(t, s.push(ctx, board.Tick{TimestampNanosec: 4}), test.ShouldBeNil)
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		test.That(t, s.push(cancelled, board.Tick{TimestampNanosec: 5}), test.ShouldNotBeNil)
+	})
+
+	t.Run("block drops the tick after the timeout", func(t *testing.T) {
+		s, ch := stalledSubscriber(t, TickQueueConfig{Size: 1, Overflow: TickOverflowBlock})
+		defer s.Close()
+		s.blockTimeout = 10 * time.Millisecond
+		test.That(t, s.push(ctx, board.Tick{TimestampNanosec: 1}), test.ShouldBeNil)
+		test.That(t, s.push(ctx, board.Tick{TimestampNanosec: 2}), test.ShouldBeNil)
+		test.That(t, s.Dropped(), test.ShouldEqual, 1)
+		test.That(t, receiveTimestamps(ch, 2), test.ShouldResemble, []uint64{0, 1})
+	})
+
+	t.Run("close lets go of a blocked interrupt", func(t *testing.T) {
+		s, _ := stalledSubscriber(t, TickQueueConfig{Size: 1, Overflow: TickOverflowBlock})
+		test.That(t, s.push(ctx, board.Tick{TimestampNanosec: 1}), test.ShouldBeNil)
+		pushed := make(chan error, 1)
+		go func() { pushed <- s.push(ctx, board.Tick{TimestampNanosec: 2}) }()
+		s.Close()
+		test.That(t, <-pushed, test.ShouldBeNil)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		s, _ := stalledSubscriber(t, TickQueueConfig{Size: 1, Overflow: TickOverflowDropNewest})
+		defer s.Close()
+		test.That(t, s.push(ctx, board.Tick{TimestampNanosec: 1}), test.ShouldBeNil)
+		test.That(t, s.push(ctx, board.Tick{TimestampNanosec: 2}), test.ShouldBeNil)
+
+		result := TickQueueStatsResult([]*TickSubscriber{s})
+		stats := result["subscribers"].([]interface{})[0].(map[string]interface{})
+		test.That(t, stats["interrupts"], test.ShouldResemble, []interface{}{"i1"})
+		test.That(t, stats["size"], test.ShouldEqual, 1)
+		test.That(t, stats["overflow"], test.ShouldEqual, "drop_newest")
+		test.That(t, stats["queued"], test.ShouldEqual, 1)
+		test.That(t, stats["dropped"], test.ShouldEqual, 1)
+	})
+}
+
+func TestTickQueueConfig(t *testing.T) {
+	config := TickQueueConfig{Size: 10, Overflow: TickOverflowBlock}
+	test.That(t, config.Validate("path"), test.ShouldBeNil)
+
+	config = TickQueueConfig{Size: -1}
+	err := config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.size")
+
+	config = TickQueueConfig{Size: MaxTickQueueSize + 1}
+	err = config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.size")
+
+	config = TickQueueConfig{Overflow: "drop_all"}
+	err = config.Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "path.overflow")
+
+	base := TickQueueConfig{Size: 10, Overflow: TickOverflowBlock}
+	config, err = TickQueueConfigFromExtra(base, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, config, test.ShouldResemble, base)
+
+	config, err = TickQueueConfigFromExtra(base, map[string]interface{}{"tick_queue_size": 5.0, "tick_overflow": "drop_newest"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, config, test.ShouldResemble, TickQueueConfig{Size: 5, Overflow: TickOverflowDropNewest})
+
+	_, err = TickQueueConfigFromExtra(base, map[string]interface{}{"tick_overflow": "drop_all"})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = TickQueueConfigFromExtra(base, map[string]interface{}{"tick_queue_size": -1.0})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = TickQueueConfigFromExtra(base, map[string]interface{}{"tick_queue_size": float64(MaxTickQueueSize + 1)})
+	test.That(t, err, test.ShouldNotBeNil)
+}
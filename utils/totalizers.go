@@ -0,0 +1,168 @@
+package rpiutils
+
+/*
+	totalizers.go: Keeps the counts of interrupts with persist: true across restarts of the module
+	and reconfigures that create their interrupts again. The counts are kept in a state file of the
+	board under the data directory of the module, which is checkpointed periodically and when the
+	board closes, and replaced with WriteFileAtomic so a crash leaves a whole file behind. A count
+	is restored when its interrupt is created.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/logging"
+)
+
+// TotalizerCheckpointInterval is how often the boards checkpoint the counts of their persisted
+// interrupts.
+const TotalizerCheckpointInterval = 10 * time.Second
+
+// TotalizersPath returns the state file of the persisted counts of a board, under the data directory
+// viam-server gives the module. It returns "" if the module has no data directory.
+func TotalizersPath(boardName string) string {
+	dir := os.Getenv("VIAM_MODULE_DATA")
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, boardName+"-totalizers.json")
+}
+
+// totalizersState is the content of the state file.
+type totalizersState struct {
+	Counts map[string]int64 `json:"counts"`
+}
+
+// Totalizers are the persisted counts of the interrupts of a board, by interrupt name.
+type Totalizers struct {
+	path string
+
+	mu     sync.Mutex
+	counts map[string]int64
+	saved  bool // the state file holds the counts
+}
+
+// NewTotalizers loads the persisted counts from the state file at path. A missing state file holds no
+// counts, and an empty path keeps the counts in memory only.
+func NewTotalizers(path string) (*Totalizers, error) {
+	t := &Totalizers{path: path, counts: map[string]int64{}, saved: true}
+	if path == "" {
+		return t, nil
+	}
+	content, err := os.ReadFile(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read totalizers file %s: %w", path, err)
+	}
+	var state totalizersState
+	if err := json.Unmarshal(content, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse totalizers file %s: %w", path, err)
+	}
+	for name, count := range state.Counts {
+		t.counts[name] = count
+	}
+	return t, nil
+}
+
+// LoadTotalizers loads the persisted counts of the interrupts of a board from its state file.
+func LoadTotalizers(boardName string, cfg *Config, logger logging.Logger) (*Totalizers, error) {
+	path := TotalizersPath(boardName)
+	if path == "" && slices.ContainsFunc(cfg.Pins, func(pin PinConfig) bool { return pin.Persist }) {
+		logger.Warn("the module has no data directory, so the counts of interrupts with persist: true are not saved")
+	}
+	return NewTotalizers(path)
+}
+
+// Restore sets the count of a new interrupt with persist: true to its persisted count, if it has one.
+func (t *Totalizers) Restore(i *BasicDigitalInterrupt) {
+	if !i.persisted() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if count, ok := t.counts[i.Name()]; ok {
+		i.ResetCounter(count)
+	}
+}
+
+// Record takes the count of an interrupt with persist: true, to be saved at the next checkpoint. An
+// interrupt that is about to be removed is recorded, so its count is restored if it is added again.
+func (t *Totalizers) Record(i *BasicDigitalInterrupt) {
+	if !i.persisted() {
+		return
+	}
+	count, err := i.Value(context.Background(), nil)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// an interrupt without a persisted count starts at 0
+	if t.counts[i.Name()] != count {
+		t.counts[i.Name()] = count
+		t.saved = false
+	}
+}
+
+// Checkpoint records the interrupts, and writes the counts to the state file if they changed since it
+// was last written.
+func (t *Totalizers) Checkpoint(interrupts []*BasicDigitalInterrupt) error {
+	for _, i := range interrupts {
+		t.Record(i)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.saved || t.path == "" {
+		return nil
+	}
+	content, err := json.Marshal(totalizersState{Counts: t.counts})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create the directory of totalizers file %s: %w", t.path, err)
+	}
+	if err := WriteFileAtomic(t.path, content, 0o600); err != nil {
+		return err
+	}
+	t.saved = true
+	return nil
+}
+
+// CheckpointEvery checkpoints the interrupts returned by interrupts every interval until the context
+// is cancelled.
+func (t *Totalizers) CheckpointEvery(
+	ctx context.Context,
+	interval time.Duration,
+	interrupts func() []*BasicDigitalInterrupt,
+	logger logging.Logger,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := t.Checkpoint(interrupts()); err != nil {
+			logger.Warnw("failed to checkpoint the counts of persisted interrupts", "error", err)
+		}
+	}
+}
+
+// persisted returns whether the count of the interrupt is persisted.
+func (i *BasicDigitalInterrupt) persisted() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.cfg.Persist
+}
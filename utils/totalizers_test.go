@@ -0,0 +1,102 @@
+package rpiutils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+)
+
+func persistedInterrupt(t *testing.T, name string, persist bool) *BasicDigitalInterrupt {
+	t.Helper()
+	i, err := CreateDigitalInterrupt(PinConfig{Name: name, Type: PinInterrupt, Persist: persist})
+	test.That(t, err, test.ShouldBeNil)
+	return i.(*BasicDigitalInterrupt)
+}
+
+func TestTotalizers(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state", "board-totalizers.json")
+
+	totalizers, err := NewTotalizers(path)
+	test.That(t, err, test.ShouldBeNil)
+	flow := persistedInterrupt(t, "flow", true)
+	other := persistedInterrupt(t, "other", false)
+	totalizers.Restore(flow)
+	count, err := flow.Value(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 0)
+
+	// nothing is written until there is a count to persist
+	test.That(t, totalizers.Checkpoint([]*BasicDigitalInterrupt{flow, other}), test.ShouldBeNil)
+	_, err = os.Stat(path)
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+
+	flow.ResetCounter(41)
+	other.ResetCounter(7)
+	test.That(t, Tick(ctx, flow, true, 1), test.ShouldBeNil)
+	test.That(t, totalizers.Checkpoint([]*BasicDigitalInterrupt{flow, other}), test.ShouldBeNil)
+	content, err := os.ReadFile(path)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(content), test.ShouldEqual, `{"counts":{"flow":42}}`)
+
+	// an unchanged count is not written again
+	test.That(t, os.Remove(path), test.ShouldBeNil)
+	test.That(t, totalizers.Checkpoint([]*BasicDigitalInterrupt{flow, other}), test.ShouldBeNil)
+	_, err = os.Stat(path)
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+
+	// a removed interrupt is recorded, and restored when it is added again
+	test.That(t, Tick(ctx, flow, true, 2), test.ShouldBeNil)
+	totalizers.Record(flow)
+	readded := persistedInterrupt(t, "flow", true)
+	totalizers.Restore(readded)
+	count, err = readded.Value(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 43)
+	test.That(t, totalizers.Checkpoint(nil), test.ShouldBeNil)
+
+	// the counts survive a restart
+	reloaded, err := NewTotalizers(path)
+	test.That(t, err, test.ShouldBeNil)
+	restarted := persistedInterrupt(t, "flow", true)
+	reloaded.Restore(restarted)
+	count, err = restarted.Value(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 43)
+	notPersisted := persistedInterrupt(t, "flow", false)
+	reloaded.Restore(notPersisted)
+	count, err = notPersisted.Value(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 0)
+
+	test.That(t, os.WriteFile(path, []byte("{"), 0o600), test.ShouldBeNil)
+	_, err = NewTotalizers(path)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestLoadTotalizers(t *testing.T) {
+	logger := logging.NewTestLogger(t)
+	cfg := &Config{Pins: []PinConfig{{Name: "flow", Pin: "13", Type: PinInterrupt, Persist: true}}}
+
+	t.Setenv("VIAM_MODULE_DATA", "")
+	test.That(t, TotalizersPath("board"), test.ShouldEqual, "")
+	totalizers, err := LoadTotalizers("board", cfg, logger)
+	test.That(t, err, test.ShouldBeNil)
+	flow := persistedInterrupt(t, "flow", true)
+	flow.ResetCounter(5)
+	test.That(t, totalizers.Checkpoint([]*BasicDigitalInterrupt{flow}), test.ShouldBeNil)
+
+	dir := t.TempDir()
+	t.Setenv("VIAM_MODULE_DATA", dir)
+	test.That(t, TotalizersPath("board"), test.ShouldEqual, filepath.Join(dir, "board-totalizers.json"))
+	totalizers, err = LoadTotalizers("board", cfg, logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, totalizers.Checkpoint([]*BasicDigitalInterrupt{flow}), test.ShouldBeNil)
+	content, err := os.ReadFile(filepath.Join(dir, "board-totalizers.json"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(content), test.ShouldEqual, `{"counts":{"flow":5}}`)
+}
@@ -0,0 +1,183 @@
+// Package rpiutils contains helpers for decoding the waveform DoCommands.
+package rpiutils
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxWavePulses is the most pulses pigpio can hold in a single wave.
+	MaxWavePulses = 12000
+	// MaxWaveChainBytes is the longest wave chain pigpio accepts, including loop and delay commands.
+	MaxWaveChainBytes = 600
+	// MaxWaves is how many waves pigpio can hold at once, wave ids are always below it.
+	MaxWaves = 250
+	// maxWaveChainValue is the largest loop count or delay a chain command can encode.
+	maxWaveChainValue = 65535
+)
+
+// WavePulse is a single step of a wave: the pins named in On are switched on, the pins named in Off
+// are switched off, and the wave then waits DelayUS microseconds before the next pulse.
+type WavePulse struct {
+	On      []string
+	Off     []string
+	DelayUS uint
+}
+
+// PulsesFromCommand decodes the "pulses" argument of a wave_add_generic request, a list of objects
+// with optional "on" and "off" pin name lists and a "delay_us".
+func PulsesFromCommand(cmd map[string]interface{}) ([]WavePulse, error) {
+	raw, ok := cmd["pulses"].([]interface{})
+	if !ok {
+		return nil, errors.New("expected \"pulses\" to be a list of pulses")
+	}
+	if len(raw) == 0 || len(raw) > MaxWavePulses {
+		return nil, fmt.Errorf("a wave must have between 1 and %d pulses, got %d", MaxWavePulses, len(raw))
+	}
+
+	pulses := make([]WavePulse, 0, len(raw))
+	for idx, rawPulse := range raw {
+		fields, ok := rawPulse.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("pulse %d: expected an object, got %T", idx, rawPulse)
+		}
+		on, err := pinNamesFromField(fields, "on")
+		if err != nil {
+			return nil, fmt.Errorf("pulse %d: %w", idx, err)
+		}
+		off, err := pinNamesFromField(fields, "off")
+		if err != nil {
+			return nil, fmt.Errorf("pulse %d: %w", idx, err)
+		}
+		var delayUS uint
+		if _, ok := fields["delay_us"]; ok {
+			if delayUS, err = UintFromCommand(fields, "delay_us"); err != nil {
+				return nil, fmt.Errorf("pulse %d: %w", idx, err)
+			}
+		}
+		pulses = append(pulses, WavePulse{On: on, Off: off, DelayUS: delayUS})
+	}
+	return pulses, nil
+}
+
+// pinNamesFromField decodes an optional list of pin names.
+func pinNamesFromField(fields map[string]interface{}, key string) ([]string, error) {
+	raw, ok := fields[key]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected %q to be a list of pin names, got %T", key, raw)
+	}
+	names := make([]string, 0, len(list))
+	for _, rawName := range list {
+		name, ok := rawName.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected %q to be a list of pin names, got a %T", key, rawName)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// EncodeWaveChain encodes the "chain" argument of a wave_chain request into pigpio's chain format,
+// returning the encoded chain and every wave id it references. Entries are either wave ids, a
+// {"delay_us": n} pause, a {"repeat": n, "chain": [...]} loop, or a {"forever": [...]} loop, which
+// has to be the last entry of the chain.
+func EncodeWaveChain(cmd map[string]interface{}) ([]byte, []uint, error) {
+	raw, ok := cmd["chain"].([]interface{})
+	if !ok || len(raw) == 0 {
+		return nil, nil, errors.New("expected \"chain\" to be a non-empty list")
+	}
+	var ids []uint
+	buf, err := encodeWaveChain(raw, true, &ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(buf) > MaxWaveChainBytes {
+		return nil, nil, fmt.Errorf("wave chain is %d bytes long, the limit is %d", len(buf), MaxWaveChainBytes)
+	}
+	return buf, ids, nil
+}
+
+func encodeWaveChain(entries []interface{}, topLevel bool, ids *[]uint) ([]byte, error) {
+	buf := []byte{}
+	for idx, entry := range entries {
+		switch e := entry.(type) {
+		case map[string]interface{}:
+			encoded, err := encodeWaveChainCommand(e, topLevel && idx == len(entries)-1, ids)
+			if err != nil {
+				return nil, fmt.Errorf("chain entry %d: %w", idx, err)
+			}
+			buf = append(buf, encoded...)
+		default:
+			id, err := UintFromCommand(map[string]interface{}{"wave_id": entry}, "wave_id")
+			if err != nil {
+				return nil, fmt.Errorf("chain entry %d: %w", idx, err)
+			}
+			if id >= MaxWaves {
+				return nil, fmt.Errorf("chain entry %d: invalid wave id %d", idx, id)
+			}
+			*ids = append(*ids, id)
+			buf = append(buf, byte(id))
+		}
+	}
+	return buf, nil
+}
+
+// encodeWaveChainCommand encodes a delay or loop entry of a wave chain.
+func encodeWaveChainCommand(entry map[string]interface{}, last bool, ids *[]uint) ([]byte, error) {
+	if _, ok := entry["delay_us"]; ok {
+		delay, err := chainValue(entry, "delay_us")
+		if err != nil {
+			return nil, err
+		}
+		return []byte{255, 2, byte(delay), byte(delay >> 8)}, nil
+	}
+
+	if forever, ok := entry["forever"]; ok {
+		if !last {
+			return nil, errors.New("a forever loop has to be the last entry of the chain")
+		}
+		nested, ok := forever.([]interface{})
+		if !ok || len(nested) == 0 {
+			return nil, errors.New("expected \"forever\" to be a non-empty list")
+		}
+		body, err := encodeWaveChain(nested, false, ids)
+		if err != nil {
+			return nil, err
+		}
+		return append(append([]byte{255, 0}, body...), 255, 3), nil
+	}
+
+	if _, ok := entry["repeat"]; ok {
+		count, err := chainValue(entry, "repeat")
+		if err != nil {
+			return nil, err
+		}
+		nested, ok := entry["chain"].([]interface{})
+		if !ok || len(nested) == 0 {
+			return nil, errors.New("expected \"chain\" to be a non-empty list")
+		}
+		body, err := encodeWaveChain(nested, false, ids)
+		if err != nil {
+			return nil, err
+		}
+		return append(append([]byte{255, 0}, body...), 255, 1, byte(count), byte(count>>8)), nil
+	}
+	return nil, errors.New("expected a wave id, or an object with \"delay_us\", \"repeat\" or \"forever\"")
+}
+
+// chainValue reads a loop count or delay, which the chain encodes in two bytes.
+func chainValue(entry map[string]interface{}, key string) (uint, error) {
+	value, err := UintFromCommand(entry, key)
+	if err != nil {
+		return 0, err
+	}
+	if value > maxWaveChainValue {
+		return 0, fmt.Errorf("%q cannot be larger than %d, got %d", key, maxWaveChainValue, value)
+	}
+	return value, nil
+}
@@ -0,0 +1,79 @@
+package rpiutils
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestPulsesFromCommand(t *testing.T) {
+	pulses, err := PulsesFromCommand(map[string]interface{}{
+		"pulses": []interface{}{
+			map[string]interface{}{"on": []interface{}{"step"}, "delay_us": 10.0},
+			map[string]interface{}{"off": []interface{}{"step", "dir"}, "delay_us": 90.0},
+			map[string]interface{}{},
+		},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pulses, test.ShouldResemble, []WavePulse{
+		{On: []string{"step"}, DelayUS: 10},
+		{Off: []string{"step", "dir"}, DelayUS: 90},
+		{},
+	})
+
+	_, err = PulsesFromCommand(map[string]interface{}{})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = PulsesFromCommand(map[string]interface{}{"pulses": []interface{}{}})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = PulsesFromCommand(map[string]interface{}{"pulses": make([]interface{}, MaxWavePulses+1)})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = PulsesFromCommand(map[string]interface{}{
+		"pulses": []interface{}{map[string]interface{}{"on": "step"}},
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = PulsesFromCommand(map[string]interface{}{
+		"pulses": []interface{}{map[string]interface{}{"delay_us": -5.0}},
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestEncodeWaveChain(t *testing.T) {
+	buf, ids, err := EncodeWaveChain(map[string]interface{}{
+		"chain": []interface{}{
+			0.0,
+			map[string]interface{}{"repeat": 300.0, "chain": []interface{}{1.0, map[string]interface{}{"delay_us": 1000.0}}},
+			map[string]interface{}{"forever": []interface{}{2.0}},
+		},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, buf, test.ShouldResemble, []byte{
+		0,
+		255, 0, 1, 255, 2, 0xe8, 0x03, 255, 1, 0x2c, 0x01,
+		255, 0, 2, 255, 3,
+	})
+	test.That(t, ids, test.ShouldResemble, []uint{0, 1, 2})
+
+	_, _, err = EncodeWaveChain(map[string]interface{}{"chain": []interface{}{}})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = EncodeWaveChain(map[string]interface{}{"chain": []interface{}{float64(MaxWaves)}})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = EncodeWaveChain(map[string]interface{}{
+		"chain": []interface{}{map[string]interface{}{"forever": []interface{}{0.0}}, 1.0},
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = EncodeWaveChain(map[string]interface{}{
+		"chain": []interface{}{map[string]interface{}{"delay_us": 70000.0}},
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = EncodeWaveChain(map[string]interface{}{
+		"chain": []interface{}{map[string]interface{}{"pause": 10.0}},
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	long := make([]interface{}, MaxWaveChainBytes+1)
+	for i := range long {
+		long[i] = 0.0
+	}
+	_, _, err = EncodeWaveChain(map[string]interface{}{"chain": long})
+	test.That(t, err, test.ShouldNotBeNil)
+}